@@ -0,0 +1,145 @@
+// Package api is the public HTTP API of `vps-manager listen`: its OpenAPI 3
+// document (also served at /api/openapi.json), the types it exchanges and a
+// typed Go client for tools that drive a host.
+//
+// It depends on nothing but the standard library. The server converts its
+// own structures to these types, and the tests check both the types and the
+// server's answers against the document.
+package api
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+)
+
+// Spec is the OpenAPI 3 document of the API
+//
+//go:embed openapi.json
+var Spec []byte
+
+const (
+	Prefix   = "/api/v1"           // Every route in Spec lives under it
+	SpecPath = "/api/openapi.json" // Where the server serves Spec (no API key needed)
+)
+
+// CreateVMRequest is the body of POST /api/v1/vms
+type CreateVMRequest struct {
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	Plan     string            `json:"plan,omitempty"`     // Default Starter
+	Username string            `json:"username,omitempty"` // Default root
+	Password string            `json:"password,omitempty"`
+	Networks []string          `json:"networks,omitempty"`
+	SSHKeys  []string          `json:"ssh_keys,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// ActionRequest is the (optional) body of POST /api/v1/vms/{id}/<action>
+type ActionRequest struct {
+	Username string `json:"username,omitempty"` // reset-password, add-ssh-key
+	Password string `json:"password,omitempty"` // rescue, reset-password
+	SSHKey   string `json:"ssh_key,omitempty"`  // rescue, add-ssh-key
+}
+
+// RebuildRequest is the body of POST /api/v1/vms/{id}/rebuild
+type RebuildRequest struct {
+	Image    string `json:"image"`
+	Password string `json:"password"`
+}
+
+// ResizeRequest is the body of POST /api/v1/vms/{id}/resize
+type ResizeRequest struct {
+	Plan string `json:"plan"`
+}
+
+// RegisterImageRequest is the body of POST /api/v1/images
+type RegisterImageRequest struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Format string `json:"format,omitempty"`
+	Pull   *bool  `json:"pull,omitempty"` // Default true: download right away (answers a job)
+}
+
+// Accepted is the 202 answer of routes that run as background jobs
+type Accepted struct {
+	Status string `json:"status"`
+	JobID  string `json:"job_id"`
+	ID     string `json:"id"` // VM or image the job works on
+}
+
+// Error codes; switch on these, not on messages
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeInternal         = "internal"
+	CodeUnavailable      = "unavailable"
+)
+
+// ErrorBody is what every error answer looks like:
+// {"error":{"code":"not_found","message":"...","request_id":"..."}}
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"` // Only for validation_failed
+}
+
+// FieldError is one invalid input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	ErrNotFound     = errors.New("not found")    // 404
+	ErrConflict     = errors.New("conflict")     // 409: busy, wrong state, image in use
+	ErrUnauthorized = errors.New("unauthorized") // 401/403: missing key, scope or quota
+)
+
+// Error is a non-2xx answer
+type Error struct {
+	Status int
+	ErrorDetail
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("server returned %d: %s", e.Status, e.Message)
+	for _, f := range e.Fields[min(1, len(e.Fields)):] { // The message names the first one
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Message)
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Unwrap lets callers use errors.Is(err, api.ErrNotFound) etc.
+func (e *Error) Unwrap() error {
+	switch e.Status {
+	case 404:
+		return ErrNotFound
+	case 409:
+		return ErrConflict
+	case 401, 403:
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// IsCode reports whether err is an API error with the given code
+func IsCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultPollInterval is how often WaitJob checks on a job
+const DefaultPollInterval = time.Second
+
+// Client talks to one server
+type Client struct {
+	Server       string // "https://host:8080"
+	Token        string // API key, sent as a bearer token
+	HTTP         *http.Client
+	PollInterval time.Duration
+}
+
+func NewClient(server, token string) *Client {
+	return &Client{
+		Server:       strings.TrimRight(server, "/"),
+		Token:        token,
+		HTTP:         &http.Client{Timeout: 60 * time.Second},
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+// WithTLS makes the client verify the server (and present a client
+// certificate) as cfg says
+func (c *Client) WithTLS(cfg *tls.Config) *Client {
+	if cfg != nil {
+		c.HTTP.Transport = &http.Transport{TLSClientConfig: cfg, Proxy: http.ProxyFromEnvironment}
+	}
+	return c
+}
+
+// do sends a request to Prefix+path and decodes a JSON answer into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Server+Prefix+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, parseError(resp.StatusCode, data)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("bad response from %s: %w", path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// parseError reads the error envelope, falling back to the raw body (e.g. from a proxy)
+func parseError(status int, data []byte) *Error {
+	var body ErrorBody
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		return &Error{Status: status, ErrorDetail: ErrorDetail{Message: strings.TrimSpace(string(data))}}
+	}
+	return &Error{Status: status, ErrorDetail: body.Error}
+}
+
+func vmPath(name string, rest ...string) string {
+	return "/vms/" + url.PathEscape(name) + strings.Join(rest, "")
+}
+
+// --- VMs ---
+
+func (c *Client) ListVMs(ctx context.Context) ([]VM, error) {
+	var list []VM
+	_, err := c.do(ctx, http.MethodGet, "/vms", nil, &list)
+	return list, err
+}
+
+func (c *Client) GetVM(ctx context.Context, name string) (VMInfo, error) {
+	var info VMInfo
+	_, err := c.do(ctx, http.MethodGet, vmPath(name), nil, &info)
+	return info, err
+}
+
+// CreateVM queues the creation; follow it with WaitJob
+func (c *Client) CreateVM(ctx context.Context, req CreateVMRequest) (Accepted, error) {
+	var acc Accepted
+	_, err := c.do(ctx, http.MethodPost, "/vms", req, &acc)
+	return acc, err
+}
+
+func (c *Client) DeleteVM(ctx context.Context, name string) (Accepted, error) {
+	var acc Accepted
+	_, err := c.do(ctx, http.MethodDelete, vmPath(name), nil, &acc)
+	return acc, err
+}
+
+// Action runs start, stop, reboot, rescue, unrescue, reset-password or
+// add-ssh-key. Quick actions finish before it returns (JobID is empty);
+// rescue/unrescue answer a job.
+func (c *Client) Action(ctx context.Context, name, action string, req ActionRequest) (Accepted, error) {
+	var raw json.RawMessage
+	status, err := c.do(ctx, http.MethodPost, vmPath(name, "/", url.PathEscape(action)), req, &raw)
+	if err != nil {
+		return Accepted{}, err
+	}
+	if status != http.StatusAccepted {
+		return Accepted{Status: string(JobSucceeded), ID: name}, nil
+	}
+	var acc Accepted
+	err = json.Unmarshal(raw, &acc)
+	return acc, err
+}
+
+func (c *Client) RebuildVM(ctx context.Context, name string, req RebuildRequest) (Accepted, error) {
+	var acc Accepted
+	_, err := c.do(ctx, http.MethodPost, vmPath(name, "/rebuild"), req, &acc)
+	return acc, err
+}
+
+func (c *Client) ResizeVM(ctx context.Context, name string, req ResizeRequest) (Accepted, error) {
+	var acc Accepted
+	_, err := c.do(ctx, http.MethodPost, vmPath(name, "/resize"), req, &acc)
+	return acc, err
+}
+
+// Metrics returns raw counters (cpu_time_ns, vcpus, mem_kb...)
+func (c *Client) Metrics(ctx context.Context, name string) (map[string]float64, error) {
+	var metrics map[string]float64
+	_, err := c.do(ctx, http.MethodGet, vmPath(name, "/metrics"), nil, &metrics)
+	return metrics, err
+}
+
+// SetLabels replaces the VM's labels
+func (c *Client) SetLabels(ctx context.Context, name string, labels map[string]string) error {
+	_, err := c.do(ctx, http.MethodPut, vmPath(name, "/labels"), labels, nil)
+	return err
+}
+
+// --- Images ---
+
+func (c *Client) ListImages(ctx context.Context) ([]Image, error) {
+	var list []Image
+	_, err := c.do(ctx, http.MethodGet, "/images", nil, &list)
+	return list, err
+}
+
+func (c *Client) GetImage(ctx context.Context, name string) (Image, error) {
+	var img Image
+	_, err := c.do(ctx, http.MethodGet, "/images/"+url.PathEscape(name), nil, &img)
+	return img, err
+}
+
+// RegisterImage adds (or repoints) an image without downloading it yet
+func (c *Client) RegisterImage(ctx context.Context, name, imageURL, format string) (Image, error) {
+	pull := false
+	var img Image
+	_, err := c.do(ctx, http.MethodPost, "/images", RegisterImageRequest{ID: name, URL: imageURL, Format: format, Pull: &pull}, &img)
+	return img, err
+}
+
+func (c *Client) PullImage(ctx context.Context, name string) (Accepted, error) {
+	var acc Accepted
+	_, err := c.do(ctx, http.MethodPost, "/images/"+url.PathEscape(name)+"/pull", nil, &acc)
+	return acc, err
+}
+
+// DeleteImage refuses images VM disks are layered on, unless forced
+func (c *Client) DeleteImage(ctx context.Context, name string, force bool) error {
+	path := "/images/" + url.PathEscape(name)
+	if force {
+		path += "?force=true"
+	}
+	_, err := c.do(ctx, http.MethodDelete, path, nil, nil)
+	return err
+}
+
+// --- Plans ---
+
+func (c *Client) Plans(ctx context.Context) ([]Plan, error) {
+	var list []Plan
+	_, err := c.do(ctx, http.MethodGet, "/plans", nil, &list)
+	return list, err
+}
+
+// --- Jobs ---
+
+func (c *Client) ListJobs(ctx context.Context) ([]Job, error) {
+	var list []Job
+	_, err := c.do(ctx, http.MethodGet, "/jobs", nil, &list)
+	return list, err
+}
+
+func (c *Client) GetJob(ctx context.Context, id string) (Job, error) {
+	var job Job
+	_, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, &job)
+	return job, err
+}
+
+// WaitJob polls a job until it finishes, passing new steps to onStep (may
+// be nil). A failed job is returned along with an error.
+func (c *Client) WaitJob(ctx context.Context, id string, onStep func(JobStep)) (Job, error) {
+	seen := 0
+	for {
+		job, err := c.GetJob(ctx, id)
+		if err != nil {
+			return job, err
+		}
+		for ; seen < len(job.Steps); seen++ {
+			if onStep != nil {
+				onStep(job.Steps[seen])
+			}
+		}
+		switch job.State {
+		case JobSucceeded:
+			return job, nil
+		case JobFailed:
+			return job, fmt.Errorf("%s %s failed: %s", job.Type, job.Target, job.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
@@ -0,0 +1,658 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/console"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/subscriptions"
+	"github.com/Shaman786/vps-manager/internal/vm"
+	"github.com/Shaman786/vps-manager/internal/webhook"
+)
+
+// fakeDriver keeps VMs in memory; methods the tests don't reach panic
+type fakeDriver struct {
+	core.HypervisorDriver
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func (d *fakeDriver) ListVMs() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var names []string
+	for name := range d.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *fakeDriver) GetVMInfo(id string) (core.VMState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	on, ok := d.running[id]
+	if !ok {
+		return core.VMState{}, fmt.Errorf("domain '%s' not found", id)
+	}
+	status := "shut off"
+	if on {
+		status = "running"
+	}
+	return core.VMState{ID: id, Name: id, Status: status, IP: "10.0.0.5"}, nil
+}
+
+func (d *fakeDriver) set(id string, on bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.running[id] = on
+	return nil
+}
+
+func (d *fakeDriver) CreateVM(c core.VMConfig) error { c.OnStep("booting"); return d.set(c.Name, true) }
+func (d *fakeDriver) StartVM(id string) error        { return d.set(id, true) }
+func (d *fakeDriver) StopVM(id string) error         { return d.set(id, false) }
+func (d *fakeDriver) ShutdownVM(id string) error     { return d.set(id, false) }
+func (d *fakeDriver) Reboot(id string) error         { return d.set(id, true) }
+func (d *fakeDriver) ResizeVM(core.VMConfig) error   { return nil }
+func (d *fakeDriver) RescueVM(c core.VMConfig) error {
+	return d.set(c.Name, true)
+}
+func (d *fakeDriver) UnrescueVM(id string) error { return d.set(id, true) }
+func (d *fakeDriver) SetAutostart(string, bool) error {
+	return nil
+}
+
+func (d *fakeDriver) DeleteVM(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.running, id)
+	return nil
+}
+
+func (d *fakeDriver) GetMetrics(string) (map[string]float64, error) {
+	return map[string]float64{"cpu_time_ns": 1e9, "vcpus": 1, "mem_kb": 524288}, nil
+}
+
+// newServer serves the real control plane with an admin key, checking
+// every exchange against api.Spec
+func newServer(t *testing.T) (*api.Client, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inv, err := vm.NewInventory(dir + "/inventory.json")
+	must(err)
+	must(inv.Put(vm.Record{Name: "web1", Image: "ubuntu-24.04", Plan: "Starter", Username: "root", CreatedAt: time.Now()}))
+	locks, err := vm.NewLocker(dir+"/locks", 2)
+	must(err)
+	mgr := vm.NewManager(&fakeDriver{running: map[string]bool{"web1": true}}, inv, locks)
+
+	store, err := images.NewStore(dir+"/registry.json", dir+"/cache")
+	must(err)
+	must(store.Register("ubuntu-24.04", "https://cloud-images.ubuntu.com/noble.img", ""))
+	auditLog, err := audit.NewLog(dir + "/audit.log")
+	must(err)
+	signer, err := console.NewSigner(dir + "/console.key")
+	must(err)
+	queue, err := jobs.NewStore(dir + "/jobs.json")
+	must(err)
+	subs, err := subscriptions.NewStore(dir + "/subscriptions.json")
+	must(err)
+	keys, err := auth.NewStore(dir + "/keys.json")
+	must(err)
+	token, _, err := keys.Create("test", "", []string{auth.ScopeAdmin})
+	must(err)
+
+	handler := webhook.Handler(webhook.Options{
+		Manager: mgr, Store: store, Audit: auditLog, Console: signer,
+		Jobs: queue, Subs: subs, Keys: keys,
+	})
+	srv := httptest.NewServer(checkSpec(t, loadSpec(t), handler))
+	t.Cleanup(srv.Close)
+
+	client := api.NewClient(srv.URL, token)
+	client.PollInterval = 10 * time.Millisecond
+	return client, srv
+}
+
+func TestClient(t *testing.T) {
+	client, _ := newServer(t)
+	ctx := context.Background()
+
+	plans, err := client.Plans(ctx)
+	if err != nil || len(plans) == 0 {
+		t.Fatalf("Plans: %v, %v", plans, err)
+	}
+
+	vms, err := client.ListVMs(ctx)
+	if err != nil || len(vms) != 1 || vms[0].Name != "web1" {
+		t.Fatalf("ListVMs: %+v, %v", vms, err)
+	}
+
+	acc, err := client.CreateVM(ctx, api.CreateVMRequest{
+		Name: "web2", Image: "ubuntu-24.04", Password: "hunter22",
+		Labels: map[string]string{"role": "web"},
+	})
+	if err != nil || acc.JobID == "" || acc.ID != "web2" {
+		t.Fatalf("CreateVM: %+v, %v", acc, err)
+	}
+	var steps []string
+	job, err := client.WaitJob(ctx, acc.JobID, func(s api.JobStep) { steps = append(steps, s.Message) })
+	if err != nil || job.State != api.JobSucceeded || len(steps) == 0 {
+		t.Fatalf("WaitJob: %+v, %v (steps %q)", job, err, steps)
+	}
+
+	info, err := client.GetVM(ctx, "web2")
+	if err != nil || info.Record == nil || info.Record.Labels["role"] != "web" {
+		t.Fatalf("GetVM: %+v, %v", info, err)
+	}
+
+	if acc, err := client.Action(ctx, "web2", "stop", api.ActionRequest{}); err != nil || acc.JobID != "" {
+		t.Fatalf("stop: %+v, %v", acc, err)
+	}
+	if info, _ := client.GetVM(ctx, "web2"); info.State != string(vm.Stopped) {
+		t.Errorf("state after stop = %q", info.State)
+	}
+	if _, err := client.Action(ctx, "web2", "start", api.ActionRequest{}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	acc, err = client.Action(ctx, "web2", "rescue", api.ActionRequest{Password: "rescue-me"})
+	if err != nil || acc.JobID == "" {
+		t.Fatalf("rescue: %+v, %v", acc, err)
+	}
+	if _, err := client.WaitJob(ctx, acc.JobID, nil); err != nil {
+		t.Fatalf("rescue job: %v", err)
+	}
+	acc, err = client.Action(ctx, "web2", "unrescue", api.ActionRequest{})
+	if err == nil {
+		_, err = client.WaitJob(ctx, acc.JobID, nil)
+	}
+	if err != nil {
+		t.Fatalf("unrescue: %v", err)
+	}
+
+	acc, err = client.ResizeVM(ctx, "web2", api.ResizeRequest{Plan: plans[len(plans)-1].Name})
+	if err == nil {
+		_, err = client.WaitJob(ctx, acc.JobID, nil)
+	}
+	if err != nil {
+		t.Fatalf("ResizeVM: %v", err)
+	}
+
+	if metrics, err := client.Metrics(ctx, "web2"); err != nil || metrics["vcpus"] != 1 {
+		t.Fatalf("Metrics: %v, %v", metrics, err)
+	}
+	if err := client.SetLabels(ctx, "web2", map[string]string{"role": "db"}); err != nil {
+		t.Fatalf("SetLabels: %v", err)
+	}
+
+	list, err := client.ListJobs(ctx)
+	if err != nil || len(list) < 4 {
+		t.Fatalf("ListJobs: %d jobs, %v", len(list), err)
+	}
+	if job, err := client.GetJob(ctx, list[0].ID); err != nil || job.ID != list[0].ID {
+		t.Fatalf("GetJob: %+v, %v", job, err)
+	}
+
+	acc, err = client.DeleteVM(ctx, "web2")
+	if err == nil {
+		_, err = client.WaitJob(ctx, acc.JobID, nil)
+	}
+	if err != nil {
+		t.Fatalf("DeleteVM: %v", err)
+	}
+	if _, err := client.GetVM(ctx, "web2"); !errors.Is(err, api.ErrNotFound) || !api.IsCode(err, api.CodeNotFound) {
+		t.Fatalf("GetVM after delete: %v", err)
+	}
+}
+
+func TestClientImages(t *testing.T) {
+	client, _ := newServer(t)
+	ctx := context.Background()
+
+	img, err := client.RegisterImage(ctx, "debian-12", "https://cloud.debian.org/debian-12.qcow2", "qcow2")
+	if err != nil || img.Name != "debian-12" {
+		t.Fatalf("RegisterImage: %+v, %v", img, err)
+	}
+	if img, err := client.GetImage(ctx, "debian-12"); err != nil || img.URL != "https://cloud.debian.org/debian-12.qcow2" {
+		t.Fatalf("GetImage: %+v, %v", img, err)
+	}
+	if list, err := client.ListImages(ctx); err != nil || len(list) != 2 {
+		t.Fatalf("ListImages: %+v, %v", list, err)
+	}
+	if err := client.DeleteImage(ctx, "debian-12", false); err != nil {
+		t.Fatalf("DeleteImage: %v", err)
+	}
+	if _, err := client.GetImage(ctx, "debian-12"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("GetImage after delete: %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	client, srv := newServer(t)
+	ctx := context.Background()
+
+	_, err := client.CreateVM(ctx, api.CreateVMRequest{Name: "bad name!", Plan: "Gigantic"})
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != 422 || apiErr.Code != api.CodeValidation || apiErr.RequestID == "" {
+		t.Fatalf("CreateVM with bad input: %v", err)
+	}
+	fields := map[string]bool{}
+	for _, f := range apiErr.Fields {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{"name", "image", "plan"} {
+		if !fields[want] {
+			t.Errorf("no field error for %s in %+v", want, apiErr.Fields)
+		}
+	}
+
+	if _, err := client.Action(ctx, "web1", "reset-password", api.ActionRequest{}); !api.IsCode(err, api.CodeValidation) {
+		t.Errorf("reset-password without a password: %v", err)
+	}
+	if _, err := client.GetJob(ctx, "nope"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("GetJob(nope): %v", err)
+	}
+
+	anon := api.NewClient(srv.URL, "")
+	if _, err := anon.ListVMs(ctx); !errors.Is(err, api.ErrUnauthorized) || !api.IsCode(err, api.CodeUnauthorized) {
+		t.Errorf("ListVMs without a key: %v", err)
+	}
+}
+
+// TestSpecServed checks the document is public and every route in it exists
+func TestSpecServed(t *testing.T) {
+	_, srv := newServer(t)
+	spec := loadSpec(t)
+
+	resp, err := http.Get(srv.URL + api.SpecPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || !bytes.Equal(body, api.Spec) {
+		t.Fatalf("GET %s = %d, %d bytes", api.SpecPath, resp.StatusCode, len(body))
+	}
+
+	// Without a key, a route that exists answers 401 (or 200 if public);
+	// only unknown ones get the 404 envelope
+	for path, item := range spec.Paths {
+		url := srv.URL + spec.serverOf(item) + strings.NewReplacer("{id}", "x", "{name}", "x").Replace(path)
+		for method := range item {
+			method = strings.ToUpper(method)
+			if method == "SERVERS" || method == "PARAMETERS" {
+				continue
+			}
+			req, _ := http.NewRequest(method, url, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 401 && resp.StatusCode != 200 {
+				t.Errorf("%s %s = %d, want 401 or 200", method, path, resp.StatusCode)
+			}
+		}
+	}
+
+	for _, action := range webhook.VMActions {
+		if spec.Paths["/vms/{id}/"+action]["post"] == nil {
+			t.Errorf("action %s is not in the spec", action)
+		}
+	}
+}
+
+// TestSpecSchemas checks the Go types carry exactly the properties the spec names
+func TestSpecSchemas(t *testing.T) {
+	spec := loadSpec(t)
+	types := map[string]any{
+		"VM": api.VM{}, "VMInfo": api.VMInfo{}, "VMRecord": api.VMRecord{},
+		"HealthReport": api.HealthReport{}, "HealthResult": api.HealthResult{}, "HealthCheck": api.HealthCheck{},
+		"RestartPolicy": api.RestartPolicy{}, "RestartRecord": api.RestartRecord{}, "ExecResult": api.ExecResult{},
+		"Image": api.Image{}, "Plan": api.Plan{}, "Job": api.Job{}, "JobStep": api.JobStep{},
+		"Subscription": api.Subscription{}, "Delivery": api.Delivery{}, "Resources": api.Resources{},
+		"AuditEntry": api.AuditEntry{}, "Accepted": api.Accepted{}, "FieldError": api.FieldError{},
+		"CreateVMRequest": api.CreateVMRequest{}, "ActionRequest": api.ActionRequest{},
+		"RebuildRequest": api.RebuildRequest{}, "ResizeRequest": api.ResizeRequest{},
+		"RegisterImageRequest": api.RegisterImageRequest{}, "Error": api.ErrorBody{},
+		"HealthCheckStatus": api.HealthCheckStatus{}, "RestartPolicyStatus": api.RestartPolicyStatus{},
+		"ExecRequest": api.ExecRequest{}, "ConsoleToken": api.ConsoleToken{}, "Event": api.Event{},
+		"SubscriptionRequest": api.SubscriptionRequest{}, "Tenant": api.Tenant{},
+	}
+	for name, v := range types {
+		schema := spec.resolve(schemaRef(name))
+		if schema == nil {
+			t.Errorf("no schema %s", name)
+			continue
+		}
+		want := spec.properties(schema)
+		got := jsonFields(reflect.TypeOf(v))
+		if !reflect.DeepEqual(keys(want), keys(got)) {
+			t.Errorf("%s: spec has %v, Go type has %v", name, keys(want), keys(got))
+		}
+	}
+}
+
+// --- A small OpenAPI checker: enough of the spec to catch drift ---
+
+type schema = map[string]any
+
+type openAPI struct {
+	Paths      map[string]map[string]any `json:"paths"`
+	Components map[string]map[string]any `json:"components"`
+}
+
+func loadSpec(t *testing.T) *openAPI {
+	t.Helper()
+	var spec openAPI
+	if err := json.Unmarshal(api.Spec, &spec); err != nil {
+		t.Fatalf("api.Spec is not valid JSON: %v", err)
+	}
+	return &spec
+}
+
+func schemaRef(name string) schema { return schema{"$ref": "#/components/schemas/" + name} }
+
+// resolve follows $ref to components
+func (s *openAPI) resolve(sch schema) schema {
+	for sch != nil {
+		ref, ok := sch["$ref"].(string)
+		if !ok {
+			return sch
+		}
+		parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+		sch, _ = s.Components[parts[0]][parts[1]].(schema)
+	}
+	return nil
+}
+
+// serverOf is the path prefix of a path item: /api/v1 unless it overrides servers
+func (s *openAPI) serverOf(item map[string]any) string {
+	if servers, ok := item["servers"].([]any); ok {
+		return strings.TrimSuffix(servers[0].(schema)["url"].(string), "/")
+	}
+	return api.Prefix
+}
+
+// operation finds the documented operation for a request; literal segments
+// beat templated ones
+func (s *openAPI) operation(method, path string) (schema, string) {
+	best, bestPath, bestScore := schema(nil), "", -1
+	for tmpl, item := range s.Paths {
+		rest, ok := strings.CutPrefix(path, s.serverOf(item))
+		if !ok {
+			continue
+		}
+		want, got := strings.Split(tmpl, "/"), strings.Split(rest, "/")
+		if len(want) != len(got) {
+			continue
+		}
+		score := 0
+		for i := range want {
+			switch {
+			case strings.HasPrefix(want[i], "{"):
+			case want[i] == got[i]:
+				score++
+			default:
+				score = -1
+			}
+			if score < 0 {
+				break
+			}
+		}
+		op, _ := item[strings.ToLower(method)].(schema)
+		if score > bestScore && op != nil {
+			best, bestPath, bestScore = op, tmpl, score
+		}
+	}
+	return best, bestPath
+}
+
+// properties lists an object schema's properties, merging allOf
+func (s *openAPI) properties(sch schema) map[string]schema {
+	sch = s.resolve(sch)
+	props := map[string]schema{}
+	own, _ := sch["properties"].(map[string]any)
+	for name, p := range own {
+		props[name] = p.(schema)
+	}
+	if all, ok := sch["allOf"].([]any); ok {
+		for _, part := range all {
+			for name, p := range s.properties(part.(schema)) {
+				props[name] = p
+			}
+		}
+	}
+	return props
+}
+
+// validate checks a decoded JSON value; objects may not carry undocumented properties
+func (s *openAPI) validate(sch schema, v any, at string) error {
+	sch = s.resolve(sch)
+	if v == nil {
+		if sch["nullable"] == true {
+			return nil
+		}
+		// Go encodes nil slices and maps as null
+		if sch["type"] == "array" || (sch["type"] == "object" && sch["additionalProperties"] != nil) {
+			return nil
+		}
+		return fmt.Errorf("%s: null", at)
+	}
+	if _, ok := sch["allOf"]; ok {
+		sch = schema{"type": "object", "properties": toAny(s.properties(sch)), "required": s.required(sch)}
+	}
+
+	switch sch["type"] {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: %v is not a string", at, v)
+		}
+		if sch["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+		if enum, ok := sch["enum"].([]any); ok && !contains(enum, str) {
+			return fmt.Errorf("%s: %q is not one of %v", at, str, enum)
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || (sch["type"] == "integer" && n != float64(int64(n))) {
+			return fmt.Errorf("%s: %v is not an %s", at, v, sch["type"])
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: %v is not a boolean", at, v)
+		}
+	case "array":
+		list, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: %v is not an array", at, v)
+		}
+		for i, item := range list {
+			if err := s.validate(sch["items"].(schema), item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: %v is not an object", at, v)
+		}
+		for _, name := range s.required(sch) {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required %s", at, name)
+			}
+		}
+		props, _ := sch["properties"].(map[string]any)
+		extra, _ := sch["additionalProperties"].(schema)
+		for name, val := range obj {
+			p, ok := props[name].(schema)
+			switch {
+			case ok:
+			case extra != nil:
+				p = extra
+			case props == nil: // Free-form object
+				continue
+			default:
+				return fmt.Errorf("%s: undocumented property %s", at, name)
+			}
+			if err := s.validate(p, val, at+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *openAPI) required(sch schema) []string {
+	var names []string
+	for _, n := range asList(sch["required"]) {
+		names = append(names, n.(string))
+	}
+	for _, part := range asList(sch["allOf"]) {
+		names = append(names, s.required(s.resolve(part.(schema)))...)
+	}
+	return names
+}
+
+// checkSpec fails the test when a request or answer isn't what Spec documents
+func checkSpec(t *testing.T, spec *openAPI, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, tmpl := spec.operation(r.Method, r.URL.Path)
+		if op == nil {
+			t.Errorf("%s %s is not in the spec", r.Method, r.URL.Path)
+		}
+		reqBody, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		if op != nil && len(reqBody) > 0 {
+			if err := spec.checkBody(spec.resolve(asSchema(op["requestBody"])), reqBody); err != nil {
+				t.Errorf("request %s %s: %v", r.Method, tmpl, err)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+
+		if op != nil {
+			responses := op["responses"].(schema)
+			resp, ok := responses[fmt.Sprint(rec.Code)].(schema)
+			if !ok && rec.Code >= 400 { // default only documents errors
+				resp, ok = responses["default"].(schema)
+			}
+			switch {
+			case !ok:
+				t.Errorf("%s %s answered %d, which the spec doesn't list", r.Method, tmpl, rec.Code)
+			case rec.Body.Len() > 0 && strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json"):
+				if err := spec.checkBody(spec.resolve(resp), rec.Body.Bytes()); err != nil {
+					t.Errorf("response %d of %s %s: %v", rec.Code, r.Method, tmpl, err)
+				}
+			}
+		}
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
+
+// checkBody validates JSON against the application/json schema of a request body or response
+func (s *openAPI) checkBody(def schema, data []byte) error {
+	content, _ := def["content"].(schema)
+	media, ok := content["application/json"].(schema)
+	if !ok {
+		return fmt.Errorf("no application/json content documented")
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.validate(media["schema"].(schema), v, "body")
+}
+
+// jsonFields lists the JSON names encoding/json uses for a struct type
+func jsonFields(typ reflect.Type) map[string]schema {
+	fields := map[string]schema{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+			continue
+		case f.Anonymous && name == "":
+			for n := range jsonFields(f.Type) {
+				fields[n] = nil
+			}
+			continue
+		case name == "":
+			name = f.Name
+		}
+		fields[name] = nil
+	}
+	return fields
+}
+
+func keys(m map[string]schema) []string {
+	var list []string
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func toAny(m map[string]schema) map[string]any {
+	out := map[string]any{}
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func asList(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
+
+func asSchema(v any) schema {
+	s, _ := v.(schema)
+	return s
+}
+
+func contains(list []any, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
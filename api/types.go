@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types the server answers with. They are the wire format only: the
+// server converts its own structures to them, so importing this package
+// doesn't pull in the manager or its drivers.
+
+// VM is one entry of GET /api/v1/vms
+type VM struct {
+	ID     string        `json:"id"` // libvirt domain ID or name
+	Name   string        `json:"name"`
+	Status string        `json:"status"` // Hypervisor view (RUNNING, STOPPED...)
+	IP     string        `json:"ip"`
+	State  string        `json:"state"`            // Lifecycle state: provisioning, running, rebuilding...
+	Health *HealthReport `json:"health,omitempty"` // Only for VMs with a health check
+	Plan   string        `json:"plan,omitempty"`
+	Image  string        `json:"image,omitempty"`
+	Tenant string        `json:"tenant,omitempty"` // Owner
+}
+
+// VMInfo is GET /api/v1/vms/{id}: the VM with its inventory record and policies
+type VMInfo struct {
+	VM
+	Record        *VMRecord       `json:"record,omitempty"` // Nil for VMs created outside vps-manager
+	RestartPolicy RestartPolicy   `json:"restart_policy"`
+	Restarts      []RestartRecord `json:"restarts,omitempty"`
+	HealthCheck   *HealthCheck    `json:"health_check,omitempty"`
+}
+
+// VMRecord is what vps-manager remembers about a VM it created
+type VMRecord struct {
+	Name      string            `json:"name"`
+	Image     string            `json:"image"`
+	Plan      string            `json:"plan"`
+	Username  string            `json:"username"`
+	CreatedAt time.Time         `json:"created_at"`
+	RebuiltAt time.Time         `json:"rebuilt_at,omitzero"`
+	Networks  []string          `json:"networks,omitempty"`
+	SSHKeys   []string          `json:"ssh_keys,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"` // Empty for the admin tenant
+	Restart   *RestartPolicy    `json:"restart,omitempty"`
+	Restarts  []RestartRecord   `json:"restarts,omitempty"`
+	Health    *HealthCheck      `json:"health_check,omitempty"`
+}
+
+// HealthReport summarises recent probes (most recent last)
+type HealthReport struct {
+	Status              string         `json:"status"` // healthy, unhealthy, unknown
+	ConsecutiveFailures int            `json:"consecutive_failures"`
+	History             []HealthResult `json:"history"`
+}
+
+// HealthResult is the outcome of one health probe
+type HealthResult struct {
+	Time      time.Time `json:"time"`
+	OK        bool      `json:"ok"`
+	Detail    string    `json:"detail,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+}
+
+// HealthCheck is a VM's probe (body of PUT /api/v1/vms/{id}/health-check)
+type HealthCheck struct {
+	Type            string `json:"type"` // tcp, http or agent
+	Port            int    `json:"port,omitempty"`
+	Path            string `json:"path,omitempty"`
+	ExpectStatus    int    `json:"expect_status,omitempty"` // Default 200
+	IntervalSeconds int    `json:"interval_seconds"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+	RebootAfter     int    `json:"reboot_after,omitempty"` // Consecutive failures, 0 = never reboot
+}
+
+// HealthCheckStatus is the answer of /api/v1/vms/{id}/health-check
+type HealthCheckStatus struct {
+	Check  *HealthCheck  `json:"check"`
+	Report *HealthReport `json:"report"`
+}
+
+// RestartPolicy is what the daemon does when a VM stops on its own
+// (body of PUT /api/v1/vms/{id}/restart-policy)
+type RestartPolicy struct {
+	Mode           string `json:"mode"` // never, on-crash or always
+	MaxRetries     int    `json:"max_retries"`
+	BackoffSeconds int    `json:"backoff_seconds"` // Doubles with every consecutive restart
+	CrashLoop      bool   `json:"crash_loop"`      // Set when MaxRetries ran out
+}
+
+// RestartRecord is one automatic restart
+type RestartRecord struct {
+	Time    time.Time `json:"time"`
+	Reason  string    `json:"reason"`
+	Attempt int       `json:"attempt"`
+	Result  string    `json:"result"`
+}
+
+// RestartPolicyStatus is the answer of /api/v1/vms/{id}/restart-policy
+type RestartPolicyStatus struct {
+	Policy   RestartPolicy   `json:"policy"`
+	Restarts []RestartRecord `json:"restarts"`
+}
+
+// ExecRequest is the body of POST /api/v1/vms/{id}/exec
+type ExecRequest struct {
+	Path    string   `json:"path"`
+	Args    []string `json:"args,omitempty"`
+	Input   string   `json:"input,omitempty"`   // Sent to stdin
+	Timeout int      `json:"timeout,omitempty"` // Seconds
+}
+
+// ExecResult is the outcome of a guest command
+type ExecResult struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	TimedOut bool   `json:"timed_out"` // The process may still be running in the guest
+}
+
+// ConsoleToken is the answer of POST /api/v1/vms/{id}/console
+type ConsoleToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	URL       string    `json:"url"`                // Page (vnc) or websocket (serial) to open with the token
+	Password  string    `json:"password,omitempty"` // VNC password (vnc only)
+}
+
+// Image is a registered OS image
+type Image struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	LocalPath string `json:"local_path"`
+	Checksum  string `json:"checksum"`
+	Status    string `json:"status"` // PENDING, DOWNLOADING, READY, ERROR
+}
+
+// Plan is a VM size
+type Plan struct {
+	Name  string `json:"name"`
+	RAMMB int    `json:"ram_mb"`
+	CPUs  int    `json:"cpus"`
+	Disk  string `json:"disk"` // "10G"
+}
+
+// JobState is where a background job is
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// Job is a background operation
+type Job struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`   // "vm.create", "image.download"...
+	Target     string     `json:"target"` // VM or image name
+	State      JobState   `json:"state"`
+	Steps      []JobStep  `json:"steps"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobStep is one progress message
+type JobStep struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Event is one entry of the GET /api/v1/events stream
+type Event struct {
+	Type    string    `json:"type"`    // "vm.state", "image.ready"...
+	Subject string    `json:"subject"` // VM or image name
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// SubscriptionRequest is the body of POST /api/v1/subscriptions
+type SubscriptionRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // Empty = everything; "vm.*" matches a prefix
+	Secret string   `json:"secret,omitempty"` // Generated if empty
+}
+
+// Subscription is an outbound webhook
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"` // Only in the answer to POST
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is one attempt to send an event to a subscription
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	Subject        string          `json:"subject"`
+	State          string          `json:"state"` // pending, delivered, failed
+	Attempts       int             `json:"attempts"`
+	LastStatus     int             `json:"last_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Resources is an amount of capacity. In a quota, 0 means unlimited.
+type Resources struct {
+	VMs       int `json:"vms"`
+	VCPUs     int `json:"vcpus"`
+	RAMMB     int `json:"ram_mb"`
+	DiskGB    int `json:"disk_gb"`
+	PublicIPs int `json:"public_ips"`
+}
+
+// Tenant is GET /api/v1/tenant: the caller's tenant, quota and usage
+type Tenant struct {
+	Name      string    `json:"name"`
+	Quota     Resources `json:"quota"`
+	Networks  []string  `json:"networks,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Usage     Resources `json:"usage"`
+}
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time       time.Time      `json:"time"`
+	Actor      string         `json:"actor"`
+	Tenant     string         `json:"tenant,omitempty"`
+	KeyID      string         `json:"key_id,omitempty"`
+	SourceIP   string         `json:"source_ip"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Action     string         `json:"action"`
+	Target     string         `json:"target"`
+	Params     map[string]any `json:"params,omitempty"` // Secrets are redacted
+	Result     string         `json:"result"`           // "ok" or the error
+	DurationMS int64          `json:"duration_ms"`
+}
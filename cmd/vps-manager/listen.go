@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/Shaman786/vps-manager/internal/certs"
+	"github.com/Shaman786/vps-manager/internal/signing"
+	"github.com/Shaman786/vps-manager/internal/webhook"
+)
+
+// listenConfig is `vps-manager listen [flags]`
+type listenConfig struct {
+	Addr              string
+	CertFile, KeyFile string
+	ClientCA          string
+	RequireClientCert bool
+	NoTLS             bool
+	ImageSources      []string
+}
+
+func parseListenFlags(configDir string, args []string) listenConfig {
+	var c listenConfig
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	fs.StringVar(&c.Addr, "addr", ":8080", "address to listen on")
+	fs.StringVar(&c.CertFile, "tls-cert", "", "certificate (default: self-signed, created in "+filepath.Join(configDir, "tls")+")")
+	fs.StringVar(&c.KeyFile, "tls-key", "", "key of --tls-cert")
+	fs.StringVar(&c.ClientCA, "client-ca", "", "CA for client certificates (mTLS, see 'key create --cert-cn')")
+	fs.BoolVar(&c.RequireClientCert, "require-client-cert", false, "reject connections without a valid client certificate")
+	fs.BoolVar(&c.NoTLS, "no-tls", false, "serve plain HTTP (only behind a TLS-terminating proxy)")
+	sources := fs.String("image-sources", strings.Join(webhook.DefaultImageSources, ","), "domains the release webhook may download images from")
+	_ = fs.Parse(args)
+
+	for _, d := range strings.Split(*sources, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			c.ImageSources = append(c.ImageSources, d)
+		}
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		fmt.Fprintln(os.Stderr, "❌ --tls-cert and --tls-key go together")
+		os.Exit(2)
+	}
+	if c.RequireClientCert && c.ClientCA == "" {
+		fmt.Fprintln(os.Stderr, "❌ --require-client-cert needs --client-ca")
+		os.Exit(2)
+	}
+	return c
+}
+
+// serverTLS loads (or first creates) the certificate and reloads it when the
+// files change or on SIGHUP
+func serverTLS(configDir string, c listenConfig) (*tls.Config, error) {
+	if c.NoTLS {
+		return nil, nil
+	}
+	if c.CertFile == "" {
+		c.CertFile = filepath.Join(configDir, "tls", "server.crt")
+		c.KeyFile = filepath.Join(configDir, "tls", "server.key")
+		created, err := certs.EnsureSelfSigned(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			fmt.Printf("🔐 Generated a self-signed certificate: %s\n", c.CertFile)
+		}
+	}
+
+	reloader, err := certs.NewReloader(c.CertFile, c.KeyFile, c.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("🔐 TLS certificate sha256 %s\n", reloader.Fingerprint())
+	if c.ClientCA != "" {
+		fmt.Printf("🔐 Client certificates verified against %s (required: %v)\n", c.ClientCA, c.RequireClientCert)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.Reload(); err != nil {
+				fmt.Printf("⚠️  TLS reload failed, keeping the old certificate: %v\n", err)
+				continue
+			}
+			fmt.Printf("🔐 TLS certificate reloaded (sha256 %s)\n", reloader.Fingerprint())
+		}
+	}()
+	return reloader.ServerConfig(c.RequireClientCert), nil
+}
+
+// releaseVerifier checks the watcher's signed release webhooks. The secret is
+// created on first start; give the watcher the same value (VPS_WEBHOOK_SECRET).
+func releaseVerifier(configDir string) (*signing.Verifier, error) {
+	path := filepath.Join(configDir, "webhook.secret")
+	secret, created, err := signing.LoadSecret(path)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		fmt.Printf("🔏 Generated the release webhook secret: %s (set it as VPS_WEBHOOK_SECRET for the watcher)\n", path)
+	}
+	return signing.NewVerifier(secret), nil
+}
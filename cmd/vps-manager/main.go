@@ -4,14 +4,40 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/backend"
 	"github.com/Shaman786/vps-manager/internal/cli"
+	"github.com/Shaman786/vps-manager/internal/console"
 	"github.com/Shaman786/vps-manager/internal/drivers/kvm"
 	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/subscriptions"
+	"github.com/Shaman786/vps-manager/internal/tenants"
 	"github.com/Shaman786/vps-manager/internal/vm"
 	"github.com/Shaman786/vps-manager/internal/webhook"
 )
 
 func main() {
+	// 0. REMOTE MODE: --server/--context (or a saved current context) drives
+	// another host's API, so no root and no local setup is needed
+	remote, args, err := cli.Remote(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(cli.ExitUsage)
+	}
+	if len(args) > 0 && args[0] == "context" {
+		os.Exit(cli.RunContext(args[1:]))
+	}
+	if remote != nil {
+		if len(args) > 0 && (args[0] == "listen" || args[0] == "key" || args[0] == "tenant") {
+			fmt.Fprintf(os.Stderr, "❌ %s runs on the hypervisor itself, drop --server/--context\n", args[0])
+			os.Exit(cli.ExitUsage)
+		}
+		runApp(cli.NewApp(remote), args)
+		return
+	}
+
 	// 1. SYSTEM PATHS (For Root Usage)
 	// We use a central directory for all VPS data
 	baseDir := "/host-data"
@@ -43,18 +69,111 @@ func main() {
 		configDir,
 	)
 
-	// 5. Initialize Manager
-	mgr := vm.NewManager(driver)
+	// 5. Initialize Manager (the inventory remembers plan/image per VM)
+	inv, err := vm.NewInventory(configDir + "/inventory.json")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to init inventory: %v", err))
+	}
+	locks, err := vm.NewLocker(configDir+"/locks", vm.DefaultMaxHeavyOps)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to init locks: %v", err))
+	}
+	mgr := vm.NewManager(driver, inv, locks)
+
+	// 6. Tenants own VMs and API keys; quotas are checked on create/resize
+	tenantStore, err := tenants.NewStore(configDir + "/tenants.json")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to init tenants: %v", err))
+	}
+	mgr.Tenants = tenantStore
+
+	// Every change, from the API or this CLI, is recorded
+	auditLog, err := audit.NewLog(configDir + "/audit.log")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to init audit log: %v", err))
+	}
+
+	keys, err := auth.NewStore(configDir + "/api-keys.json")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to init api keys: %v", err))
+	}
+	if len(args) > 0 && args[0] == "key" {
+		os.Exit(cli.RunKeys(keys, tenantStore, auditLog, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "tenant" {
+		os.Exit(cli.RunTenants(tenantStore, mgr, keys, auditLog, args[1:]))
+	}
+
+	// 7. Check Mode: Webhook Listener?
+	if len(args) > 0 && args[0] == "listen" {
+		listen := parseListenFlags(configDir, args[1:])
+		tlsConfig, err := serverTLS(configDir, listen)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to init TLS: %v", err))
+		}
+
+		releases, err := releaseVerifier(configDir)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to init webhook secret: %v", err))
+		}
+
+		consoleSigner, err := console.NewSigner(configDir + "/console.key")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to init console signer: %v", err))
+		}
+
+		jobStore, err := jobs.NewStore(configDir + "/jobs.json")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to init job store: %v", err))
+		}
+
+		subStore, err := subscriptions.NewStore(configDir + "/subscriptions.json")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to init subscriptions: %v", err))
+		}
+		subscriptions.NewDispatcher(subStore).Start(mgr.Events)
+
+		// Follow libvirt so crashes and guest shutdowns show up without polling
+		if err := mgr.WatchDomainEvents(nil); err != nil {
+			fmt.Printf("⚠️  Not watching libvirt events: %v\n", err)
+		}
+		// Restart policies act on those events
+		mgr.StartSupervisor()
+		mgr.StartHealthChecks()
+
+		if keys.Empty() {
+			fmt.Println("⚠️  No API keys yet, every API call will be rejected. Create one with: vps-manager key create <name> --scope admin")
+		}
 
-	// 6. Check Mode: Webhook Listener?
-	if len(os.Args) > 1 && os.Args[1] == "listen" {
 		// Pass the image store to the webhook so it can register new images
-		webhook.Start(mgr, imgStore, ":8080")
+		webhook.Start(webhook.Options{
+			Addr:    listen.Addr,
+			Manager: mgr,
+			Store:   imgStore,
+			Audit:   auditLog,
+			Console: consoleSigner,
+			Jobs:    jobStore,
+			Subs:    subStore,
+			Keys:    keys,
+			Tenants: tenantStore,
+			TLS:     tlsConfig,
+
+			Releases:     releases,
+			ImageSources: listen.ImageSources,
+		})
 		return
 	}
 
-	// 7. Default Mode: Interactive CLI
-	// Pass both manager and store to the CLI
-	app := cli.NewApp(mgr, imgStore)
-	app.ShowMainMenu()
+	// 8. Commands: interactive menu (also the default) or scriptable subcommands
+	local := backend.NewLocal(mgr, imgStore)
+	local.Audit = auditLog
+	runApp(cli.NewApp(local), args)
+}
+
+func runApp(app *cli.App, args []string) {
+	if len(args) == 0 || args[0] == "menu" {
+		app.ShowMainMenu()
+		return
+	}
+	os.Exit(app.Run(args))
 }
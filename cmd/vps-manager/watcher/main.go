@@ -11,33 +11,59 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"github.com/Shaman786/vps-manager/internal/certs"
+	"github.com/Shaman786/vps-manager/internal/signing"
 )
 
+// webhookClient talks to the control plane (TLS, pinned with $VPS_TLS_FINGERPRINT)
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
 func main() {
-	// SECURITY FIX: Read IP from Environment Variable
-	serverIP := os.Getenv("VPS_IP")
-	if serverIP == "" {
-		log.Fatal("❌ CRITICAL ERROR: VPS_IP environment variable is not set.")
+	// $VPS_URL (e.g. http://hv1:8080 behind a TLS proxy) wins over $VPS_IP,
+	// which means https on the default port
+	server := strings.TrimSuffix(os.Getenv("VPS_URL"), "/")
+	if server == "" {
+		ip := os.Getenv("VPS_IP")
+		if ip == "" {
+			log.Fatal("❌ CRITICAL ERROR: neither VPS_URL nor VPS_IP environment variable is set.")
+		}
+		server = fmt.Sprintf("https://%s:8080", ip)
+	}
+	// The server only accepts releases signed with its webhook.secret
+	secret := os.Getenv("VPS_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Fatal("❌ CRITICAL ERROR: VPS_WEBHOOK_SECRET environment variable is not set.")
 	}
+	// ...and sent with an images:write key ('vps-manager key create watcher --scope images:write')
+	apiKey := os.Getenv("VPS_API_KEY")
+	if apiKey == "" {
+		log.Fatal("❌ CRITICAL ERROR: VPS_API_KEY environment variable is not set (an images:write key).")
+	}
+
+	webhookURL := server + "/webhook"
 
-	webhookURL := fmt.Sprintf("http://%s:8080/webhook", serverIP)
+	// The server's certificate is usually self-signed: pin it (listen prints the sha256)
+	if fp := os.Getenv("VPS_TLS_FINGERPRINT"); fp != "" {
+		webhookClient.Transport = &http.Transport{TLSClientConfig: certs.Pinned(fp)}
+	}
 
 	fmt.Println("🕵️  WATCHER STARTED: Scraping official mirrors...")
-	fmt.Printf("   Target Server: %s\n", serverIP)
+	fmt.Printf("   Target Server: %s\n", server)
 	fmt.Println("---------------------------------------------------")
 
 	// 1. Find Ubuntu 24.04 Latest Build
 	if url, ver, err := scrapeUbuntu("24.04"); err != nil {
 		log.Printf("❌ Ubuntu Scraping Failed: %v", err)
 	} else {
-		triggerWebhook(webhookURL, "Ubuntu", ver, url)
+		triggerWebhook(webhookURL, secret, apiKey, "Ubuntu", ver, url)
 	}
 
 	// 2. Find Rocky 9 Latest Build
 	if url, ver, err := scrapeRocky("9"); err != nil {
 		log.Printf("❌ Rocky Scraping Failed: %v", err)
 	} else {
-		triggerWebhook(webhookURL, "Rocky", ver, url)
+		triggerWebhook(webhookURL, secret, apiKey, "Rocky", ver, url)
 	}
 }
 
@@ -95,7 +121,7 @@ func fetchHTML(url string) (*goquery.Document, error) {
 	return goquery.NewDocumentFromReader(resp.Body)
 }
 
-func triggerWebhook(targetURL, distro, version, imgUrl string) {
+func triggerWebhook(targetURL, secret, apiKey, distro, version, imgUrl string) {
 	fmt.Printf("   ✅ FOUND: %s %s\n", distro, version)
 
 	payload := map[string]string{
@@ -105,7 +131,11 @@ func triggerWebhook(targetURL, distro, version, imgUrl string) {
 	}
 	data, _ := json.Marshal(payload)
 
-	resp, err := http.Post(targetURL, "application/json", bytes.NewBuffer(data))
+	req, _ := http.NewRequest(http.MethodPost, targetURL, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	signing.SetHeaders(req, secret, data)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := webhookClient.Do(req)
 	if err != nil {
 		fmt.Printf("   ❌ Webhook Failed: %v\n", err)
 		return
@@ -0,0 +1,174 @@
+// Package audit keeps an append-only JSON-lines record of who did what.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one line in the audit log
+type Entry struct {
+	Time       time.Time      `json:"time"`
+	Actor      string         `json:"actor"`                // Who asked: API key name, or "cli:<user>"
+	Tenant     string         `json:"tenant,omitempty"`     // Tenant of the API key (empty for local CLI)
+	KeyID      string         `json:"key_id,omitempty"`     // API key that authenticated the call
+	SourceIP   string         `json:"source_ip"`            // Where from (empty for local CLI)
+	RequestID  string         `json:"request_id,omitempty"` // X-Request-ID of the API call
+	Action     string         `json:"action"`               // "vm.exec", "vm.file.write"...
+	Target     string         `json:"target"`               // VM name, image name...
+	Params     map[string]any `json:"params,omitempty"`     // Secrets are redacted on write
+	Result     string         `json:"result"`               // "ok" or the error
+	DurationMS int64          `json:"duration_ms"`          // For jobs: until the job finished
+}
+
+// Redacted replaces the values of parameters that look like secrets
+const Redacted = "[redacted]"
+
+var secretWords = []string{"password", "secret", "token", "passphrase", "private"}
+
+// Log appends entries to a file. It never rewrites old lines.
+type Log struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	_ = f.Close()
+	return &Log{Path: path}, nil
+}
+
+// Record appends one entry
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Params = redact(e.Params)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Filter selects entries in Query. Empty fields match everything.
+type Filter struct {
+	Actor  string    // Substring ("alice" matches "panel (alice)")
+	Tenant string    // Exact
+	Action string    // Exact, or a prefix ending in "." ("vm." = all VM actions)
+	Target string    // Exact
+	Failed *bool     // Only failed (true) or successful (false) entries
+	Since  time.Time // Inclusive
+	Until  time.Time // Exclusive
+	Limit  int       // Keep only the most recent n (0 = all)
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case f.Actor != "" && !strings.Contains(e.Actor, f.Actor),
+		f.Tenant != "" && e.Tenant != f.Tenant,
+		f.Target != "" && e.Target != f.Target,
+		f.Failed != nil && *f.Failed != (e.Result != "ok"),
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	case f.Action == "", e.Action == f.Action:
+		return true
+	}
+	return strings.HasSuffix(f.Action, ".") && strings.HasPrefix(e.Action, f.Action)
+}
+
+// Query returns matching entries, oldest first
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	list := []Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue // A torn line from a crash shouldn't hide the rest
+		}
+		if f.match(e) {
+			list = append(list, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if f.Limit > 0 && len(list) > f.Limit {
+		list = list[len(list)-f.Limit:]
+	}
+	return list, nil
+}
+
+// LocalActor names the person running the CLI (the sudo caller, not root)
+func LocalActor() string {
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return "cli:" + u
+	}
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// redact copies params with secret-looking values replaced
+func redact(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	out := make(map[string]any, len(params))
+	for k, v := range params {
+		out[k] = v
+		if s, ok := v.(string); ok && s == "" {
+			continue // "not set" is worth keeping
+		}
+		lower := strings.ToLower(k)
+		for _, word := range secretWords {
+			if strings.Contains(lower, word) {
+				out[k] = Redacted
+				break
+			}
+		}
+		if nested, ok := v.(map[string]any); ok && out[k] != Redacted {
+			out[k] = redact(nested)
+		}
+	}
+	return out
+}
+
+// Result turns an error into the Result field
+func Result(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
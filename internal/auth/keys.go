@@ -0,0 +1,289 @@
+// Package auth issues API keys and checks them. Only a SHA-256 hash of each
+// key is stored; the key itself is shown once, when it is created.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/jsonfile"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+)
+
+// Scopes
+const (
+	ScopeRead        = "read"         // Every GET (except admin areas)
+	ScopeVMWrite     = "vm:write"     // Create/change/delete VMs, guest exec, consoles
+	ScopeImagesWrite = "images:write" // Register/pull/remove images, the /webhook beacon
+	ScopeAdmin       = "admin"        // Everything, incl. keys and subscriptions
+)
+
+var Scopes = []string{ScopeRead, ScopeVMWrite, ScopeImagesWrite, ScopeAdmin}
+
+// KeyPrefix starts every key so it's easy to spot in configs and logs
+const KeyPrefix = "vpsm_"
+
+var (
+	ErrInvalidKey = errors.New("invalid or revoked API key")
+	ErrForbidden  = errors.New("API key lacks the required scope")
+)
+
+// Key is a stored API key (without the secret)
+type Key struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`              // Who/what uses it: "billing", "alice"
+	Tenant     string    `json:"tenant,omitempty"`  // Empty = admin
+	Hash       string    `json:"hash,omitempty"`    // sha256(secret), hex
+	CertCN     string    `json:"cert_cn,omitempty"` // Or: a client certificate with this common name (mTLS)
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+}
+
+// Allows reports whether the key grants scope. admin grants everything,
+// and any write scope also allows reading. Images and subscriptions are
+// host-wide, so images:write and admin routes need a key of the admin tenant.
+func (k Key) Allows(scope string) bool {
+	if k.TenantName() != tenants.Admin && (scope == ScopeImagesWrite || scope == ScopeAdmin) {
+		return false
+	}
+	if slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope) {
+		return true
+	}
+	return scope == ScopeRead && len(k.Scopes) > 0
+}
+
+// TenantName returns the tenant the key is bound to
+func (k Key) TenantName() string {
+	if k.Tenant == "" {
+		return tenants.Admin
+	}
+	return k.Tenant
+}
+
+// Store keeps keys in a JSON file (0600). `key create/revoke` and the
+// listen daemon share it: changes re-read the file under a lock, and the
+// daemon picks up keys created or revoked since its last look.
+type Store struct {
+	Path string
+	file *jsonfile.File[Key]
+	keys map[string]Key
+	mu   sync.Mutex
+}
+
+func NewStore(path string) (*Store, error) {
+	s := &Store{Path: path, file: jsonfile.New[Key](path, 0600)}
+	keys, _, err := s.file.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api keys: %w", err)
+	}
+	s.keys = keys
+	return s, nil
+}
+
+// current returns the keys, re-read if another process changed the file
+// (caller holds the lock). On a read error it keeps the last good copy.
+func (s *Store) current() map[string]Key {
+	if keys, changed, err := s.file.Load(); err == nil && changed {
+		s.keys = keys
+	}
+	return s.keys
+}
+
+// update applies change to the keys on disk (caller holds the lock)
+func (s *Store) update(change func(keys map[string]Key) error) error {
+	keys, err := s.file.Update(change)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+// Create makes a new key and returns the secret token (the only time it exists in clear)
+func (s *Store) Create(name, tenant string, scopes []string) (string, Key, error) {
+	if err := checkKey(name, scopes); err != nil {
+		return "", Key{}, err
+	}
+	secret := randomHex(24)
+	key, err := s.add(Key{Name: name, Tenant: tenant, Hash: hash(secret), Scopes: scopes})
+	if err != nil {
+		return "", Key{}, err
+	}
+	return KeyPrefix + key.ID + "_" + secret, key, nil
+}
+
+// CreateForCert binds scopes to client certificates with the common name cn
+// (verified against the server's client CA); there is no secret to show
+func (s *Store) CreateForCert(name, tenant, cn string, scopes []string) (Key, error) {
+	if cn == "" {
+		return Key{}, fmt.Errorf("a certificate key needs a common name")
+	}
+	if err := checkKey(name, scopes); err != nil {
+		return Key{}, err
+	}
+	for _, k := range s.List() {
+		if k.CertCN == cn {
+			return Key{}, fmt.Errorf("key '%s' already uses common name %q", k.ID, cn)
+		}
+	}
+	return s.add(Key{Name: name, Tenant: tenant, CertCN: cn, Scopes: scopes})
+}
+
+func checkKey(name string, scopes []string) error {
+	if name == "" {
+		return fmt.Errorf("a key needs a name")
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("a key needs at least one scope (%s)", strings.Join(Scopes, ", "))
+	}
+	for _, sc := range scopes {
+		if !slices.Contains(Scopes, sc) {
+			return fmt.Errorf("unknown scope %q (known: %s)", sc, strings.Join(Scopes, ", "))
+		}
+	}
+	return nil
+}
+
+func (s *Store) add(key Key) (Key, error) {
+	key.ID = randomHex(6)
+	key.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.update(func(keys map[string]Key) error {
+		keys[key.ID] = key
+		return nil
+	})
+	if err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// Revoke deletes a key by ID
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func(keys map[string]Key) error {
+		if _, ok := keys[id]; !ok {
+			return fmt.Errorf("api key '%s' not found", id)
+		}
+		delete(keys, id)
+		return nil
+	})
+}
+
+// List returns all keys, oldest first
+func (s *Store) List() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.current()
+	list := make([]Key, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// OfTenant lists the keys bound to a tenant
+func (s *Store) OfTenant(tenant string) []Key {
+	var list []Key
+	for _, k := range s.List() {
+		if k.TenantName() == tenant {
+			list = append(list, k)
+		}
+	}
+	return list
+}
+
+// Empty reports whether no keys exist yet (nothing can authenticate)
+func (s *Store) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.current()) == 0
+}
+
+// Authenticate checks a "vpsm_<id>_<secret>" token
+func (s *Store) Authenticate(token string) (Key, error) {
+	rest, ok := strings.CutPrefix(token, KeyPrefix)
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.current()[id]
+	if !ok || key.Hash == "" || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash(secret))) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	return s.used(key), nil
+}
+
+// AuthenticateCert finds the key bound to a verified client certificate's common name
+func (s *Store) AuthenticateCert(cn string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.current() {
+		if cn != "" && key.CertCN == cn {
+			return s.used(key), nil
+		}
+	}
+	return Key{}, ErrInvalidKey
+}
+
+// used stamps LastUsedAt (caller holds the lock). Don't rewrite the file on
+// every request, and only touch the stamp: the file may have changed since.
+func (s *Store) used(key Key) Key {
+	if time.Since(key.LastUsedAt) > time.Minute {
+		now := time.Now()
+		_ = s.update(func(keys map[string]Key) error {
+			if k, ok := keys[key.ID]; ok { // Not revoked meanwhile
+				k.LastUsedAt = now
+				keys[key.ID] = k
+			}
+			return nil
+		})
+		key.LastUsedAt = now
+	}
+	return key
+}
+
+type ctxKey struct{}
+
+// WithKey attaches the authenticated key to a request context
+func WithKey(ctx context.Context, k Key) context.Context {
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+// FromContext returns the key that authenticated the request, if any
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(Key)
+	return k, ok
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,107 @@
+package auth
+
+import "testing"
+
+func TestKeyAllows(t *testing.T) {
+	cases := []struct {
+		name   string
+		scopes []string
+		allow  []string
+		deny   []string
+	}{
+		{"read", []string{ScopeRead}, []string{ScopeRead}, []string{ScopeVMWrite, ScopeImagesWrite, ScopeAdmin}},
+		{"vm:write", []string{ScopeVMWrite}, []string{ScopeRead, ScopeVMWrite}, []string{ScopeImagesWrite, ScopeAdmin}},
+		{"images:write", []string{ScopeImagesWrite}, []string{ScopeRead, ScopeImagesWrite}, []string{ScopeVMWrite, ScopeAdmin}},
+		{"vm and images", []string{ScopeVMWrite, ScopeImagesWrite}, []string{ScopeRead, ScopeVMWrite, ScopeImagesWrite}, []string{ScopeAdmin}},
+		{"admin", []string{ScopeAdmin}, Scopes, nil},
+		{"no scopes", nil, nil, Scopes},
+		{"unknown scope", []string{"root"}, []string{ScopeRead}, []string{ScopeVMWrite, ScopeImagesWrite, ScopeAdmin}},
+	}
+	for _, tc := range cases {
+		key := Key{Name: tc.name, Scopes: tc.scopes}
+		for _, scope := range tc.allow {
+			if !key.Allows(scope) {
+				t.Errorf("%s key: %s denied, want allowed", tc.name, scope)
+			}
+		}
+		for _, scope := range tc.deny {
+			if key.Allows(scope) {
+				t.Errorf("%s key: %s allowed, want denied", tc.name, scope)
+			}
+		}
+	}
+}
+
+// Images and subscriptions are host-wide: a tenant's key never reaches them,
+// whatever its scopes
+func TestTenantKeyAllows(t *testing.T) {
+	cases := []struct {
+		tenant string
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{"acme", []string{ScopeAdmin}, ScopeAdmin, false},
+		{"acme", []string{ScopeAdmin}, ScopeImagesWrite, false},
+		{"acme", []string{ScopeImagesWrite}, ScopeImagesWrite, false},
+		{"acme", []string{ScopeAdmin}, ScopeVMWrite, true},
+		{"acme", []string{ScopeAdmin}, ScopeRead, true},
+		{"acme", []string{ScopeVMWrite}, ScopeVMWrite, true},
+		{"acme", []string{ScopeImagesWrite}, ScopeRead, true},
+		{"admin", []string{ScopeAdmin}, ScopeAdmin, true},
+		{"admin", []string{ScopeImagesWrite}, ScopeImagesWrite, true},
+		{"", []string{ScopeAdmin}, ScopeAdmin, true}, // No tenant = admin tenant
+	}
+	for _, tc := range cases {
+		key := Key{Name: "k", Tenant: tc.tenant, Scopes: tc.scopes}
+		if got := key.Allows(tc.scope); got != tc.want {
+			t.Errorf("tenant %q with %v: Allows(%s) = %v, want %v", tc.tenant, tc.scopes, tc.scope, got, tc.want)
+		}
+	}
+}
+
+// The CLI and the daemon each hold a Store on the same file
+func TestStoreSharedFile(t *testing.T) {
+	path := t.TempDir() + "/api-keys.json"
+	daemon, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, oldKey, err := daemon.Create("ops", "", []string{ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cli, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _, err := cli.Create("billing", "", []string{ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.Authenticate(fresh); err != nil {
+		t.Fatalf("key created by another process: %v", err)
+	}
+
+	// Stamping LastUsedAt must not write back the daemon's old copy
+	if _, err := daemon.Authenticate(old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Authenticate(fresh); err != nil {
+		t.Fatalf("key lost after the daemon saved: %v", err)
+	}
+
+	if err := cli.Revoke(oldKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.Authenticate(old); err == nil {
+		t.Fatal("revoked key still accepted by the daemon")
+	}
+	if _, err := daemon.Authenticate(fresh); err != nil {
+		t.Fatal(err)
+	}
+	if len(cli.List()) != 1 {
+		t.Fatalf("revoked key came back: %v", cli.List())
+	}
+}
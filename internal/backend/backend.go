@@ -0,0 +1,183 @@
+// Package backend is what the CLI drives: the local vm.Manager (needs root on
+// the hypervisor) or a remote `vps-manager listen` server through the API client.
+// Both behave the same, so every command works in either mode.
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+var (
+	// ErrNotFound: no such VM or image
+	ErrNotFound = vm.ErrNotFound
+	// ErrConflict: the image is in use, or (remote) the VM is busy or in the wrong state.
+	// Locally the last two are vm.ErrOperationInProgress / vm.ErrInvalidTransition.
+	ErrConflict = errors.New("conflict")
+	// ErrUnauthorized: (remote) the API key is missing, revoked or lacks the scope
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Progress receives step messages of long operations (may be nil)
+type Progress func(step string)
+
+type Backend interface {
+	ListVMs() ([]core.VMState, error)
+	VMInfo(name string) (vm.Info, error)
+	Metrics(name string) (map[string]float64, error) // Raw counters, see core.HypervisorDriver.GetMetrics
+	CreateVM(opts vm.CreateOptions) error            // Progress goes to opts.Progress
+	RebuildVM(name, image, password string, progress Progress) error
+	ResizeVM(name, plan string, progress Progress) error
+	Action(name, action string, params vm.ActionParams, progress Progress) error
+	SetLabels(name string, labels map[string]string) error
+
+	ListImages() ([]images.ImageInfo, error)
+	RegisterImage(name, url, checksum string) error
+	PullImage(name string, progress Progress) error
+	RemoveImage(name string, force bool) error
+
+	Plans() ([]plans.VMPlan, error)
+}
+
+// Local runs everything in this process (root on the hypervisor)
+type Local struct {
+	Manager *vm.Manager
+	Images  *images.Store
+	Audit   *audit.Log // Optional: changes are recorded here
+	Actor   string     // Who to record, "cli:<user>"
+}
+
+func NewLocal(mgr *vm.Manager, store *images.Store) *Local {
+	return &Local{Manager: mgr, Images: store, Actor: audit.LocalActor()}
+}
+
+func (l *Local) ListVMs() ([]core.VMState, error) {
+	return l.Manager.ListServers()
+}
+
+func (l *Local) VMInfo(name string) (vm.Info, error) {
+	return l.Manager.Info(name)
+}
+
+func (l *Local) Metrics(name string) (map[string]float64, error) {
+	return l.Manager.Metrics(name)
+}
+
+func (l *Local) CreateVM(opts vm.CreateOptions) (err error) {
+	defer l.record(time.Now(), "vm.create", opts.Name, map[string]any{"image": opts.Image, "plan": opts.PlanName, "username": opts.Username,
+		"password": opts.Password, "networks": opts.Networks, "ssh_keys": len(opts.SSHKeys), "labels": opts.Labels}, &err)
+	return l.Manager.CreateServer(opts)
+}
+
+func (l *Local) RebuildVM(name, image, password string, progress Progress) (err error) {
+	defer l.record(time.Now(), "vm.rebuild", name, map[string]any{"image": image, "password": password}, &err)
+	if err := l.exists(name); err != nil {
+		return err
+	}
+	return l.Manager.RebuildServer(name, image, password)
+}
+
+func (l *Local) ResizeVM(name, plan string, progress Progress) (err error) {
+	defer l.record(time.Now(), "vm.resize", name, map[string]any{"plan": plan}, &err)
+	if err := l.exists(name); err != nil {
+		return err
+	}
+	return l.Manager.ResizeServer(name, plan)
+}
+
+func (l *Local) Action(name, action string, params vm.ActionParams, progress Progress) (err error) {
+	defer l.record(time.Now(), "vm."+action, name, params.Audit(), &err)
+	if err := l.exists(name); err != nil {
+		return err
+	}
+	return l.Manager.PerformActionWithParams(name, action, params)
+}
+
+func (l *Local) SetLabels(name string, labels map[string]string) (err error) {
+	defer l.record(time.Now(), "vm.labels", name, map[string]any{"labels": labels}, &err)
+	if err := l.exists(name); err != nil {
+		return err
+	}
+	return l.Manager.SetLabels(name, labels)
+}
+
+func (l *Local) ListImages() ([]images.ImageInfo, error) {
+	return l.Images.List(), nil
+}
+
+func (l *Local) RegisterImage(name, url, checksum string) (err error) {
+	defer l.record(time.Now(), "image.register", name, map[string]any{"url": url, "format": checksum}, &err)
+	return l.Images.Register(name, url, checksum)
+}
+
+func (l *Local) PullImage(name string, progress Progress) (err error) {
+	defer l.record(time.Now(), "image.pull", name, nil, &err)
+	if err := l.imageExists(name); err != nil {
+		return err
+	}
+	waiting := func() {
+		if progress != nil {
+			progress("host is busy, waiting for a free download slot")
+		}
+	}
+	return l.Manager.RunHeavy(waiting, func() error { _, err := l.Images.Resolve(name); return err })
+}
+
+func (l *Local) RemoveImage(name string, force bool) (err error) {
+	defer l.record(time.Now(), "image.delete", name, map[string]any{"force": force}, &err)
+	if err := l.imageExists(name); err != nil {
+		return err
+	}
+	if err := CheckImageUnused(l.Manager, name, force); err != nil {
+		return err
+	}
+	return l.Images.Remove(name)
+}
+
+func (l *Local) Plans() ([]plans.VMPlan, error) {
+	return plans.Available, nil
+}
+
+// CheckImageUnused refuses to remove an image VM disks are layered on (unless forced)
+func CheckImageUnused(mgr *vm.Manager, name string, force bool) error {
+	if users := mgr.ImageUsers(name); len(users) > 0 && !force {
+		return fmt.Errorf("%w: image '%s' is the backing image of %v; rebuild them first or force it", ErrConflict, name, users)
+	}
+	return nil
+}
+
+// record writes the audit entry of a change made from this host
+func (l *Local) record(start time.Time, action, target string, params map[string]any, err *error) {
+	if l.Audit == nil {
+		return
+	}
+	_ = l.Audit.Record(audit.Entry{
+		Actor:      l.Actor,
+		Action:     action,
+		Target:     target,
+		Params:     params,
+		Result:     audit.Result(*err),
+		DurationMS: time.Since(start).Milliseconds(),
+	})
+}
+
+func (l *Local) exists(name string) error {
+	if l.Manager.State(name) == vm.Deleted {
+		return fmt.Errorf("vm '%s': %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+func (l *Local) imageExists(name string) error {
+	if _, err := l.Images.Get(name); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package certs gives the control plane its TLS: a self-signed certificate
+// on first start, certificates that reload when their files change, and
+// optional client certificates (mTLS).
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SelfSignedValidity is how long a generated certificate lasts
+const SelfSignedValidity = 2 * 365 * 24 * time.Hour
+
+// EnsureSelfSigned writes a self-signed certificate for this host unless
+// certFile already exists. It reports whether it created one.
+func EnsureSelfSigned(certFile, keyFile string) (bool, error) {
+	if _, err := os.Stat(certFile); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return false, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	hostname, _ := os.Hostname()
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{"vps-manager"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true, // So clients can trust it directly with --ca-cert
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           hostIPs(),
+	}
+	if hostname != "" && hostname != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, hostname)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", keyFile, err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", certFile, err)
+	}
+	return true, nil
+}
+
+// Fingerprint is the SHA-256 of a certificate (hex, what clients pin)
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileFingerprint reads a PEM certificate and returns its fingerprint
+func FileFingerprint(certFile string) (string, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("%s: no PEM certificate", certFile)
+	}
+	return Fingerprint(block.Bytes), nil
+}
+
+// Pinned returns a client config that only accepts a server certificate
+// with this fingerprint (no CA needed, works with self-signed certificates)
+func Pinned(fingerprint string) *tls.Config {
+	want := strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true, // Replaced by the pin check below
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) > 0 && Fingerprint(rawCerts[0]) == want {
+				return nil
+			}
+			return fmt.Errorf("server certificate doesn't match the pinned fingerprint")
+		},
+	}
+}
+
+// ClientConfig builds a client TLS config: trust caFile (or pin a
+// fingerprint instead) and present certFile/keyFile for mTLS. Empty
+// arguments are skipped; with all empty it returns nil (system defaults).
+func ClientConfig(caFile, fingerprint, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && fingerprint == "" && certFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if fingerprint != "" {
+		cfg = Pinned(fingerprint)
+	} else if caFile != "" {
+		pool, err := loadPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func loadPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA %s: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates", caFile)
+	}
+	return pool, nil
+}
+
+// hostIPs lists loopback and interface addresses for the certificate
+func hostIPs() []net.IP {
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,127 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadCheckInterval is how often handshakes look at the files' mtimes
+const ReloadCheckInterval = 10 * time.Second
+
+// Reloader serves a certificate (and client CA) that follow their files:
+// replace them (e.g. certbot renew) and new connections pick them up.
+type Reloader struct {
+	CertFile, KeyFile string
+	ClientCAFile      string // Optional: verify client certificates against it (mTLS)
+
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	checked   time.Time
+	mu        sync.Mutex
+}
+
+func NewReloader(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+	r := &Reloader{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files now. On error the previous certificate stays in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.ClientCAFile != "" {
+		if pool, err = loadPool(r.ClientCAFile); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.clientCAs = &cert, pool
+	r.modTimes = r.stat()
+	r.checked = time.Now()
+	return nil
+}
+
+// Fingerprint of the certificate currently served
+func (r *Reloader) Fingerprint() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Fingerprint(r.cert.Certificate[0])
+}
+
+// ServerConfig returns a config for http.Server. With a client CA, client
+// certificates are verified if sent (or always, with requireClientCert);
+// API keys keep working for clients without one unless it's required.
+func (r *Reloader) ServerConfig(requireClientCert bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.maybeReload()
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				if requireClientCert {
+					cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// maybeReload reloads if a file changed since the last look
+func (r *Reloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.checked) < ReloadCheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	changed := false
+	for path, mod := range r.stat() {
+		if !mod.Equal(r.modTimes[path]) {
+			changed = true
+		}
+	}
+	r.mu.Unlock()
+
+	if changed {
+		if err := r.Reload(); err != nil {
+			fmt.Printf("⚠️  TLS reload failed, keeping the old certificate: %v\n", err)
+			return
+		}
+		fmt.Printf("🔐 TLS certificate reloaded (sha256 %s)\n", r.Fingerprint())
+	}
+}
+
+func (r *Reloader) stat() map[string]time.Time {
+	mods := map[string]time.Time{}
+	for _, path := range []string{r.CertFile, r.KeyFile, r.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			mods[path] = info.ModTime()
+		}
+	}
+	return mods
+}
@@ -11,7 +11,7 @@ import (
 
 // ... (ListVMs remains same) ...
 func (a *App) handleListVMs() {
-	vms, err := a.mgr.ListServers()
+	vms, err := a.backend.ListVMs()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
@@ -59,7 +59,7 @@ func (a *App) handleCreateVM() {
 
 	fmt.Printf("\n🚀 Creating %s (%s) on %s...\n", name, plan, image)
 
-	if err := a.mgr.CreateServer(opts); err != nil {
+	if err := a.backend.CreateVM(opts); err != nil {
 		fmt.Printf("❌ Failed: %v\n", err)
 	} else {
 		fmt.Println("✅ VM Created Successfully!")
@@ -73,17 +73,71 @@ func (a *App) handleControlVM() {
 	name, _ := reader.ReadString('\n')
 	name = strings.TrimSpace(name)
 
-	fmt.Print("Action (start/stop/reboot/delete): ")
+	fmt.Print("Action (start/stop/reboot/delete/rescue/unrescue/reset-password/add-ssh-key): ")
 	action, _ := reader.ReadString('\n')
 	action = strings.TrimSpace(action)
 
-	if err := a.mgr.PerformAction(name, action); err != nil {
+	var params vm.ActionParams
+	switch action {
+	case "reset-password", "add-ssh-key":
+		fmt.Print("Guest User (default: the VM's user): ")
+		params.Username, _ = reader.ReadString('\n')
+		params.Username = strings.TrimSpace(params.Username)
+
+		if action == "reset-password" {
+			fmt.Print("New Password: ")
+			params.Password, _ = reader.ReadString('\n')
+			params.Password = strings.TrimSpace(params.Password)
+		} else {
+			fmt.Print("SSH Public Key: ")
+			params.SSHKey, _ = reader.ReadString('\n')
+			params.SSHKey = strings.TrimSpace(params.SSHKey)
+		}
+	case "rescue":
+		fmt.Print("Temporary Root Password (optional): ")
+		params.Password, _ = reader.ReadString('\n')
+		params.Password = strings.TrimSpace(params.Password)
+
+		fmt.Print("Temporary SSH Public Key (optional): ")
+		params.SSHKey, _ = reader.ReadString('\n')
+		params.SSHKey = strings.TrimSpace(params.SSHKey)
+	}
+
+	if err := a.backend.Action(name, action, params, printStep); err != nil {
 		fmt.Printf("❌ Failed: %v\n", err)
 	} else {
 		fmt.Println("✅ Action completed.")
 	}
 }
 
+func (a *App) handleRebuildVM() {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("VM Name: ")
+	name, _ := reader.ReadString('\n')
+	name = strings.TrimSpace(name)
+
+	fmt.Print("New Image (e.g., debian-12): ")
+	image, _ := reader.ReadString('\n')
+	image = strings.TrimSpace(image)
+
+	fmt.Print("New Root Password: ")
+	pass, _ := reader.ReadString('\n')
+	pass = strings.TrimSpace(pass)
+
+	fmt.Printf("\n⚠️  All data on %s's root disk will be wiped. Type the VM name to confirm: ", name)
+	confirm, _ := reader.ReadString('\n')
+	if strings.TrimSpace(confirm) != name {
+		fmt.Println("Aborted.")
+		return
+	}
+
+	if err := a.backend.RebuildVM(name, image, pass, printStep); err != nil {
+		fmt.Printf("❌ Failed: %v\n", err)
+	} else {
+		fmt.Println("✅ VM Rebuilt!")
+	}
+}
+
 func (a *App) handleDownloadImage() {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Print("Logical Name (e.g., ubuntu-24.04): ")
@@ -95,13 +149,18 @@ func (a *App) handleDownloadImage() {
 	url = strings.TrimSpace(url)
 
 	fmt.Println("Registering and Fetching...")
-	if err := a.store.Register(name, url, ""); err != nil {
+	if err := a.backend.RegisterImage(name, url, ""); err != nil {
 		fmt.Printf("❌ Registration Failed: %v\n", err)
 		return
 	}
-	if _, err := a.store.Resolve(name); err != nil {
+	if err := a.backend.PullImage(name, printStep); err != nil {
 		fmt.Printf("❌ Download Failed: %v\n", err)
 	} else {
 		fmt.Println("✅ Image Ready!")
 	}
 }
+
+// printStep shows progress of long operations
+func printStep(step string) {
+	fmt.Printf("   ⏳ %s\n", step)
+}
@@ -3,21 +3,15 @@ package cli
 import (
 	"fmt"
 
-	"github.com/Shaman786/vps-manager/internal/images"
-	"github.com/Shaman786/vps-manager/internal/vm"
+	"github.com/Shaman786/vps-manager/internal/backend"
 )
 
 type App struct {
-	mgr   *vm.Manager
-	store *images.Store // Added Store here
+	backend backend.Backend // Local manager or a remote server, commands don't care
 }
 
-// Update Constructor to accept store
-func NewApp(mgr *vm.Manager, store *images.Store) *App {
-	return &App{
-		mgr:   mgr,
-		store: store,
-	}
+func NewApp(b backend.Backend) *App {
+	return &App{backend: b}
 }
 
 func (a *App) ShowMainMenu() {
@@ -25,9 +19,10 @@ func (a *App) ShowMainMenu() {
 		fmt.Println("\n--- HOST-PALACE VPS MANAGER ---")
 		fmt.Println("1. List VMs")
 		fmt.Println("2. Create VM")
-		fmt.Println("3. Control VM (Start/Stop/Reboot)")
-		fmt.Println("4. Download/Register Image")
-		fmt.Println("5. Exit")
+		fmt.Println("3. Control VM (Start/Stop/Reboot/Rescue)")
+		fmt.Println("4. Rebuild VM (Reinstall OS)")
+		fmt.Println("5. Download/Register Image")
+		fmt.Println("6. Exit")
 		fmt.Print("Select: ")
 
 		var choice string
@@ -41,8 +36,10 @@ func (a *App) ShowMainMenu() {
 		case "3":
 			a.handleControlVM()
 		case "4":
-			a.handleDownloadImage()
+			a.handleRebuildVM()
 		case "5":
+			a.handleDownloadImage()
+		case "6":
 			return
 		default:
 			fmt.Println("Invalid choice")
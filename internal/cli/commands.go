@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/tui"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// Exit codes of the non-interactive commands
+const (
+	ExitOK       = 0
+	ExitError    = 1 // The operation failed
+	ExitUsage    = 2 // Bad flags/arguments
+	ExitNotFound = 3 // No such VM/image
+	ExitConflict = 4 // VM busy or in the wrong state for the action, or image in use
+	ExitDenied   = 5 // (remote) Missing/invalid API key, not enough scope, or over quota
+)
+
+// usageError marks errors that should exit with ExitUsage
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+const usage = `Usage: vps-manager <command> [flags]
+
+  vm list                          List VMs
+  vm info <name>                   Details, restart policy and health
+  vm create <name> --image ...     Create a VM (see vps-manager vm create -h)
+  vm start|stop|reboot <name>
+  vm delete <name> --yes
+  vm rebuild <name> --image <img> --password <pw>
+  vm resize <name> --plan <plan>
+  vm rescue|unrescue <name>
+  image list
+  image register <name> <url>
+  image pull <name>                Download a registered image now
+  image rm <name>
+  plan list                        Available VM plans
+  plan -f fleet.yaml               Diff a fleet spec against the host
+  apply -f fleet.yaml              Make the host match a fleet spec
+  menu                             Interactive menu
+  tui                              Full-screen dashboard
+  listen [--addr :8080] [--tls-cert f --tls-key f] [--client-ca f [--require-client-cert]] [--no-tls]
+         [--image-sources domain,...]
+                                   Run the API server (HTTPS, self-signed by default)
+  context list|current             Saved servers
+  context add <name> --server <url> [--token <t>] [--ca-cert f | --fingerprint sha256]
+              [--client-cert f --client-key f] [--use]
+  context use <name>|local
+  context rm <name>
+  key create <name> --scope <s>    API key for 'listen' (read, vm:write, images:write, admin)
+  key list
+  key revoke <id>
+  tenant create|set <name> [--max-vms N --max-vcpus N --max-ram-mb N --max-disk-gb N --max-public-ips N --network br0]
+  tenant list                      Tenants with usage/quota
+  tenant rm <name>
+
+Most commands take --output table|json|yaml (-o).
+Global: --server <url> --token <t> or --context <name> to drive a remote
+'vps-manager listen' instead of this host (token also from $VPS_MANAGER_TOKEN).
+--ca-cert, --fingerprint, --client-cert and --client-key override the context's TLS settings.
+`
+
+// Run executes a non-interactive command and returns the process exit code
+func (a *App) Run(args []string) int {
+	return a.run(args, os.Stdout, os.Stderr)
+}
+
+func (a *App) run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+	}
+
+	var err error
+	switch args[0] {
+	case "vm":
+		err = a.vmCommand(args[1:], stdout, stderr)
+	case "image":
+		err = a.imageCommand(args[1:], stdout, stderr)
+	case "plan":
+		if len(args) > 1 && args[1] == "list" {
+			err = a.planList(args[2:], stdout, stderr)
+		} else {
+			err = a.fleetCommand("plan", args[1:], stdout, stderr)
+		}
+	case "apply":
+		err = a.fleetCommand("apply", args[1:], stdout, stderr)
+	case "tui", "dashboard":
+		err = tui.New(a.backend).Run()
+	default:
+		err = usagef("unknown command %q", args[0])
+	}
+
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	fmt.Fprintf(stderr, "❌ %v\n", err)
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	var ue usageError
+	switch {
+	case errors.As(err, &ue):
+		return ExitUsage
+	case errors.Is(err, backend.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, backend.ErrConflict), errors.Is(err, vm.ErrOperationInProgress), errors.Is(err, vm.ErrInvalidTransition):
+		return ExitConflict
+	case errors.Is(err, backend.ErrUnauthorized):
+		return ExitDenied
+	}
+	return ExitError
+}
+
+// flags returns a flag set with the shared --output/-o flag
+func flags(name string, stderr io.Writer) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", OutputTable, "table, json or yaml")
+	fs.StringVar(output, "o", OutputTable, "shorthand for --output")
+	return fs, output
+}
+
+// parse accepts flags before and after positional arguments ("vm start web1 -o json")
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err.Error()}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// listFlag collects a repeatable flag (--network a --network b)
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+// labelFlag collects repeatable key=value pairs
+type labelFlag map[string]string
+
+func (l labelFlag) String() string { return fmt.Sprint(map[string]string(l)) }
+func (l labelFlag) Set(v string) error {
+	k, val, ok := strings.Cut(v, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label must be key=value")
+	}
+	l[k] = val
+	return nil
+}
+
+// stepTo prints progress of long operations (stderr, so -o json stays parseable)
+func stepTo(w io.Writer) backend.Progress {
+	return func(step string) { fmt.Fprintf(w, "   %s\n", step) }
+}
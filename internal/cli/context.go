@@ -0,0 +1,319 @@
+package cli
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/certs"
+	"github.com/Shaman786/vps-manager/internal/client"
+)
+
+// LocalContext is the reserved context name for "drive this host directly"
+const LocalContext = "local"
+
+// Context is a saved server (like a kubectl context)
+type Context struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+
+	// TLS: trust CACert (e.g. the server's self-signed server.crt) or pin the
+	// server certificate's sha256 Fingerprint; ClientCert/ClientKey for mTLS
+	CACert      string `json:"ca_cert,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	ClientCert  string `json:"client_cert,omitempty"`
+	ClientKey   string `json:"client_key,omitempty"`
+}
+
+// tlsConfig builds the client TLS settings (nil = system defaults)
+func (c Context) tlsConfig() (*tls.Config, error) {
+	if c.ClientCert != "" && c.ClientKey == "" {
+		return nil, usagef("--client-cert needs --client-key")
+	}
+	return certs.ClientConfig(c.CACert, c.Fingerprint, c.ClientCert, c.ClientKey)
+}
+
+// Config is ~/.vps-manager/config.json
+type Config struct {
+	Current  string             `json:"current,omitempty"`
+	Contexts map[string]Context `json:"contexts"`
+}
+
+// ConfigPath is where contexts are saved ($VPS_MANAGER_CONFIG overrides it)
+func ConfigPath() string {
+	if p := os.Getenv("VPS_MANAGER_CONFIG"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".vps-manager/config.json"
+	}
+	return filepath.Join(home, ".vps-manager", "config.json")
+}
+
+func LoadConfig() (*Config, error) {
+	cfg := &Config{Contexts: map[string]Context{}}
+	data, err := os.ReadFile(ConfigPath())
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ConfigPath(), err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ConfigPath(), err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = map[string]Context{}
+	}
+	return cfg, nil
+}
+
+// Save writes the config readable by the owner only (it holds tokens)
+func (c *Config) Save() error {
+	path := ConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(c, "", "  ")
+	return os.WriteFile(path, data, 0600)
+}
+
+// Remote strips the global --server/--token/--context (and TLS) flags from
+// args and returns a client for the selected server, or nil to work on this
+// host. Order: --server, then --context, then the current context.
+func Remote(args []string) (backend.Backend, []string, error) {
+	var server, token, context string
+	var flagTLS Context
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if len(rest) == 0 && args[i] == "context" {
+			// `context add --server ...` keeps its own flags
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(args[i], "=")
+		var target *string
+		switch name {
+		case "--server":
+			target = &server
+		case "--token":
+			target = &token
+		case "--context":
+			target = &context
+		case "--ca-cert":
+			target = &flagTLS.CACert
+		case "--fingerprint":
+			target = &flagTLS.Fingerprint
+		case "--client-cert":
+			target = &flagTLS.ClientCert
+		case "--client-key":
+			target = &flagTLS.ClientKey
+		default:
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, usagef("%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		*target = value
+	}
+
+	selected := Context{}
+	if server == "" {
+		cfg, err := LoadConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		if context == "" {
+			context = cfg.Current
+		}
+		if context != "" && context != LocalContext {
+			ctx, ok := cfg.Contexts[context]
+			if !ok {
+				return nil, nil, usagef("unknown context %q (see vps-manager context list)", context)
+			}
+			server = ctx.Server
+			if token == "" {
+				token = ctx.Token
+			}
+			selected = ctx
+		}
+	}
+	if server == "" {
+		return nil, rest, nil
+	}
+	if token == "" {
+		token = os.Getenv("VPS_MANAGER_TOKEN")
+	}
+	// Flags override the context's TLS settings one by one
+	for _, f := range []struct{ flag, saved *string }{
+		{&flagTLS.CACert, &selected.CACert}, {&flagTLS.Fingerprint, &selected.Fingerprint},
+		{&flagTLS.ClientCert, &selected.ClientCert}, {&flagTLS.ClientKey, &selected.ClientKey},
+	} {
+		if *f.flag != "" {
+			*f.saved = *f.flag
+		}
+	}
+	tlsCfg, err := selected.tlsConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.New(server, token).WithTLS(tlsCfg), rest, nil
+}
+
+// RunContext handles `vps-manager context ...` (no backend needed)
+func RunContext(args []string) int {
+	err := contextCommand(args, os.Stdout, os.Stderr)
+	if err == nil {
+		return ExitOK
+	}
+	fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+	return exitCode(err)
+}
+
+func contextCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return usagef("usage: vps-manager context list|current|add|use|rm")
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	cmd, args := args[0], args[1:]
+
+	switch cmd {
+	case "list", "ls":
+		fs, output := flags("context list", stderr)
+		if _, err := parse(fs, args); err != nil {
+			return err
+		}
+		p, err := newPrinter(stdout, *output)
+		if err != nil {
+			return usageError{err.Error()}
+		}
+		type entry struct {
+			Name    string `json:"name"`
+			Server  string `json:"server"`
+			Current bool   `json:"current"`
+		}
+		list := []entry{{Name: LocalContext, Server: "(this host)", Current: cfg.Current == "" || cfg.Current == LocalContext}}
+		var rows [][]string
+		for _, name := range slices.Sorted(maps.Keys(cfg.Contexts)) {
+			list = append(list, entry{Name: name, Server: cfg.Contexts[name].Server, Current: cfg.Current == name})
+		}
+		for _, e := range list {
+			mark := ""
+			if e.Current {
+				mark = "*"
+			}
+			rows = append(rows, []string{mark, e.Name, e.Server})
+		}
+		return p.print(list, []string{"CURRENT", "NAME", "SERVER"}, rows)
+
+	case "current":
+		if cfg.Current == "" {
+			fmt.Fprintln(stdout, LocalContext)
+		} else {
+			fmt.Fprintln(stdout, cfg.Current)
+		}
+		return nil
+
+	case "add":
+		fs, _ := flags("context add", stderr)
+		server := fs.String("server", "", "API URL, e.g. https://host:8080")
+		token := fs.String("token", "", "API token")
+		caCert := fs.String("ca-cert", "", "CA (or the server's self-signed certificate) to trust")
+		fingerprint := fs.String("fingerprint", "", "pin the server certificate's sha256 instead")
+		clientCert := fs.String("client-cert", "", "client certificate for mTLS")
+		clientKey := fs.String("client-key", "", "key of the client certificate")
+		use := fs.Bool("use", false, "make it the current context")
+		pos, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		name, err := oneName("context add", pos)
+		if err != nil {
+			return err
+		}
+		if name == LocalContext {
+			return usagef("%q is reserved for this host", LocalContext)
+		}
+		if *server == "" {
+			return usagef("context add needs --server")
+		}
+		ctx := Context{Server: strings.TrimRight(*server, "/"), Token: *token,
+			CACert: absPath(*caCert), Fingerprint: *fingerprint, ClientCert: absPath(*clientCert), ClientKey: absPath(*clientKey)}
+		if _, err := ctx.tlsConfig(); err != nil {
+			return err
+		}
+		cfg.Contexts[name] = ctx
+		if *use {
+			cfg.Current = name
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "✅ Saved context %s\n", name)
+		return nil
+
+	case "use":
+		name, err := oneName("context use", args)
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Contexts[name]; !ok && name != LocalContext {
+			return fmt.Errorf("context %q: %w", name, backend.ErrNotFound)
+		}
+		cfg.Current = name
+		if name == LocalContext {
+			cfg.Current = ""
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "✅ Using %s\n", name)
+		return nil
+
+	case "rm":
+		name, err := oneName("context rm", args)
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Contexts[name]; !ok {
+			return fmt.Errorf("context %q: %w", name, backend.ErrNotFound)
+		}
+		delete(cfg.Contexts, name)
+		if cfg.Current == name {
+			cfg.Current = ""
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "✅ Removed context %s\n", name)
+		return nil
+	}
+	return usagef("unknown context command %q", cmd)
+}
+
+// absPath keeps saved file paths working from any directory
+func absPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
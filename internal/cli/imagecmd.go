@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+)
+
+func (a *App) imageCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return usagef("usage: vps-manager image list|register|pull|rm")
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list", "ls":
+		return a.imageList(args, stdout, stderr)
+	case "register":
+		return a.imageRegister(args, stdout, stderr)
+	case "pull":
+		return a.imagePull(args, stdout, stderr)
+	case "rm":
+		return a.imageRemove(args, stdout, stderr)
+	}
+	return usagef("unknown image command %q", cmd)
+}
+
+func (a *App) imageList(args []string, stdout, stderr io.Writer) error {
+	fs, output := flags("image list", stderr)
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	p, err := newPrinter(stdout, *output)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	list, err := a.backend.ListImages()
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, img := range list {
+		rows = append(rows, []string{img.Name, img.Status, img.URL})
+	}
+	return p.print(list, []string{"NAME", "STATUS", "URL"}, rows)
+}
+
+func (a *App) imageRegister(args []string, stdout, stderr io.Writer) error {
+	fs, _ := flags("image register", stderr)
+	checksum := fs.String("checksum", "", "expected checksum")
+	pull := fs.Bool("pull", false, "download it right away")
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usagef("usage: vps-manager image register <name> <url> [--pull]")
+	}
+	if err := a.backend.RegisterImage(pos[0], pos[1], *checksum); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "✅ Registered %s\n", pos[0])
+	if *pull {
+		return a.pull(pos[0], stdout, stderr)
+	}
+	return nil
+}
+
+func (a *App) imagePull(args []string, stdout, stderr io.Writer) error {
+	fs, _ := flags("image pull", stderr)
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, err := oneName("image pull", pos)
+	if err != nil {
+		return err
+	}
+	return a.pull(name, stdout, stderr)
+}
+
+func (a *App) pull(name string, stdout, stderr io.Writer) error {
+	if err := a.backend.PullImage(name, stepTo(stderr)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "✅ %s is ready\n", name)
+	return nil
+}
+
+func (a *App) imageRemove(args []string, stdout, stderr io.Writer) error {
+	fs, _ := flags("image rm", stderr)
+	force := fs.Bool("force", false, "remove even if VMs were installed from it")
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, err := oneName("image rm", pos)
+	if err != nil {
+		return err
+	}
+	// VM disks are overlays on the cached image: the backend refuses unless forced
+	if err := a.backend.RemoveImage(name, *force); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "✅ Removed %s\n", name)
+	return nil
+}
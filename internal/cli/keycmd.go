@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+)
+
+// RunKeys manages the API keys of this host's 'listen' server. It works on
+// the key file directly, so it's local only (run it as root on the hypervisor).
+func RunKeys(keys *auth.Store, ts *tenants.Store, log *audit.Log, args []string) int {
+	err := keyCommand(keys, ts, log, args, os.Stdout, os.Stderr)
+	if err == nil {
+		return ExitOK
+	}
+	fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+	return exitCode(err)
+}
+
+func keyCommand(keys *auth.Store, ts *tenants.Store, log *audit.Log, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return usagef("usage: vps-manager key create|list|revoke")
+	}
+	cmd, args := args[0], args[1:]
+
+	switch cmd {
+	case "create":
+		fs, output := flags("key create", stderr)
+		var scopes listFlag
+		fs.Var(&scopes, "scope", "repeatable or comma separated: "+strings.Join(auth.Scopes, ", "))
+		tenant := fs.String("tenant", tenants.Admin, "tenant the key is bound to (it only sees that tenant's VMs)")
+		certCN := fs.String("cert-cn", "", "authenticate client certificates with this common name instead of a token (mTLS)")
+		pos, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		if len(pos) != 1 {
+			return usagef("usage: vps-manager key create <name> --scope <scope>[,<scope>...]")
+		}
+		var split []string
+		for _, s := range scopes {
+			split = append(split, strings.Split(s, ",")...)
+		}
+		if _, err := ts.Get(*tenant); err != nil {
+			return usageError{err.Error()}
+		}
+		if *tenant == tenants.Admin {
+			*tenant = ""
+		}
+		var token string
+		var key auth.Key
+		if *certCN != "" {
+			key, err = keys.CreateForCert(pos[0], *tenant, *certCN, split)
+		} else {
+			token, key, err = keys.Create(pos[0], *tenant, split)
+		}
+		recordLocal(log, "key.create", pos[0], map[string]any{"id": key.ID, "tenant": *tenant, "scopes": split, "cert_cn": *certCN}, err)
+		if err != nil {
+			return usageError{err.Error()}
+		}
+		if *output != OutputTable {
+			p, err := newPrinter(stdout, *output)
+			if err != nil {
+				return usageError{err.Error()}
+			}
+			return p.print(struct {
+				auth.Key
+				Token string `json:"token,omitempty"`
+			}{key, token}, nil, nil)
+		}
+		if key.CertCN != "" {
+			fmt.Fprintf(stdout, "🔑 Created key %s (%s) for tenant %s with scopes %s, for client certificates with CN %q\n",
+				key.ID, key.Name, key.TenantName(), strings.Join(key.Scopes, ","), key.CertCN)
+			return nil
+		}
+		fmt.Fprintf(stdout, "🔑 Created key %s (%s) for tenant %s with scopes %s\n", key.ID, key.Name, key.TenantName(), strings.Join(key.Scopes, ","))
+		fmt.Fprintln(stdout, token)
+		fmt.Fprintln(stderr, "⚠️  Store it now, it can't be shown again.")
+		return nil
+
+	case "list", "ls":
+		fs, output := flags("key list", stderr)
+		if _, err := parse(fs, args); err != nil {
+			return err
+		}
+		p, err := newPrinter(stdout, *output)
+		if err != nil {
+			return usageError{err.Error()}
+		}
+		list := keys.List()
+		var rows [][]string
+		for _, k := range list {
+			used := "never"
+			if !k.LastUsedAt.IsZero() {
+				used = k.LastUsedAt.Format("2006-01-02 15:04")
+			}
+			kind := "token"
+			if k.CertCN != "" {
+				kind = "cert CN=" + k.CertCN
+			}
+			rows = append(rows, []string{k.ID, k.Name, k.TenantName(), strings.Join(k.Scopes, ","), kind, k.CreatedAt.Format("2006-01-02 15:04"), used})
+		}
+		return p.print(list, []string{"ID", "NAME", "TENANT", "SCOPES", "AUTH", "CREATED", "LAST USED"}, rows)
+
+	case "revoke", "rm":
+		if len(args) != 1 {
+			return usagef("usage: vps-manager key revoke <id>")
+		}
+		err := keys.Revoke(args[0])
+		recordLocal(log, "key.revoke", args[0], nil, err)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "🗑️  Revoked key %s\n", args[0])
+		return nil
+	}
+	return usagef("unknown key command %q", cmd)
+}
+
+// recordLocal writes an audit entry for a change made with the CLI
+func recordLocal(log *audit.Log, action, target string, params map[string]any, err error) {
+	if log == nil {
+		return
+	}
+	_ = log.Record(audit.Entry{
+		Actor:  audit.LocalActor(),
+		Action: action,
+		Target: target,
+		Params: params,
+		Result: audit.Result(err),
+	})
+}
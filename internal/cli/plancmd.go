@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Shaman786/vps-manager/internal/fleet"
+)
+
+func (a *App) planList(args []string, stdout, stderr io.Writer) error {
+	fs, output := flags("plan list", stderr)
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	p, err := newPrinter(stdout, *output)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	list, err := a.backend.Plans()
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, plan := range list {
+		rows = append(rows, []string{plan.Name, strconv.Itoa(plan.CPUs), fmt.Sprintf("%d MB", plan.RAM), plan.Disk})
+	}
+	return p.print(list, []string{"NAME", "CPUS", "RAM", "DISK"}, rows)
+}
+
+// fleetCommand handles `plan|apply -f fleet.yaml [--prune] [--dry-run]`
+func (a *App) fleetCommand(command string, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("f", "", "fleet spec (YAML)")
+	prune := fs.Bool("prune", false, "delete VMs that aren't in the spec")
+	dryRun := false
+	if command == "apply" {
+		fs.BoolVar(&dryRun, "dry-run", false, "show the plan without changing anything")
+	}
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return usagef("usage: vps-manager %s -f fleet.yaml [--prune]", command)
+	}
+
+	spec, err := fleet.Load(*file)
+	if err != nil {
+		return err
+	}
+	changes, warnings, err := fleet.Plan(a.backend, spec, *prune)
+	if err != nil {
+		return err
+	}
+	fleet.Print(stdout, changes, warnings)
+
+	if command == "plan" || dryRun || len(changes) == 0 {
+		return nil
+	}
+	fmt.Fprintln(stdout)
+	return fleet.Apply(a.backend, changes, stdout)
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// RunTenants manages tenants and their quotas (local only, like 'key')
+func RunTenants(ts *tenants.Store, mgr *vm.Manager, keys *auth.Store, log *audit.Log, args []string) int {
+	err := tenantCommand(ts, mgr, keys, log, args, os.Stdout, os.Stderr)
+	if err == nil {
+		return ExitOK
+	}
+	fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+	return exitCode(err)
+}
+
+func tenantCommand(ts *tenants.Store, mgr *vm.Manager, keys *auth.Store, log *audit.Log, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return usagef("usage: vps-manager tenant create|set|list|rm")
+	}
+	cmd, args := args[0], args[1:]
+
+	switch cmd {
+	case "create", "set":
+		fs, _ := flags("tenant "+cmd, stderr)
+		quota := quotaFlags(fs)
+		var networks listFlag
+		fs.Var(&networks, "network", "bridge or network:<name> its VMs may use besides default (repeatable)")
+		pos, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		if len(pos) != 1 {
+			return usagef("usage: vps-manager tenant %s <name> [--max-vms N] [--max-vcpus N] [--max-ram-mb N] [--max-disk-gb N] [--max-public-ips N] [--network br0]", cmd)
+		}
+
+		t := tenants.Tenant{Name: pos[0]}
+		existing, err := ts.Get(pos[0])
+		switch {
+		case cmd == "create" && err == nil:
+			return fmt.Errorf("%w: tenant '%s' already exists", backend.ErrConflict, pos[0])
+		case cmd == "set" && err != nil:
+			return err
+		case cmd == "set":
+			t = existing
+		}
+		// Only the flags given change anything
+		fs.Visit(func(f *flag.Flag) {
+			if set, ok := quota[f.Name]; ok {
+				set(&t.Quota)
+			}
+		})
+		if len(networks) > 0 {
+			t.Networks = networks
+		}
+		err = ts.Put(t)
+		recordLocal(log, "tenant."+cmd, t.Name, map[string]any{"quota": t.Quota, "networks": t.Networks}, err)
+		if err != nil {
+			return usageError{err.Error()}
+		}
+		fmt.Fprintf(stdout, "🏢 Tenant %s: %s\n", t.Name, formatQuota(t.Quota))
+		return nil
+
+	case "list", "ls":
+		fs, output := flags("tenant list", stderr)
+		if _, err := parse(fs, args); err != nil {
+			return err
+		}
+		p, err := newPrinter(stdout, *output)
+		if err != nil {
+			return usageError{err.Error()}
+		}
+		type entry struct {
+			tenants.Tenant
+			Usage tenants.Resources `json:"usage"`
+		}
+		var list []entry
+		var rows [][]string
+		for _, t := range ts.List() {
+			used := mgr.Usage(t.Name)
+			list = append(list, entry{t, used})
+			rows = append(rows, []string{t.Name,
+				usedOf(used.VMs, t.Quota.VMs), usedOf(used.VCPUs, t.Quota.VCPUs), usedOf(used.RAMMB, t.Quota.RAMMB),
+				usedOf(used.DiskGB, t.Quota.DiskGB), usedOf(used.PublicIPs, t.Quota.PublicIPs), strings.Join(t.Networks, ",")})
+		}
+		return p.print(list, []string{"NAME", "VMS", "VCPUS", "RAM MB", "DISK GB", "PUBLIC IPS", "NETWORKS"}, rows)
+
+	case "rm", "delete":
+		if len(args) != 1 {
+			return usagef("usage: vps-manager tenant rm <name>")
+		}
+		if args[0] == tenants.Admin {
+			return usagef("the %s tenant can't be removed", tenants.Admin)
+		}
+		if _, err := ts.Get(args[0]); err != nil {
+			return fmt.Errorf("%w: %w", vm.ErrNotFound, err)
+		}
+		// Orphans would silently fall back to admin
+		if n := mgr.Usage(args[0]).VMs; n > 0 {
+			return fmt.Errorf("%w: tenant '%s' still owns %d VM(s)", backend.ErrConflict, args[0], n)
+		}
+		if n := len(keys.OfTenant(args[0])); n > 0 {
+			return fmt.Errorf("%w: tenant '%s' still has %d API key(s), revoke them first", backend.ErrConflict, args[0], n)
+		}
+		err := ts.Delete(args[0])
+		recordLocal(log, "tenant.delete", args[0], nil, err)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "🗑️  Removed tenant %s\n", args[0])
+		return nil
+	}
+	return usagef("unknown tenant command %q", cmd)
+}
+
+// quotaFlags registers the --max-* flags; the map applies one of them to a quota
+func quotaFlags(fs *flag.FlagSet) map[string]func(*tenants.Resources) {
+	vms := fs.Int("max-vms", 0, "max VMs (0 = unlimited)")
+	vcpus := fs.Int("max-vcpus", 0, "max vCPUs over all VMs")
+	ram := fs.Int("max-ram-mb", 0, "max RAM in MB over all VMs")
+	disk := fs.Int("max-disk-gb", 0, "max root disk GB over all VMs")
+	ips := fs.Int("max-public-ips", 0, "max bridged NICs over all VMs")
+	return map[string]func(*tenants.Resources){
+		"max-vms":        func(q *tenants.Resources) { q.VMs = *vms },
+		"max-vcpus":      func(q *tenants.Resources) { q.VCPUs = *vcpus },
+		"max-ram-mb":     func(q *tenants.Resources) { q.RAMMB = *ram },
+		"max-disk-gb":    func(q *tenants.Resources) { q.DiskGB = *disk },
+		"max-public-ips": func(q *tenants.Resources) { q.PublicIPs = *ips },
+	}
+}
+
+func formatQuota(q tenants.Resources) string {
+	limit := func(n int) string {
+		if n == 0 {
+			return "∞"
+		}
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("vms %s, vcpus %s, ram %s MB, disk %s GB, public ips %s",
+		limit(q.VMs), limit(q.VCPUs), limit(q.RAMMB), limit(q.DiskGB), limit(q.PublicIPs))
+}
+
+func usedOf(used, max int) string {
+	if max == 0 {
+		return strconv.Itoa(used)
+	}
+	return fmt.Sprintf("%d/%d", used, max)
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/fleet"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+func (a *App) vmCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return usagef("usage: vps-manager vm list|info|create|start|stop|reboot|delete|rebuild|resize|rescue|unrescue")
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list", "ls":
+		return a.vmList(args, stdout, stderr)
+	case "info":
+		return a.vmInfo(args, stdout, stderr)
+	case "create":
+		return a.vmCreate(args, stdout, stderr)
+	case "rebuild":
+		return a.vmRebuild(args, stdout, stderr)
+	case "resize":
+		return a.vmResize(args, stdout, stderr)
+	case "start", "stop", "reboot", "delete", "rescue", "unrescue":
+		return a.vmAction(cmd, args, stdout, stderr)
+	}
+	return usagef("unknown vm command %q", cmd)
+}
+
+func (a *App) vmList(args []string, stdout, stderr io.Writer) error {
+	fs, output := flags("vm list", stderr)
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	p, err := newPrinter(stdout, *output)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	vms, err := a.backend.ListVMs()
+	if err != nil {
+		return err
+	}
+	if vms == nil {
+		vms = []core.VMState{}
+	}
+	var rows [][]string
+	for _, v := range vms {
+		health := "-"
+		if v.Health != nil {
+			health = v.Health.Status
+		}
+		rows = append(rows, []string{v.Name, v.State, v.IP, health})
+	}
+	return p.print(vms, []string{"NAME", "STATE", "IP", "HEALTH"}, rows)
+}
+
+func (a *App) vmInfo(args []string, stdout, stderr io.Writer) error {
+	fs, output := flags("vm info", stderr)
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, err := oneName("vm info", pos)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(stdout, *output)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	out, err := a.backend.VMInfo(name)
+	if err != nil {
+		return err
+	}
+	info := out.VMState
+
+	rows := [][]string{
+		{"Name", info.Name},
+		{"State", info.State},
+		{"IP", info.IP},
+		{"Tenant", info.Tenant},
+		{"Restart policy", out.Restart.Mode},
+	}
+	if out.Record != nil {
+		rows = append(rows,
+			[]string{"Plan", out.Record.Plan},
+			[]string{"Image", out.Record.Image},
+			[]string{"User", out.Record.Username},
+			[]string{"Networks", strings.Join(out.Record.Networks, ", ")},
+			[]string{"Labels", fleet.FormatLabels(out.Record.Labels)},
+			[]string{"Created", out.Record.CreatedAt.Format("2006-01-02 15:04")},
+		)
+	}
+	if out.Restart.CrashLoop {
+		rows = append(rows, []string{"Crash loop", "yes"})
+	}
+	if info.Health != nil {
+		rows = append(rows, []string{"Health", info.Health.Status})
+	}
+	return p.print(out, []string{"FIELD", "VALUE"}, rows)
+}
+
+func (a *App) vmCreate(args []string, stdout, stderr io.Writer) error {
+	fs, output := flags("vm create", stderr)
+	opts := vm.CreateOptions{}
+	var networks, keys listFlag
+	labels := labelFlag{}
+	fs.StringVar(&opts.Name, "name", "", "VM name (or the first argument)")
+	fs.StringVar(&opts.Image, "image", "", "logical image name, e.g. ubuntu-24.04")
+	fs.StringVar(&opts.PlanName, "plan", "Starter", "plan (see vps-manager plan list)")
+	fs.StringVar(&opts.Username, "username", "root", "login user")
+	fs.StringVar(&opts.Password, "password", "", "password (generated and printed if empty)")
+	fs.Var(&networks, "network", "NIC: default, network:<name> or a bridge (repeatable)")
+	fs.Var(&keys, "ssh-key", "authorized SSH public key (repeatable)")
+	fs.Var(labels, "label", "key=value (repeatable)")
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if opts.Name == "" && len(pos) == 1 {
+		opts.Name = pos[0]
+	} else if len(pos) > 0 {
+		return usagef("usage: vps-manager vm create <name> --image <image> [flags]")
+	}
+	if opts.Name == "" || opts.Image == "" {
+		return usagef("vm create needs a name and --image")
+	}
+	for _, n := range networks {
+		if err := core.CheckNetwork(n); err != nil {
+			return usageError{err.Error()}
+		}
+	}
+	p, err := newPrinter(stdout, *output)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	generated := opts.Password == ""
+	if generated {
+		opts.Password = vm.RandomPassword()
+	}
+	opts.Networks, opts.SSHKeys = networks, keys
+	if len(labels) > 0 {
+		opts.Labels = labels
+	}
+	opts.Progress = stepTo(stderr)
+
+	if err := a.backend.CreateVM(opts); err != nil {
+		return err
+	}
+	if generated {
+		fmt.Fprintf(stderr, "🔑 Password for %s: %s\n", opts.Username, opts.Password)
+	}
+	info, err := a.backend.VMInfo(opts.Name)
+	if err != nil {
+		return err
+	}
+	return p.print(info, []string{"NAME", "STATE", "IP"}, [][]string{{info.Name, info.State, info.IP}})
+}
+
+func (a *App) vmRebuild(args []string, stdout, stderr io.Writer) error {
+	fs, _ := flags("vm rebuild", stderr)
+	image := fs.String("image", "", "image to reinstall from")
+	password := fs.String("password", "", "new password (generated and printed if empty)")
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, err := oneName("vm rebuild", pos)
+	if err != nil {
+		return err
+	}
+	if *image == "" {
+		return usagef("vm rebuild needs --image")
+	}
+	pw := *password
+	if pw == "" {
+		pw = vm.RandomPassword()
+		defer fmt.Fprintf(stderr, "🔑 New password: %s\n", pw)
+	}
+	if err := a.backend.RebuildVM(name, *image, pw, stepTo(stderr)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "✅ %s rebuilt from %s\n", name, *image)
+	return nil
+}
+
+func (a *App) vmResize(args []string, stdout, stderr io.Writer) error {
+	fs, _ := flags("vm resize", stderr)
+	plan := fs.String("plan", "", "new plan")
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, err := oneName("vm resize", pos)
+	if err != nil {
+		return err
+	}
+	if *plan == "" {
+		return usagef("vm resize needs --plan")
+	}
+	if err := a.backend.ResizeVM(name, *plan, stepTo(stderr)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "✅ %s resized to %s\n", name, *plan)
+	return nil
+}
+
+// vmAction runs the simple lifecycle actions
+func (a *App) vmAction(action string, args []string, stdout, stderr io.Writer) error {
+	fs, _ := flags("vm "+action, stderr)
+	yes := fs.Bool("yes", false, "confirm (required for delete)")
+	var params vm.ActionParams
+	if action == "rescue" {
+		fs.StringVar(&params.Password, "password", "", "temporary root password")
+		fs.StringVar(&params.SSHKey, "ssh-key", "", "temporary root SSH key")
+	}
+	pos, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, err := oneName("vm "+action, pos)
+	if err != nil {
+		return err
+	}
+	if action == "delete" && !*yes {
+		return usagef("vm delete destroys %s and its disks; add --yes to confirm", name)
+	}
+	if err := a.backend.Action(name, action, params, stepTo(stderr)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "✅ %s: %s done\n", name, action)
+	return nil
+}
+
+func oneName(cmd string, pos []string) (string, error) {
+	if len(pos) != 1 {
+		return "", usagef("usage: vps-manager %s <name>", cmd)
+	}
+	return pos[0], nil
+}
@@ -0,0 +1,124 @@
+// Package client drives a remote `vps-manager listen` server through the
+// api package's client. It implements backend.Backend, so the CLI works the
+// same against a remote host.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// Client talks to one server
+type Client struct {
+	API *api.Client
+}
+
+var _ backend.Backend = (*Client)(nil)
+
+func New(server, token string) *Client {
+	return &Client{API: api.NewClient(server, token)}
+}
+
+// WithTLS makes the client verify the server (and present a client
+// certificate) as cfg says, e.g. from certs.ClientConfig
+func (c *Client) WithTLS(cfg *tls.Config) *Client {
+	c.API.WithTLS(cfg)
+	return c
+}
+
+// wait follows the job a call started (if any), passing its steps to progress
+func (c *Client) wait(acc api.Accepted, err error, progress backend.Progress) error {
+	if err != nil || acc.JobID == "" {
+		return backendErr(err) // Or finished synchronously
+	}
+	_, err = c.API.WaitJob(context.Background(), acc.JobID, func(step api.JobStep) {
+		if progress != nil {
+			progress(step.Message)
+		}
+	})
+	return backendErr(err)
+}
+
+func (c *Client) ListVMs() ([]core.VMState, error) {
+	list, err := c.API.ListVMs(context.Background())
+	return fromList(list, fromVM), backendErr(err)
+}
+
+func (c *Client) VMInfo(name string) (vm.Info, error) {
+	info, err := c.API.GetVM(context.Background(), name)
+	return fromVMInfo(info), backendErr(err)
+}
+
+func (c *Client) Metrics(name string) (map[string]float64, error) {
+	metrics, err := c.API.Metrics(context.Background(), name)
+	return metrics, backendErr(err)
+}
+
+func (c *Client) CreateVM(opts vm.CreateOptions) error {
+	acc, err := c.API.CreateVM(context.Background(), api.CreateVMRequest{
+		Name:     opts.Name,
+		Image:    opts.Image,
+		Plan:     opts.PlanName,
+		Username: opts.Username,
+		Password: opts.Password,
+		Networks: opts.Networks,
+		SSHKeys:  opts.SSHKeys,
+		Labels:   opts.Labels,
+	})
+	return c.wait(acc, err, opts.Progress)
+}
+
+func (c *Client) RebuildVM(name, image, password string, progress backend.Progress) error {
+	acc, err := c.API.RebuildVM(context.Background(), name, api.RebuildRequest{Image: image, Password: password})
+	return c.wait(acc, err, progress)
+}
+
+func (c *Client) ResizeVM(name, plan string, progress backend.Progress) error {
+	acc, err := c.API.ResizeVM(context.Background(), name, api.ResizeRequest{Plan: plan})
+	return c.wait(acc, err, progress)
+}
+
+func (c *Client) Action(name, action string, params vm.ActionParams, progress backend.Progress) error {
+	if action == "delete" {
+		acc, err := c.API.DeleteVM(context.Background(), name)
+		return c.wait(acc, err, progress)
+	}
+	req := api.ActionRequest{Username: params.Username, Password: params.Password, SSHKey: params.SSHKey}
+	acc, err := c.API.Action(context.Background(), name, action, req)
+	return c.wait(acc, err, progress)
+}
+
+func (c *Client) SetLabels(name string, labels map[string]string) error {
+	return backendErr(c.API.SetLabels(context.Background(), name, labels))
+}
+
+func (c *Client) ListImages() ([]images.ImageInfo, error) {
+	list, err := c.API.ListImages(context.Background())
+	return fromList(list, fromImage), backendErr(err)
+}
+
+func (c *Client) RegisterImage(name, imageURL, checksum string) error {
+	_, err := c.API.RegisterImage(context.Background(), name, imageURL, checksum)
+	return backendErr(err)
+}
+
+func (c *Client) PullImage(name string, progress backend.Progress) error {
+	acc, err := c.API.PullImage(context.Background(), name)
+	return c.wait(acc, err, progress)
+}
+
+func (c *Client) RemoveImage(name string, force bool) error {
+	return backendErr(c.API.DeleteImage(context.Background(), name, force))
+}
+
+func (c *Client) Plans() ([]plans.VMPlan, error) {
+	list, err := c.API.Plans(context.Background())
+	return fromList(list, fromPlan), backendErr(err)
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// Conversions from the api package's wire types back to the structures the
+// CLI prints (the reverse of the server's, see webhook/wire.go)
+
+func fromVM(v api.VM) core.VMState {
+	return core.VMState{
+		ID:     v.ID,
+		Name:   v.Name,
+		Status: v.Status,
+		IP:     v.IP,
+		State:  v.State,
+		Health: fromHealthReport(v.Health),
+		Plan:   v.Plan,
+		Image:  v.Image,
+		Tenant: v.Tenant,
+	}
+}
+
+func fromVMInfo(info api.VMInfo) vm.Info {
+	out := vm.Info{
+		VMState:     fromVM(info.VM),
+		Restart:     vm.RestartPolicy(info.RestartPolicy),
+		Restarts:    fromRestarts(info.Restarts),
+		HealthCheck: fromHealthCheck(info.HealthCheck),
+	}
+	if r := info.Record; r != nil {
+		out.Record = &vm.Record{
+			Name:      r.Name,
+			Image:     r.Image,
+			Plan:      r.Plan,
+			Username:  r.Username,
+			CreatedAt: r.CreatedAt,
+			RebuiltAt: r.RebuiltAt,
+			Networks:  r.Networks,
+			SSHKeys:   r.SSHKeys,
+			Labels:    r.Labels,
+			Tenant:    r.Tenant,
+			Restarts:  fromRestarts(r.Restarts),
+			Health:    fromHealthCheck(r.Health),
+		}
+		if r.Restart != nil {
+			p := vm.RestartPolicy(*r.Restart)
+			out.Record.Restart = &p
+		}
+	}
+	return out
+}
+
+func fromHealthReport(r *api.HealthReport) *core.HealthReport {
+	if r == nil {
+		return nil
+	}
+	out := &core.HealthReport{Status: r.Status, ConsecutiveFailures: r.ConsecutiveFailures}
+	for _, h := range r.History {
+		out.History = append(out.History, core.HealthResult(h))
+	}
+	return out
+}
+
+func fromHealthCheck(c *api.HealthCheck) *vm.HealthCheck {
+	if c == nil {
+		return nil
+	}
+	out := vm.HealthCheck(*c)
+	return &out
+}
+
+func fromRestarts(list []api.RestartRecord) []vm.RestartRecord {
+	var out []vm.RestartRecord
+	for _, r := range list {
+		out = append(out, vm.RestartRecord(r))
+	}
+	return out
+}
+
+func fromImage(img api.Image) images.ImageInfo {
+	return images.ImageInfo(img)
+}
+
+func fromPlan(p api.Plan) plans.VMPlan {
+	return plans.VMPlan{Name: p.Name, RAM: p.RAMMB, CPUs: p.CPUs, Disk: p.Disk}
+}
+
+func fromList[W, T any](list []W, conv func(W) T) []T {
+	out := make([]T, 0, len(list))
+	for _, v := range list {
+		out = append(out, conv(v))
+	}
+	return out
+}
+
+// remoteError keeps the server's message and also matches the backend
+// errors, so the CLI handles a remote 404 like a local one
+type remoteError struct {
+	err  error
+	kind error
+}
+
+func (e *remoteError) Error() string   { return e.err.Error() }
+func (e *remoteError) Unwrap() []error { return []error{e.err, e.kind} }
+
+func backendErr(err error) error {
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		return &remoteError{err, backend.ErrNotFound}
+	case errors.Is(err, api.ErrConflict):
+		return &remoteError{err, backend.ErrConflict}
+	case errors.Is(err, api.ErrUnauthorized):
+		return &remoteError{err, backend.ErrUnauthorized}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package cloudinit
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ5K0hR0mz7Y7r2c0d8zq3fW7c8T3p8J2o9xk4yM1n2b ops@laptop"
+
+func TestQuote(t *testing.T) {
+	cases := []string{
+		"web1",
+		"",
+		"yes",
+		"123",
+		"null",
+		"p@ss: word",
+		"#not a comment",
+		`back\slash "and" quotes`,
+		"multi\nline\ttext",
+		"x\n  - evil: true",
+		"{a: 1}",
+		"'single'",
+		"ünïcödé ✓",
+	}
+	for _, s := range cases {
+		q, err := quote(s)
+		if err != nil {
+			t.Fatalf("quote(%q): %v", s, err)
+		}
+		if !strings.HasPrefix(q, `"`) || strings.Contains(q, "\n") {
+			t.Errorf("quote(%q) = %s, want one double-quoted line", s, q)
+		}
+		var back string
+		if err := yaml.Unmarshal([]byte("v: "+q), &struct{ V *string }{&back}); err != nil || back != s {
+			t.Errorf("quote(%q) = %s reads back as %q (%v)", s, q, back, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	good := ConfigData{Hostname: "web1", Username: "ops", UserPass: "s3cret", RootPass: "r00t", SSHKeys: []string{testKey}}
+	cases := []struct {
+		name string
+		edit func(d *ConfigData)
+		ok   bool
+	}{
+		{"valid", func(d *ConfigData) {}, true},
+		{"no user", func(d *ConfigData) { d.Username = "" }, true},
+		{"password with yaml", func(d *ConfigData) { d.RootPass = `a: "b" # c` }, true},
+		{"key without comment", func(d *ConfigData) { d.SSHKeys = []string{strings.TrimSuffix(testKey, " ops@laptop")} }, true},
+
+		{"no hostname", func(d *ConfigData) { d.Hostname = "" }, false},
+		{"hostname newline", func(d *ConfigData) { d.Hostname = "web1\nruncmd: [reboot]" }, false},
+		{"uppercase user", func(d *ConfigData) { d.Username = "Admin" }, false},
+		{"user with colon", func(d *ConfigData) { d.Username = "ops:x" }, false},
+		{"user too long", func(d *ConfigData) { d.Username = strings.Repeat("a", 33) }, false},
+		{"password newline", func(d *ConfigData) { d.UserPass = "pw\nroot:owned" }, false},
+		{"root password tab", func(d *ConfigData) { d.RootPass = "pw\tx" }, false},
+		{"key newline", func(d *ConfigData) { d.SSHKeys = []string{testKey + "\nssh-rsa AAAA"} }, false},
+		{"key type unknown", func(d *ConfigData) { d.SSHKeys = []string{"ssh-foo AAAAC3NzaC1lZDI1NTE5"} }, false},
+		{"key not base64", func(d *ConfigData) { d.SSHKeys = []string{"ssh-ed25519 not*base64"} }, false},
+		{"key type mismatch", func(d *ConfigData) { d.SSHKeys = []string{"ssh-rsa" + strings.TrimPrefix(testKey, "ssh-ed25519")} }, false},
+		{"key with options", func(d *ConfigData) { d.SSHKeys = []string{`command="sh" ` + testKey} }, false},
+		{"key only", func(d *ConfigData) { d.SSHKeys = []string{"ssh-ed25519"} }, false},
+	}
+	for _, tc := range cases {
+		d := good
+		tc.edit(&d)
+		if err := Validate(d); (err == nil) != tc.ok {
+			t.Errorf("%s: Validate = %v, want ok=%v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+// Whatever the values, the cloud-config has our keys and only those values
+func TestGenerateQuotesValues(t *testing.T) {
+	data := ConfigData{
+		Hostname: "web1 #x",
+		Username: "ops",
+		UserPass: `"}: {runcmd: [reboot]`,
+		RootPass: "r00t'\\",
+		SSHKeys:  []string{testKey},
+	}
+	out, err := Generate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Hostname string   `yaml:"hostname"`
+		Keys     []string `yaml:"ssh_authorized_keys"`
+		Chpasswd struct {
+			List string `yaml:"list"`
+		} `yaml:"chpasswd"`
+		Runcmd []any `yaml:"runcmd"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &cfg); err != nil {
+		t.Fatalf("generated config is not YAML: %v\n%s", err, out)
+	}
+	if cfg.Hostname != data.Hostname {
+		t.Errorf("hostname %q, want %q", cfg.Hostname, data.Hostname)
+	}
+	if len(cfg.Keys) != 1 || cfg.Keys[0] != testKey {
+		t.Errorf("ssh keys %q", cfg.Keys)
+	}
+	if cfg.Chpasswd.List != data.Passwords() {
+		t.Errorf("chpasswd list %q, want %q", cfg.Chpasswd.List, data.Passwords())
+	}
+	if len(cfg.Runcmd) != 3 {
+		t.Errorf("runcmd has %d entries, want the 3 of the template", len(cfg.Runcmd))
+	}
+
+	if _, err := Generate(ConfigData{Hostname: "web1", RootPass: "a\nb"}); err == nil {
+		t.Error("Generate accepted a password with a newline")
+	}
+}
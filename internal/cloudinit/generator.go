@@ -13,36 +13,45 @@ type ConfigData struct {
 	UserPass       string
 	RootPass       string
 	AllowRootLogin bool
+	SSHKeys        []string // Authorized for the default user and root
 }
 
 // THE GOD MODE CONFIG
 // 1. disable_root: false (Prevents Debian/Ubuntu from locking root account)
 // 2. PermitRootLogin is FORCED to YES
 const configTmpl = `#cloud-config
-hostname: {{.Hostname}}
+hostname: {{q .Hostname}}
 ssh_pwauth: true
 disable_root: false
 package_update: true
 package_upgrade: false
+{{- if .SSHKeys}}
+ssh_authorized_keys:
+{{- range .SSHKeys}}
+  - {{q .}}
+{{- end}}
+{{- end}}
 
 # --- 1. OPTIONAL USER CREATION ---
 users:
   - default
 {{- if .Username}}
-  - name: {{.Username}}
+  - name: {{q .Username}}
     sudo: ['ALL=(ALL) NOPASSWD:ALL']
     groups: [sudo, wheel, users, admin]
     shell: /bin/bash
     lock_passwd: false
+{{- if .SSHKeys}}
+    ssh_authorized_keys:
+{{- range .SSHKeys}}
+      - {{q .}}
+{{- end}}
+{{- end}}
 {{- end}}
 
 # --- 2. SET PASSWORDS ---
 chpasswd:
-  list: |
-    root:{{.RootPass}}
-{{- if .Username}}
-    {{.Username}}:{{.UserPass}}
-{{- end}}
+  list: {{q .Passwords}}
   expire: false
 
 # --- 3. SSH CONFIGURATION ---
@@ -55,15 +64,34 @@ write_files:
       KbdInteractiveAuthentication yes
       PubkeyAuthentication yes
 
-# --- 4. APPLY CHANGES ---
+# --- 4. GUEST AGENT (password resets / key injection without reboot) ---
+packages:
+  - qemu-guest-agent
+
+# --- 5. APPLY CHANGES ---
 runcmd:
   - [ systemctl, daemon-reload ]
+  - [ sh, -c, "systemctl enable --now qemu-guest-agent 2>/dev/null || rc-update add qemu-guest-agent 2>/dev/null || true" ]
   # Restart SSH to apply the PermitRootLogin change
   - [ sh, -c, "systemctl restart sshd 2>/dev/null || systemctl restart ssh 2>/dev/null || true" ]
 `
 
+// Passwords is the chpasswd list, one "user:password" per line
+func (d ConfigData) Passwords() string {
+	list := "root:" + d.RootPass
+	if d.Username != "" {
+		list += "\n" + d.Username + ":" + d.UserPass
+	}
+	return list
+}
+
+// Generate validates data and renders the user-data; every value is emitted
+// as a quoted YAML scalar
 func Generate(data ConfigData) (string, error) {
-	tmpl, err := template.New("cloud-config").Parse(configTmpl)
+	if err := Validate(data); err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("cloud-config").Funcs(template.FuncMap{"q": quote}).Parse(configTmpl)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -0,0 +1,96 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+var validUsername = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// sshKeyTypes are the public key algorithms OpenSSH accepts in authorized_keys
+var sshKeyTypes = map[string]bool{
+	"ssh-ed25519": true, "ssh-rsa": true, "ssh-dss": true,
+	"ecdsa-sha2-nistp256": true, "ecdsa-sha2-nistp384": true, "ecdsa-sha2-nistp521": true,
+	"sk-ssh-ed25519@openssh.com": true, "sk-ecdsa-sha2-nistp256@openssh.com": true,
+}
+
+// ValidUsername accepts what useradd does: lowercase, digits, '_' and '-'
+func ValidUsername(name string) bool {
+	return validUsername.MatchString(name)
+}
+
+// CheckSSHKey accepts one authorized_keys line without options:
+// "<type> <base64 key> [comment]", where the key really is of that type
+func CheckSSHKey(key string) error {
+	if hasControl(key) {
+		return fmt.Errorf("ssh key must be a single line")
+	}
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return fmt.Errorf("ssh key must look like \"ssh-ed25519 AAAA... [comment]\"")
+	}
+	if !sshKeyTypes[fields[0]] {
+		return fmt.Errorf("unsupported ssh key type %q", fields[0])
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil || len(blob) < 4 {
+		return fmt.Errorf("ssh key is not valid base64")
+	}
+	n := binary.BigEndian.Uint32(blob)
+	if uint64(n) > uint64(len(blob)-4) || string(blob[4:4+n]) != fields[0] {
+		return fmt.Errorf("ssh key data does not match its type %s", fields[0])
+	}
+	return nil
+}
+
+// CheckPassword rejects passwords that can't be set as one line
+func CheckPassword(password string) error {
+	if hasControl(password) {
+		return fmt.Errorf("password must not contain control characters")
+	}
+	return nil
+}
+
+// Validate checks everything Generate puts into the cloud-config
+func Validate(data ConfigData) error {
+	if data.Hostname == "" || hasControl(data.Hostname) {
+		return fmt.Errorf("invalid hostname %q", data.Hostname)
+	}
+	if data.Username != "" && !ValidUsername(data.Username) {
+		return fmt.Errorf("invalid username %q: use lowercase letters, digits, '_' or '-'", data.Username)
+	}
+	for _, pw := range []string{data.RootPass, data.UserPass} {
+		if err := CheckPassword(pw); err != nil {
+			return err
+		}
+	}
+	for _, key := range data.SSHKeys {
+		if err := CheckSSHKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasControl(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
+
+// quote renders s as a double-quoted YAML scalar, so no value can end the
+// line it is on or start new keys
+func quote(s string) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: s}); err != nil {
+		return "", err
+	}
+	enc.Close()
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,85 @@
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+// maxTailRead bounds how much of a console log Tail reads
+const maxTailRead = 256 * 1024
+
+// SerialFunc finds a VM's serial pty and log file
+type SerialFunc func(vmID string) (core.SerialInfo, error)
+
+// SerialProxy gives a websocket client interactive access to the VM's serial pty
+func SerialProxy(signer *Signer, target SerialFunc) http.Handler {
+	return websocket.Server{
+		Handshake: handshake,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			vmID, err := signer.Verify(ws.Request().URL.Query().Get("token"), "serial")
+			if err != nil {
+				return
+			}
+			info, err := target(vmID)
+			if err != nil || info.PTY == "" {
+				fmt.Fprintf(ws, "serial console for %s unavailable (is it running?)\r\n", vmID)
+				return
+			}
+
+			// O_NOCTTY: the daemon must not adopt the guest's pty as its controlling terminal
+			pty, err := os.OpenFile(info.PTY, os.O_RDWR|syscall.O_NOCTTY, 0)
+			if err != nil {
+				fmt.Fprintf(ws, "failed to open %s: %v\r\n", info.PTY, err)
+				return
+			}
+			defer pty.Close()
+
+			ws.PayloadType = websocket.BinaryFrame
+			pipe(ws, pty)
+		},
+	}
+}
+
+// Tail returns up to the last n lines of a log file
+func Tail(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(st.Size()-maxTailRead, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if offset > 0 && len(lines) > 0 {
+		lines = lines[1:] // First line is probably cut in half
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return bytes.Join(lines, nil), nil
+}
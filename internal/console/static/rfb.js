@@ -0,0 +1,315 @@
+// A small RFB (VNC) client for the embedded console: protocol 3.8, None and
+// VNC authentication, Raw/CopyRect encodings and server-side resizes. It
+// keeps the subset of noVNC's RFB API that vnc.html uses, and everything is
+// served by vps-manager itself, so the console needs no third-party code.
+
+import { vncAuth } from "./des.js";
+
+const ENC_RAW = 0, ENC_COPYRECT = 1, ENC_DESKTOPSIZE = -223;
+
+const KEYSYMS = {
+  Backspace: 0xff08, Tab: 0xff09, Enter: 0xff0d, Escape: 0xff1b, Insert: 0xff63, Delete: 0xffff,
+  Home: 0xff50, End: 0xff57, PageUp: 0xff55, PageDown: 0xff56,
+  ArrowLeft: 0xff51, ArrowUp: 0xff52, ArrowRight: 0xff53, ArrowDown: 0xff54,
+  Shift: 0xffe1, Control: 0xffe3, Alt: 0xffe9, AltGraph: 0xfe03, Meta: 0xffe7, CapsLock: 0xffe5,
+};
+
+// keysym maps a KeyboardEvent to an X11 keysym (0 = ignore)
+export function keysym(e) {
+  if (KEYSYMS[e.key]) return KEYSYMS[e.key];
+  const f = /^F([1-9]|1[0-2])$/.exec(e.key);
+  if (f) return 0xffbe + Number(f[1]) - 1;
+  if ([...e.key].length === 1) {
+    const cp = e.key.codePointAt(0);
+    return cp < 0x100 ? cp : 0x1000000 | cp; // Latin-1 maps directly, the rest as Unicode keysyms
+  }
+  return 0;
+}
+
+export default class RFB extends EventTarget {
+  constructor(target, url, options = {}) {
+    super();
+    this._target = target;
+    this._password = options.credentials?.password;
+    this._chunks = [];
+    this._length = 0;
+    this._waiter = null;
+    this._pressed = new Map(); // KeyboardEvent.code -> keysym sent on keydown
+    this._buttons = 0;
+    this._scale = false;
+    this._connected = false;
+
+    this._canvas = target.ownerDocument.createElement("canvas");
+    this._canvas.tabIndex = 0;
+    this._canvas.style.outline = "none";
+    target.appendChild(this._canvas);
+    this._ctx = this._canvas.getContext("2d");
+
+    this._ws = new WebSocket(url, ["binary"]);
+    this._ws.binaryType = "arraybuffer";
+    this._ws.onmessage = (e) => this._push(new Uint8Array(e.data));
+    this._ws.onclose = () => this._closed();
+    this._ws.onopen = () => this._run().catch((err) => {
+      if (this._ws.readyState === WebSocket.CLOSED) return; // Already reported by _closed
+      console.error("VNC:", err);
+      this._error = err;
+      this._ws.close();
+    });
+  }
+
+  get scaleViewport() { return this._scale; }
+  set scaleViewport(on) {
+    this._scale = on;
+    this._fit();
+  }
+
+  sendCredentials({ password }) {
+    this._password = password;
+    if (this._onCredentials) this._onCredentials();
+  }
+
+  sendCtrlAltDel() {
+    for (const k of [0xffe3, 0xffe9, 0xffff]) this._key(k, true);
+    for (const k of [0xffff, 0xffe9, 0xffe3]) this._key(k, false);
+  }
+
+  disconnect() {
+    this._ws.close();
+  }
+
+  // --- Receiving ---
+
+  _push(chunk) {
+    this._chunks.push(chunk);
+    this._length += chunk.length;
+    const w = this._waiter;
+    if (w && this._length >= w.n) {
+      this._waiter = null;
+      w.resolve(this._take(w.n));
+    }
+  }
+
+  _take(n) {
+    const out = new Uint8Array(n);
+    for (let off = 0; off < n;) {
+      const c = this._chunks[0];
+      const k = Math.min(c.length, n - off);
+      out.set(c.subarray(0, k), off);
+      off += k;
+      if (k === c.length) this._chunks.shift();
+      else this._chunks[0] = c.subarray(k);
+    }
+    this._length -= n;
+    return out;
+  }
+
+  // _read resolves with exactly n bytes
+  _read(n) {
+    if (this._length >= n) return Promise.resolve(this._take(n));
+    return new Promise((resolve, reject) => { this._waiter = { n, resolve, reject }; });
+  }
+
+  async _view(n) {
+    const b = await this._read(n);
+    return new DataView(b.buffer);
+  }
+
+  async _reason() {
+    const n = (await this._view(4)).getUint32(0);
+    return new TextDecoder().decode(await this._read(n));
+  }
+
+  _closed() {
+    if (this._waiter) {
+      this._waiter.reject(new Error("connection closed"));
+      this._waiter = null;
+    }
+    const clean = this._connected && !this._error;
+    this._connected = false;
+    this.dispatchEvent(new CustomEvent("disconnect", { detail: { clean } }));
+  }
+
+  async _run() {
+    const version = new TextDecoder().decode(await this._read(12));
+    if (!version.startsWith("RFB ")) throw new Error("not a VNC server");
+    this._send(new TextEncoder().encode("RFB 003.008\n"));
+
+    // Security
+    const count = (await this._read(1))[0];
+    if (count === 0) throw new Error(await this._reason());
+    const types = await this._read(count);
+    if (types.includes(2)) {
+      this._send([2]);
+      const challenge = await this._read(16);
+      if (this._password === undefined) {
+        const given = new Promise((resolve) => { this._onCredentials = resolve; });
+        this.dispatchEvent(new CustomEvent("credentialsrequired", { detail: { types: ["password"] } }));
+        await given;
+      }
+      this._send(vncAuth(this._password, challenge));
+    } else if (types.includes(1)) {
+      this._send([1]);
+    } else {
+      throw new Error("no supported security type");
+    }
+    if ((await this._view(4)).getUint32(0) !== 0) {
+      throw new Error("authentication failed: " + await this._reason().catch(() => ""));
+    }
+
+    // Init: share the session, then ask for 32-bit little-endian RGBX pixels,
+    // which is exactly the canvas' RGBA layout
+    this._send([1]);
+    const init = await this._view(24);
+    this._resize(init.getUint16(0), init.getUint16(2));
+    await this._read(init.getUint32(20)); // Desktop name
+
+    this._send([0, 0, 0, 0, 32, 24, 0, 1, 0, 255, 0, 255, 0, 255, 0, 8, 16, 0, 0, 0]);
+    const encodings = [ENC_COPYRECT, ENC_RAW, ENC_DESKTOPSIZE];
+    const msg = new DataView(new ArrayBuffer(4 + 4 * encodings.length));
+    msg.setUint8(0, 2);
+    msg.setUint16(2, encodings.length);
+    encodings.forEach((enc, i) => msg.setInt32(4 + 4 * i, enc));
+    this._send(new Uint8Array(msg.buffer));
+    this._request(false);
+
+    this._connected = true;
+    this._listen();
+    this._canvas.focus();
+    this.dispatchEvent(new CustomEvent("connect"));
+
+    for (;;) {
+      const type = (await this._read(1))[0];
+      switch (type) {
+        case 0: await this._update(); break;
+        case 1: { // SetColourMapEntries (unused with true colour)
+          const h = await this._view(5);
+          await this._read(6 * h.getUint16(3));
+          break;
+        }
+        case 2: break; // Bell
+        case 3: { // ServerCutText
+          const h = await this._view(7);
+          await this._read(h.getUint32(3));
+          break;
+        }
+        default:
+          throw new Error("unknown server message " + type);
+      }
+    }
+  }
+
+  async _update() {
+    const rects = (await this._view(3)).getUint16(1);
+    let resized = false;
+    for (let i = 0; i < rects; i++) {
+      const r = await this._view(12);
+      const x = r.getUint16(0), y = r.getUint16(2), w = r.getUint16(4), h = r.getUint16(6);
+      switch (r.getInt32(8)) {
+        case ENC_RAW: {
+          const px = await this._read(w * h * 4);
+          for (let j = 3; j < px.length; j += 4) px[j] = 255;
+          if (w && h) this._ctx.putImageData(new ImageData(new Uint8ClampedArray(px.buffer), w, h), x, y);
+          break;
+        }
+        case ENC_COPYRECT: {
+          const src = await this._view(4);
+          this._ctx.drawImage(this._canvas, src.getUint16(0), src.getUint16(2), w, h, x, y, w, h);
+          break;
+        }
+        case ENC_DESKTOPSIZE:
+          this._resize(w, h);
+          resized = true;
+          break;
+        default:
+          throw new Error("unsupported encoding " + r.getInt32(8));
+      }
+    }
+    this._request(!resized);
+  }
+
+  // --- Sending ---
+
+  _send(bytes) {
+    if (this._ws.readyState === WebSocket.OPEN) this._ws.send(bytes instanceof Uint8Array ? bytes : new Uint8Array(bytes));
+  }
+
+  _request(incremental) {
+    const { width, height } = this._canvas;
+    this._send([3, incremental ? 1 : 0, 0, 0, 0, 0, width >> 8, width & 255, height >> 8, height & 255]);
+  }
+
+  _key(sym, down) {
+    this._send([4, down ? 1 : 0, 0, 0, (sym >>> 24) & 255, (sym >> 16) & 255, (sym >> 8) & 255, sym & 255]);
+  }
+
+  _pointer(x, y, mask) {
+    this._send([5, mask, x >> 8, x & 255, y >> 8, y & 255]);
+  }
+
+  _listen() {
+    const c = this._canvas;
+    c.addEventListener("keydown", (e) => {
+      const sym = this._pressed.get(e.code) || keysym(e);
+      if (!sym) return;
+      e.preventDefault();
+      this._pressed.set(e.code, sym);
+      this._key(sym, true);
+    });
+    c.addEventListener("keyup", (e) => {
+      const sym = this._pressed.get(e.code) || keysym(e);
+      if (!sym) return;
+      e.preventDefault();
+      this._pressed.delete(e.code);
+      this._key(sym, false);
+    });
+    c.addEventListener("blur", () => { // Don't leave keys stuck down in the guest
+      for (const sym of this._pressed.values()) this._key(sym, false);
+      this._pressed.clear();
+    });
+
+    // Browser buttons (left 1, right 2, middle 4) -> RFB (left 1, middle 2, right 4)
+    const mask = (b) => (b & 1) | (b & 4 ? 2 : 0) | (b & 2 ? 4 : 0);
+    const pos = (e) => {
+      const rect = c.getBoundingClientRect();
+      return [
+        Math.max(0, Math.min(c.width - 1, Math.floor((e.clientX - rect.left) * c.width / rect.width))),
+        Math.max(0, Math.min(c.height - 1, Math.floor((e.clientY - rect.top) * c.height / rect.height))),
+      ];
+    };
+    for (const type of ["mousedown", "mouseup", "mousemove"]) {
+      c.addEventListener(type, (e) => {
+        if (type === "mousedown") c.focus();
+        e.preventDefault();
+        this._buttons = mask(e.buttons);
+        this._pointer(...pos(e), this._buttons);
+      });
+    }
+    c.addEventListener("wheel", (e) => {
+      e.preventDefault();
+      const button = e.deltaY < 0 ? 8 : 16; // Buttons 4 and 5
+      const [x, y] = pos(e);
+      this._pointer(x, y, this._buttons | button);
+      this._pointer(x, y, this._buttons);
+    }, { passive: false });
+    c.addEventListener("contextmenu", (e) => e.preventDefault());
+    this._target.ownerDocument.defaultView?.addEventListener("resize", () => this._fit());
+  }
+
+  _resize(width, height) {
+    this._canvas.width = width;
+    this._canvas.height = height;
+    this._fit();
+  }
+
+  // _fit scales the canvas down (never up) to the target when scaleViewport is on
+  _fit() {
+    const c = this._canvas;
+    if (!this._scale || !c.width || !c.height) {
+      c.style.width = c.style.height = "";
+      return;
+    }
+    const s = Math.min(1, this._target.clientWidth / c.width, this._target.clientHeight / c.height);
+    c.style.width = Math.floor(c.width * s) + "px";
+    c.style.height = Math.floor(c.height * s) + "px";
+  }
+}
@@ -0,0 +1,84 @@
+// Package console issues short-lived console tokens and proxies browser
+// websockets to the VM consoles that libvirt only exposes on localhost.
+package console
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTTL only has to cover opening the page; a live connection isn't cut off
+const DefaultTokenTTL = 2 * time.Minute
+
+var ErrInvalidToken = errors.New("invalid or expired console token")
+
+// Claims is what a token vouches for
+type Claims struct {
+	VM      string `json:"vm"`
+	Kind    string `json:"kind"` // "vnc"
+	Expires int64  `json:"exp"`
+}
+
+// Signer signs tokens with a host-local secret
+type Signer struct {
+	key []byte
+}
+
+// NewSigner loads the HMAC key from keyPath, generating it on first use
+func NewSigner(keyPath string) (*Signer, error) {
+	key, err := os.ReadFile(keyPath)
+	if err == nil && len(key) >= 32 {
+		return &Signer{key: key}, nil
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save console key: %w", err)
+	}
+	return &Signer{key: key}, nil
+}
+
+// Issue returns a signed token for one VM console
+func (s *Signer) Issue(vmID, kind string, ttl time.Duration) (string, time.Time) {
+	expires := time.Now().Add(ttl)
+	payload, _ := json.Marshal(Claims{VM: vmID, Kind: kind, Expires: expires.Unix()})
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + s.sign(body), expires
+}
+
+// Verify checks signature, kind and expiry and returns the VM it is for
+func (s *Signer) Verify(token, kind string) (string, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(body))) {
+		return "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return "", ErrInvalidToken
+	}
+	if c.Kind != kind || time.Now().Unix() > c.Expires {
+		return "", ErrInvalidToken
+	}
+	return c.VM, nil
+}
+
+func (s *Signer) sign(body string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
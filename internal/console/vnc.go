@@ -0,0 +1,89 @@
+package console
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+//go:embed static
+var static embed.FS
+
+// TargetFunc finds where a VM's console listens on the host
+type TargetFunc func(vmID string) (core.ConsoleInfo, error)
+
+// VNCPage serves the embedded console page. It reads the token from the query
+// and the VNC password from the URL fragment, which never reaches the server.
+func VNCPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := static.ReadFile("static/vnc.html")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(page)
+	}
+}
+
+// Assets serves the console's scripts (mounted at /console/static/)
+func Assets() http.Handler {
+	files, _ := fs.Sub(static, "static")
+	return http.StripPrefix("/console/static/", http.FileServer(http.FS(files)))
+}
+
+// handshake accepts any client, browser or not: the token is the only gate
+// (no Origin check). The "binary" subprotocol is confirmed when asked for.
+func handshake(cfg *websocket.Config, r *http.Request) error {
+	for _, p := range cfg.Protocol {
+		if p == "binary" {
+			cfg.Protocol = []string{"binary"}
+			return nil
+		}
+	}
+	cfg.Protocol = nil
+	return nil
+}
+
+// VNCProxy bridges the console's websocket to the VM's localhost VNC port
+func VNCProxy(signer *Signer, target TargetFunc) http.Handler {
+	return websocket.Server{
+		Handshake: handshake,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			vmID, err := signer.Verify(ws.Request().URL.Query().Get("token"), "vnc")
+			if err != nil {
+				return
+			}
+			info, err := target(vmID)
+			if err != nil {
+				fmt.Printf("❌ Console for %s unavailable: %v\n", vmID, err)
+				return
+			}
+
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(info.Host, fmt.Sprint(info.Port)), 5*time.Second)
+			if err != nil {
+				fmt.Printf("❌ VNC dial failed for %s: %v\n", vmID, err)
+				return
+			}
+			defer conn.Close()
+
+			ws.PayloadType = websocket.BinaryFrame
+			pipe(ws, conn)
+		},
+	}
+}
+
+// pipe copies both ways until either side hangs up
+func pipe(a, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	go func() { _, _ = io.Copy(a, b); done <- struct{}{} }()
+	go func() { _, _ = io.Copy(b, a); done <- struct{}{} }()
+	<-done
+}
@@ -1,23 +1,118 @@
 package core
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrGuestAgentUnavailable means qemu-guest-agent isn't configured or isn't answering inside the VM
+var ErrGuestAgentUnavailable = errors.New("guest agent unavailable (is qemu-guest-agent installed and running in the VM?)")
+
 // VMConfig defines the "Order" from the user
 type VMConfig struct {
 	Name     string
 	Image    string // Logical name: "ubuntu-24.04"
 	CPUCores int
-	RAM      int // MB
-	DiskSize int // GB
-	Network  string
-	UserData string // Cloud-init
+	RAM      int      // MB
+	DiskSize int      // GB
+	Networks []string // One NIC each: "default" (NAT), "network:<name>" or a bridge like "br0"
+	UserData string   // Cloud-init
 	MetaData string
+
+	OnStep func(step string) // Optional: called as each provisioning step starts
+}
+
+// networkName is "default", "network:<libvirt network>" or a host bridge
+var networkName = regexp.MustCompile(`^(network:)?[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// CheckNetwork rejects NIC names that aren't a plain network or bridge name
+func CheckNetwork(network string) error {
+	if !networkName.MatchString(network) {
+		return fmt.Errorf("invalid network %q: use default, network:<name> or a bridge like br0 (letters, digits, '-', '_', '.')", network)
+	}
+	return nil
+}
+
+// StepError reports which step of a multi-step operation failed
+// (everything earlier steps created has already been rolled back)
+type StepError struct {
+	Step string
+	Err  error
 }
 
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step '%s' failed: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
 // VMState defines what a running VM looks like
 type VMState struct {
 	ID     string
 	Name   string
 	Status string // RUNNING, STOPPED
 	IP     string
+	State  string        // Lifecycle state tracked by the manager (provisioning, rebuilding...)
+	Health *HealthReport `json:",omitempty"` // Only for VMs with a health check
+	Plan   string        `json:",omitempty"` // From the inventory
+	Image  string        `json:",omitempty"`
+	Tenant string        `json:",omitempty"` // Owner, from the inventory
+}
+
+// HealthResult is the outcome of one health probe
+type HealthResult struct {
+	Time      time.Time `json:"time"`
+	OK        bool      `json:"ok"`
+	Detail    string    `json:"detail,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+}
+
+// HealthReport summarises recent probes (most recent last)
+type HealthReport struct {
+	Status              string         `json:"status"` // healthy, unhealthy, unknown
+	ConsecutiveFailures int            `json:"consecutive_failures"`
+	History             []HealthResult `json:"history"`
+}
+
+// MaxGuestFileSize caps guest file transfers: the agent moves everything as base64 JSON
+const MaxGuestFileSize = 1 << 20
+
+// ExecRequest is a command to run inside the guest via the agent
+type ExecRequest struct {
+	Path    string   // "/bin/sh"
+	Args    []string // ["-c", "systemctl restart nginx"]
+	Input   []byte   // Optional stdin
+	Timeout time.Duration
+}
+
+// ExecResult is what came back from the guest
+type ExecResult struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	TimedOut bool   `json:"timed_out"` // The process may still be running in the guest
+}
+
+// ConsoleInfo is where a VM's graphical console listens on the host
+type ConsoleInfo struct {
+	Host     string
+	Port     int
+	Password string
+}
+
+// SerialInfo locates a VM's serial console on the host
+type SerialInfo struct {
+	PTY     string // "/dev/pts/3" (empty while the VM is off)
+	LogPath string // Everything the guest wrote to ttyS0
+}
+
+// DomainEvent is a lifecycle notification from the hypervisor
+type DomainEvent struct {
+	Domain string
+	Event  string // "Started", "Stopped", "Crashed", "Defined", "Undefined", "Reboot"...
+	Detail string // "Booted", "Destroyed", "Shutdown", "Failed"...
 }
 
 // HypervisorDriver is the Interface our Manager talks to
@@ -28,11 +123,32 @@ type HypervisorDriver interface {
 	CreateVM(config VMConfig) error
 	DeleteVM(id string) error
 	StartVM(id string) error
-	StopVM(id string) error
+	StopVM(id string) error     // Pulls the plug
+	ShutdownVM(id string) error // Asks the guest to power off (ACPI); it may ignore it
 	Reboot(id string) error
+	SetAutostart(id string, enabled bool) error // Start with the host
+	ResizeVM(config VMConfig) error             // New CPU/RAM/disk size; the domain must be shut off
+	RebuildVM(config VMConfig) error            // Fresh root disk + seed, same domain
+
+	// Recovery
+	RescueVM(config VMConfig) error // Boot config.Image, customer disk attached second
+	UnrescueVM(id string) error
+
+	// Guest Agent (no reboot needed)
+	SetUserPassword(id, user, password string) error
+	AddSSHKey(id, user, key string) error
+	GuestExec(id string, req ExecRequest) (ExecResult, error)
+	ReadGuestFile(id, path string) ([]byte, error)
+	WriteGuestFile(id, path string, data []byte) error
+	PingGuestAgent(id string) error
 
 	// Info
 	ListVMs() ([]string, error)
 	GetVMInfo(id string) (VMState, error)
 	GetMetrics(id string) (map[string]float64, error)
+	GetConsole(id string) (ConsoleInfo, error) // VNC, bound to localhost
+	GetSerial(id string) (SerialInfo, error)
+
+	// Events (out-of-band changes: guest shutdowns, crashes, admins using virsh)
+	WatchEvents(stop <-chan struct{}) (<-chan DomainEvent, error)
 }
@@ -0,0 +1,31 @@
+package core
+
+import "testing"
+
+func TestCheckNetwork(t *testing.T) {
+	cases := []struct {
+		network string
+		ok      bool
+	}{
+		{"default", true},
+		{"br0", true},
+		{"network:isolated", true},
+		{"network:lab-net_2.0", true},
+
+		{"", false},
+		{"network:", false},
+		{"-br0", false},
+		{"br0 ", false},
+		{"default'/><disk type='file'", false},
+		{"network:x'/>", false},
+		{"br<0>", false},
+		{"br0&", false},
+		{"network:network:x", false},
+		{"../etc", false},
+	}
+	for _, tc := range cases {
+		if err := CheckNetwork(tc.network); (err == nil) != tc.ok {
+			t.Errorf("CheckNetwork(%q) = %v, want ok=%v", tc.network, err, tc.ok)
+		}
+	}
+}
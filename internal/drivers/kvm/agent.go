@@ -0,0 +1,255 @@
+package kvm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+// SetUserPassword changes a guest account's password through qemu-guest-agent.
+// The request goes to virsh on stdin: as an argument (here or with "virsh
+// set-user-password") anyone on the host could read it in /proc.
+func (k *KVMDriver) SetUserPassword(id, user, password string) error {
+	if err := agentPing(id); err != nil {
+		return err
+	}
+	_, err := agentCommandPrivate(id, agentRequest("guest-set-user-password", map[string]any{
+		"username": user,
+		"password": base64.StdEncoding.EncodeToString([]byte(password)),
+		"crypted":  false,
+	}))
+	return err
+}
+
+// AddSSHKey appends a public key to the user's authorized_keys through qemu-guest-agent
+func (k *KVMDriver) AddSSHKey(id, user, key string) error {
+	if err := agentPing(id); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "vps-sshkey-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.WriteString(strings.TrimSpace(key) + "\n")
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.Command("virsh", "set-user-sshkeys", id, user, "--file", f.Name()).CombinedOutput()
+	if err != nil {
+		return agentError("set-user-sshkeys", out)
+	}
+	return nil
+}
+
+// --- PRIVATE HELPERS ---
+
+// agentCommand sends a raw QMP-style request to the guest agent
+func agentCommand(id, request string) ([]byte, error) {
+	out, err := exec.Command("virsh", "qemu-agent-command", id, request, "--timeout", "5").CombinedOutput()
+	if err != nil {
+		return nil, agentError("qemu-agent-command", out)
+	}
+	return out, nil
+}
+
+// agentCommandPrivate is agentCommand for requests carrying secrets: they're
+// written to virsh's stdin and never appear on its command line. virsh exits
+// 0 in that mode whatever happens, so only an agent answer counts as success.
+func agentCommandPrivate(id, request string) ([]byte, error) {
+	line, err := virshLine("qemu-agent-command", id, request, "--timeout", "5")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("virsh", "-q")
+	cmd.Stdin = strings.NewReader(line)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	var resp struct {
+		Return json.RawMessage `json:"return"`
+	}
+	if err != nil || json.Unmarshal(out, &resp) != nil || resp.Return == nil {
+		return nil, agentError("qemu-agent-command", append(stderr.Bytes(), out...))
+	}
+	return out, nil
+}
+
+// virshLine quotes args for virsh's command shell. Inside single quotes
+// nothing is special, so only values without quotes or newlines can pass.
+// Plain words (commands, --options) stay unquoted.
+func virshLine(args ...string) (string, error) {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		switch {
+		case strings.ContainsAny(a, "'\n\r"):
+			return "", fmt.Errorf("can't pass %q to virsh", args[0])
+		case virshWord.MatchString(a):
+			quoted[i] = a
+		default:
+			quoted[i] = "'" + a + "'"
+		}
+	}
+	return strings.Join(quoted, " ") + "\n", nil
+}
+
+var virshWord = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// PingGuestAgent checks that qemu-guest-agent answers (health checks)
+func (k *KVMDriver) PingGuestAgent(id string) error {
+	return agentPing(id)
+}
+
+func agentPing(id string) error {
+	_, err := agentCommand(id, `{"execute":"guest-ping"}`)
+	return err
+}
+
+// agentError turns virsh's agent complaints into ErrGuestAgentUnavailable
+func agentError(op string, out []byte) error {
+	msg := strings.TrimSpace(string(out))
+	lower := strings.ToLower(msg)
+	if strings.Contains(lower, "agent") && (strings.Contains(lower, "not responding") ||
+		strings.Contains(lower, "not connected") ||
+		strings.Contains(lower, "not configured") ||
+		strings.Contains(lower, "not running")) {
+		return fmt.Errorf("%w: %s", core.ErrGuestAgentUnavailable, msg)
+	}
+	return fmt.Errorf("%s failed: %s", op, msg)
+}
+
+// The agent refuses reads/writes much bigger than this in one call
+const agentChunk = 48 * 1024
+
+// GuestExec runs a command through guest-exec and polls until it exits or times out
+func (k *KVMDriver) GuestExec(id string, req core.ExecRequest) (core.ExecResult, error) {
+	args := map[string]any{
+		"path":           req.Path,
+		"capture-output": true,
+	}
+	if len(req.Args) > 0 { // A nil slice would marshal to null, which the agent rejects
+		args["arg"] = req.Args
+	}
+	if len(req.Input) > 0 {
+		args["input-data"] = base64.StdEncoding.EncodeToString(req.Input)
+	}
+
+	var started struct {
+		PID int `json:"pid"`
+	}
+	if err := agentCall(id, "guest-exec", args, &started); err != nil {
+		return core.ExecResult{}, err
+	}
+
+	deadline := time.Now().Add(req.Timeout)
+	for {
+		var status struct {
+			Exited   bool   `json:"exited"`
+			ExitCode int    `json:"exitcode"`
+			OutData  string `json:"out-data"`
+			ErrData  string `json:"err-data"`
+		}
+		if err := agentCall(id, "guest-exec-status", map[string]any{"pid": started.PID}, &status); err != nil {
+			return core.ExecResult{}, err
+		}
+		if status.Exited {
+			stdout, _ := base64.StdEncoding.DecodeString(status.OutData)
+			stderr, _ := base64.StdEncoding.DecodeString(status.ErrData)
+			return core.ExecResult{ExitCode: status.ExitCode, Stdout: string(stdout), Stderr: string(stderr)}, nil
+		}
+		if time.Now().After(deadline) {
+			return core.ExecResult{ExitCode: -1, TimedOut: true}, nil
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// ReadGuestFile pulls a small file out of the guest
+func (k *KVMDriver) ReadGuestFile(id, path string) ([]byte, error) {
+	var handle int
+	if err := agentCall(id, "guest-file-open", map[string]any{"path": path, "mode": "r"}, &handle); err != nil {
+		return nil, err
+	}
+	defer func() { _ = agentCall(id, "guest-file-close", map[string]any{"handle": handle}, nil) }()
+
+	var data []byte
+	for {
+		var chunk struct {
+			Count int    `json:"count"`
+			Buf   string `json:"buf-b64"`
+			EOF   bool   `json:"eof"`
+		}
+		if err := agentCall(id, "guest-file-read", map[string]any{"handle": handle, "count": agentChunk}, &chunk); err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(chunk.Buf)
+		if err != nil {
+			return nil, fmt.Errorf("guest-file-read returned bad data: %w", err)
+		}
+		data = append(data, b...)
+		if len(data) > core.MaxGuestFileSize {
+			return nil, fmt.Errorf("file %s is larger than %d bytes", path, core.MaxGuestFileSize)
+		}
+		if chunk.EOF || chunk.Count == 0 {
+			return data, nil
+		}
+	}
+}
+
+// WriteGuestFile pushes a small file into the guest (replacing it)
+func (k *KVMDriver) WriteGuestFile(id, path string, data []byte) error {
+	if len(data) > core.MaxGuestFileSize {
+		return fmt.Errorf("file is larger than %d bytes", core.MaxGuestFileSize)
+	}
+
+	var handle int
+	if err := agentCall(id, "guest-file-open", map[string]any{"path": path, "mode": "w"}, &handle); err != nil {
+		return err
+	}
+	defer func() { _ = agentCall(id, "guest-file-close", map[string]any{"handle": handle}, nil) }()
+
+	for off := 0; off < len(data); off += agentChunk {
+		end := min(off+agentChunk, len(data))
+		req := map[string]any{"handle": handle, "buf-b64": base64.StdEncoding.EncodeToString(data[off:end])}
+		if err := agentCall(id, "guest-file-write", req, nil); err != nil {
+			return err
+		}
+	}
+	return agentCall(id, "guest-file-flush", map[string]any{"handle": handle}, nil)
+}
+
+// agentCall wraps agentCommand with JSON in/out for the "return" payload
+func agentCall(id, command string, args map[string]any, result any) error {
+	out, err := agentCommand(id, agentRequest(command, args))
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	var resp struct {
+		Return json.RawMessage `json:"return"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return fmt.Errorf("%s: bad agent response: %w", command, err)
+	}
+	return json.Unmarshal(resp.Return, result)
+}
+
+func agentRequest(command string, args map[string]any) string {
+	req, _ := json.Marshal(map[string]any{"execute": command, "arguments": args})
+	return string(req)
+}
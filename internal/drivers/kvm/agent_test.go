@@ -0,0 +1,22 @@
+package kvm
+
+import "testing"
+
+func TestVirshLine(t *testing.T) {
+	req := agentRequest("guest-set-user-password", map[string]any{"username": "ops", "password": "czNjcjN0", "crypted": false})
+	line, err := virshLine("qemu-agent-command", "web1", req, "--timeout", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `qemu-agent-command web1 '{"arguments":{"crypted":false,"password":"czNjcjN0","username":"ops"},"execute":"guest-set-user-password"}' --timeout 5` + "\n"
+	if line != want {
+		t.Fatalf("got  %s\nwant %s", line, want)
+	}
+
+	// A quote or newline would end the argument (or the command) early
+	for _, bad := range []string{"web1' 'x", "web1\nlist --all", "a\rb", "it's"} {
+		if _, err := virshLine("qemu-agent-command", bad, "{}"); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package kvm
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+// "event 'lifecycle' for domain 'web1': Stopped Crashed"
+// "event 'reboot' for domain 'web1'" (older virsh prints the name unquoted)
+var eventLineRe = regexp.MustCompile(`event '([a-z-]+)' for domain '?([^':]+)'?(?::\s*(\S+)(?:\s+(\S+))?)?`)
+
+// WatchEvents follows "virsh event --loop" and restarts it if it dies
+// (e.g. libvirtd restarted) until stop is closed.
+func (k *KVMDriver) WatchEvents(stop <-chan struct{}) (<-chan core.DomainEvent, error) {
+	if _, err := exec.LookPath("virsh"); err != nil {
+		return nil, fmt.Errorf("virsh not found in PATH")
+	}
+
+	out := make(chan core.DomainEvent, 64)
+	go func() {
+		defer close(out)
+		for {
+			if err := k.followEvents(stop, out); err != nil {
+				fmt.Printf("⚠️  libvirt event stream ended: %v (reconnecting)\n", err)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (k *KVMDriver) followEvents(stop <-chan struct{}, out chan<- core.DomainEvent) error {
+	cmd := exec.Command("virsh", "event", "--all", "--loop")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			_ = cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		m := eventLineRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		e := core.DomainEvent{Domain: m[2], Event: m[3], Detail: m[4]}
+		switch m[1] {
+		case "lifecycle":
+		case "reboot":
+			e.Event = "Reboot"
+		default:
+			continue // Device, balloon, tray... events don't change our state
+		}
+		out <- e
+	}
+	return cmd.Wait()
+}
@@ -1,10 +1,15 @@
 package kvm
 
 import (
+	"crypto/rand"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/Shaman786/vps-manager/internal/core"
@@ -27,31 +32,76 @@ func NewKVMDriver(store *images.Store, diskDir, confDir string) *KVMDriver {
 
 func (k *KVMDriver) Name() string { return "KVM-Libvirt-v2" }
 
+// CreateVM provisions as ordered steps. If one fails, everything the earlier
+// steps created is removed again so a retry with the same name starts clean.
 func (k *KVMDriver) CreateVM(cfg core.VMConfig) error {
-	// 1. Resolve Image
-	imgInfo, err := k.ImageStore.Resolve(cfg.Image)
-	if err != nil {
-		return fmt.Errorf("image resolve failed: %w", err)
-	}
-
-	// 2. Prepare Paths
+	var imgInfo images.ImageInfo
 	diskPath := filepath.Join(k.DiskDir, cfg.Name+".qcow2")
-	sizeStr := fmt.Sprintf("%dG", cfg.DiskSize)
-
-	// 3. Create Disk
-	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", imgInfo.LocalPath, diskPath, sizeStr)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("disk create failed: %s", string(out))
-	}
+	isoPath := filepath.Join(k.ConfigDir, cfg.Name+"-cidata.iso")
 
-	// 4. Cloud-Init
-	isoPath, err := k.createCloudInitISO(cfg.Name, cfg.UserData, cfg.MetaData)
-	if err != nil {
-		return err
+	steps := []step{
+		{
+			name: "check name",
+			do: func() error {
+				if exec.Command("virsh", "dominfo", cfg.Name).Run() == nil {
+					return fmt.Errorf("vm '%s' already exists", cfg.Name)
+				}
+				return nil
+			},
+		},
+		{
+			name: "check tools",
+			do: func() error {
+				for _, tool := range []string{"qemu-img", "cloud-localds", "virsh"} {
+					if _, err := exec.LookPath(tool); err != nil {
+						return fmt.Errorf("%s not found in PATH", tool)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "resolve image",
+			do: func() (err error) {
+				imgInfo, err = k.ImageStore.Resolve(cfg.Image)
+				return err
+			},
+		},
+		{
+			name: "create disk",
+			do: func() error {
+				sizeStr := fmt.Sprintf("%dG", cfg.DiskSize)
+				cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", imgInfo.LocalPath, diskPath, sizeStr)
+				if out, err := cmd.CombinedOutput(); err != nil {
+					return fmt.Errorf("qemu-img: %s", strings.TrimSpace(string(out)))
+				}
+				return nil
+			},
+			undo: func() { _ = os.Remove(diskPath) },
+		},
+		{
+			name: "create cloud-init seed",
+			do: func() error {
+				_, err := k.createCloudInitISO(cfg.Name, cfg.UserData, cfg.MetaData)
+				return err
+			},
+			undo: func() { _ = os.Remove(isoPath) },
+		},
+		{
+			name: "define domain",
+			do: func() error {
+				return defineXML(k.domainXML(cfg.Name, cfg.RAM, cfg.CPUCores, diskPath, isoPath, cfg.Networks))
+			},
+			undo: func() { _ = exec.Command("virsh", "undefine", cfg.Name).Run() },
+		},
+		{
+			name: "start domain",
+			do:   func() error { return virsh("start", cfg.Name) },
+			undo: func() { _ = exec.Command("virsh", "destroy", cfg.Name).Run() },
+		},
 	}
 
-	// 5. Launch
-	return k.launchWithXML(cfg.Name, cfg.RAM, cfg.CPUCores, diskPath, isoPath, cfg.Network)
+	return runSteps(steps, cfg.OnStep)
 }
 
 func (k *KVMDriver) DeleteVM(id string) error {
@@ -59,6 +109,12 @@ func (k *KVMDriver) DeleteVM(id string) error {
 	_ = exec.Command("virsh", "undefine", id).Run()
 	_ = os.Remove(filepath.Join(k.DiskDir, id+".qcow2"))
 	_ = os.Remove(filepath.Join(k.ConfigDir, id+"-cidata.iso"))
+	_ = os.Remove(k.normalXMLPath(id))
+	rotated, _ := filepath.Glob(k.consoleLogPath(id) + "*")
+	for _, f := range rotated {
+		_ = os.Remove(f)
+	}
+	k.removeRescueFiles(id)
 	return nil
 }
 
@@ -70,10 +126,79 @@ func (k *KVMDriver) StopVM(id string) error {
 	return exec.Command("virsh", "destroy", id).Run()
 }
 
+func (k *KVMDriver) ShutdownVM(id string) error {
+	return virsh("shutdown", id)
+}
+
 func (k *KVMDriver) Reboot(id string) error {
 	return exec.Command("virsh", "reboot", id).Run()
 }
 
+func (k *KVMDriver) SetAutostart(id string, enabled bool) error {
+	if enabled {
+		return virsh("autostart", id)
+	}
+	return virsh("autostart", "--disable", id)
+}
+
+// RebuildVM swaps the root overlay and cloud-init seed of an existing domain.
+// The domain XML is left alone, so MAC, networks and extra disks survive.
+func (k *KVMDriver) RebuildVM(cfg core.VMConfig) error {
+	if err := exec.Command("virsh", "dominfo", cfg.Name).Run(); err != nil {
+		return fmt.Errorf("vm '%s' not found", cfg.Name)
+	}
+
+	// 1. Resolve the new image before touching anything
+	imgInfo, err := k.ImageStore.Resolve(cfg.Image)
+	if err != nil {
+		return fmt.Errorf("image resolve failed: %w", err)
+	}
+
+	diskPath := filepath.Join(k.DiskDir, cfg.Name+".qcow2")
+
+	// 2. Keep the current size when the caller doesn't know the plan
+	diskSize := cfg.DiskSize
+	if diskSize == 0 {
+		if diskSize, err = virtualSizeGB(diskPath); err != nil {
+			return err
+		}
+	}
+
+	// 3. Build the new overlay next to the old one; until it exists the
+	// VM keeps its disk
+	newPath := diskPath + ".new"
+	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", imgInfo.LocalPath, newPath, fmt.Sprintf("%dG", diskSize))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("disk create failed: %s", string(out))
+	}
+
+	// 4. Build the new seed next to the old one as well
+	newSeed, err := k.createCloudInitISO(cfg.Name+"-rebuild", cfg.UserData, cfg.MetaData)
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	seedPath := filepath.Join(k.ConfigDir, cfg.Name+"-cidata.iso")
+
+	// 5. Power off and swap both in (same paths, so the domain XML still
+	// points at them)
+	_ = exec.Command("virsh", "destroy", cfg.Name).Run()
+	if err := os.Rename(newPath, diskPath); err != nil {
+		os.Remove(newPath)
+		os.Remove(newSeed)
+		_ = exec.Command("virsh", "start", cfg.Name).Run()
+		return &core.StepError{Step: "swap disk", Err: fmt.Errorf("%w (the VM still has its old disk and was started again)", err)}
+	}
+	if err := os.Rename(newSeed, seedPath); err != nil {
+		os.Remove(newSeed)
+		_ = exec.Command("virsh", "start", cfg.Name).Run()
+		return &core.StepError{Step: "swap seed", Err: fmt.Errorf("%w (the VM has the new disk but its old cloud-init seed, and was started again)", err)}
+	}
+
+	return exec.Command("virsh", "start", cfg.Name).Run()
+}
+
 func (k *KVMDriver) ListVMs() ([]string, error) {
 	out, err := exec.Command("virsh", "list", "--all", "--name").Output()
 	if err != nil {
@@ -109,8 +234,72 @@ func (k *KVMDriver) GetVMInfo(id string) (core.VMState, error) {
 	}, nil
 }
 
+// GetMetrics returns raw counters from virsh domstats:
+// cpu_time_ns (cumulative), vcpus, mem_kb (balloon size), mem_rss_kb and,
+// when the guest reports balloon stats, mem_used_kb.
+// CPU usage is the change of cpu_time_ns between two samples.
 func (k *KVMDriver) GetMetrics(id string) (map[string]float64, error) {
-	return map[string]float64{}, nil
+	out, err := exec.Command("virsh", "domstats", "--cpu-total", "--balloon", "--vcpu", id).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("domstats failed: %s", strings.TrimSpace(string(out)))
+	}
+	raw := map[string]float64{}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			raw[key] = f
+		}
+	}
+
+	metrics := map[string]float64{}
+	copyStat := func(from, to string) {
+		if v, ok := raw[from]; ok {
+			metrics[to] = v
+		}
+	}
+	copyStat("cpu.time", "cpu_time_ns")
+	copyStat("vcpu.current", "vcpus")
+	copyStat("balloon.current", "mem_kb")
+	copyStat("balloon.rss", "mem_rss_kb")
+	if avail, ok := raw["balloon.available"]; ok {
+		if unused, ok := raw["balloon.unused"]; ok {
+			metrics["mem_used_kb"] = avail - unused
+		}
+	}
+	return metrics, nil
+}
+
+// GetConsole finds the VNC port and password (the console proxy connects on the VM's behalf)
+func (k *KVMDriver) GetConsole(id string) (core.ConsoleInfo, error) {
+	out, err := exec.Command("virsh", "vncdisplay", id).CombinedOutput()
+	if err != nil {
+		return core.ConsoleInfo{}, fmt.Errorf("vncdisplay failed: %s", strings.TrimSpace(string(out)))
+	}
+
+	// "127.0.0.1:3" or ":3" -> display 3 -> port 5903
+	display := strings.TrimSpace(string(out))
+	host, num, found := strings.Cut(display, ":")
+	if !found {
+		return core.ConsoleInfo{}, fmt.Errorf("unexpected vncdisplay output: %q", display)
+	}
+	if host == "" || host == "0.0.0.0" {
+		host = "127.0.0.1"
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return core.ConsoleInfo{}, fmt.Errorf("unexpected vncdisplay output: %q", display)
+	}
+
+	xml, _ := exec.Command("virsh", "dumpxml", "--security-info", id).Output()
+	password := ""
+	if m := vncPasswdRe.FindSubmatch(xml); m != nil {
+		password = string(m[1])
+	}
+
+	return core.ConsoleInfo{Host: host, Port: 5900 + n, Password: password}, nil
 }
 
 // --- PRIVATE HELPERS ---
@@ -135,6 +324,59 @@ func (k *KVMDriver) createCloudInitISO(name, user, meta string) (string, error)
 	return isoPath, nil
 }
 
+// virtualSizeGB reads the virtual size of a qcow2 image, rounded up to whole GB
+func virtualSizeGB(path string) (int, error) {
+	out, err := exec.Command("qemu-img", "info", "--output=json", "-U", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("qemu-img info failed for %s: %w", path, err)
+	}
+	var info struct {
+		VirtualSize int64 `json:"virtual-size"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return 0, fmt.Errorf("qemu-img info parse failed: %w", err)
+	}
+	const gb = 1 << 30
+	return int((info.VirtualSize + gb - 1) / gb), nil
+}
+
+// GetSerial finds the pty libvirt allocated for the serial console and the log it tees into
+func (k *KVMDriver) GetSerial(id string) (core.SerialInfo, error) {
+	xml, err := exec.Command("virsh", "dumpxml", id).Output()
+	if err != nil {
+		return core.SerialInfo{}, fmt.Errorf("vm '%s' not found", id)
+	}
+	info := core.SerialInfo{LogPath: k.consoleLogPath(id)}
+	if block := serialBlockRe.FindSubmatch(xml); block != nil {
+		if m := sourcePathRe.FindSubmatch(block[1]); m != nil {
+			info.PTY = string(m[1])
+		}
+	}
+	return info, nil
+}
+
+func (k *KVMDriver) consoleLogPath(name string) string {
+	return filepath.Join(k.ConfigDir, name+"-console.log")
+}
+
+var (
+	serialBlockRe = regexp.MustCompile(`(?s)<serial type=.pty.>(.*?)</serial>`)
+	sourcePathRe  = regexp.MustCompile(`<source path=['"]([^'"]+)['"]`)
+)
+
+var vncPasswdRe = regexp.MustCompile(`<graphics type=.vnc.[^>]*passwd=['"]([^'"]*)['"]`)
+
+// vncPassword generates a per-VM VNC password (VNC only uses the first 8 characters)
+func vncPassword() string {
+	const chars = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return string(b)
+}
+
 // Helper to find QEMU binary on different distros
 func detectEmulator() string {
 	// Rocky/RHEL/CentOS
@@ -145,16 +387,20 @@ func detectEmulator() string {
 	return "/usr/bin/qemu-system-x86_64"
 }
 
-func (k *KVMDriver) launchWithXML(name string, ramMB, cpu int, disk, iso, bridge string) error {
+func (k *KVMDriver) domainXML(name string, ramMB, cpu int, disk, iso string, networks []string) string {
 	ramKB := ramMB * 1024
 	emulator := detectEmulator() // <--- SMART DETECTION HERE
 
-	netXML := "<interface type='network'><source network='default'/><model type='virtio'/></interface>"
-	if bridge != "default" && bridge != "" {
-		netXML = fmt.Sprintf("<interface type='bridge'><source bridge='%s'/><model type='virtio'/></interface>", bridge)
+	netXML := interfaceXML("default")
+	if len(networks) > 0 {
+		var nics []string
+		for _, n := range networks {
+			nics = append(nics, interfaceXML(n))
+		}
+		netXML = strings.Join(nics, "\n    ")
 	}
 
-	xml := fmt.Sprintf(`
+	return fmt.Sprintf(`
 <domain type='kvm'>
   <name>%s</name>
   <memory unit='KiB'>%d</memory>
@@ -175,15 +421,37 @@ func (k *KVMDriver) launchWithXML(name string, ramMB, cpu int, disk, iso, bridge
       <readonly/>
     </disk>
     %s
+    <serial type='pty'><log file='%s' append='on'/><target port='0'/></serial>
     <console type='pty'><target type='serial' port='0'/></console>
-    <graphics type='vnc' port='-1' autoport='yes' listen='0.0.0.0'/>
+    <channel type='unix'><target type='virtio' name='org.qemu.guest_agent.0'/></channel>
+    <graphics type='vnc' port='-1' autoport='yes' listen='127.0.0.1' passwd='%s'/>
   </devices>
-</domain>`, name, ramKB, cpu, emulator, disk, iso, netXML)
+</domain>`, xmlEscape(name), ramKB, cpu, xmlEscape(emulator), xmlEscape(disk), xmlEscape(iso), netXML, xmlEscape(k.consoleLogPath(name)), xmlEscape(vncPassword()))
+}
 
+// interfaceXML: "default" and "network:<name>" are libvirt networks, anything else a host bridge
+func interfaceXML(network string) string {
+	if network == "" || network == "default" {
+		network = "network:default"
+	}
+	if name, ok := strings.CutPrefix(network, "network:"); ok {
+		return fmt.Sprintf("<interface type='network'><source network='%s'/><model type='virtio'/></interface>", xmlEscape(name))
+	}
+	return fmt.Sprintf("<interface type='bridge'><source bridge='%s'/><model type='virtio'/></interface>", xmlEscape(network))
+}
+
+// xmlEscape makes a value safe inside an XML element or quoted attribute
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func defineXML(xml string) error {
 	cmd := exec.Command("virsh", "define", "/dev/stdin")
 	cmd.Stdin = strings.NewReader(xml)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("virsh define failed: %s", string(out))
 	}
-	return exec.Command("virsh", "start", name).Run()
+	return nil
 }
@@ -0,0 +1,137 @@
+package kvm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+// RescueVM boots the domain from a throwaway overlay of the rescue image.
+// The customer's disk is kept attached as a secondary device so it can be
+// mounted and repaired. The normal definition is saved so UnrescueVM can
+// put it back untouched.
+func (k *KVMDriver) RescueVM(cfg core.VMConfig) error {
+	normalXML := k.normalXMLPath(cfg.Name)
+	if _, err := os.Stat(normalXML); err == nil {
+		return fmt.Errorf("vm '%s' is already in rescue mode", cfg.Name)
+	}
+
+	// 1. Save the normal definition
+	xml, err := exec.Command("virsh", "dumpxml", "--inactive", "--security-info", cfg.Name).Output()
+	if err != nil {
+		return fmt.Errorf("vm '%s' not found", cfg.Name)
+	}
+
+	imgInfo, err := k.ImageStore.Resolve(cfg.Image)
+	if err != nil {
+		return fmt.Errorf("rescue image resolve failed: %w", err)
+	}
+
+	// 2. Rescue overlay + seed
+	diskPath := filepath.Join(k.DiskDir, cfg.Name+".qcow2")
+	rescueDisk := filepath.Join(k.DiskDir, cfg.Name+"-rescue.qcow2")
+	_ = os.Remove(rescueDisk)
+	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", imgInfo.LocalPath, rescueDisk)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rescue disk create failed: %s", string(out))
+	}
+
+	rescueISO, err := k.createCloudInitISO(cfg.Name+"-rescue", cfg.UserData, cfg.MetaData)
+	if err != nil {
+		_ = os.Remove(rescueDisk)
+		return err
+	}
+
+	// 3. Rewrite: rescue disk on vda, rescue seed on the cdrom, customer disk on a free vdX
+	rescueXML := string(xml)
+	rescueXML = replaceSource(rescueXML, diskPath, rescueDisk)
+	rescueXML = replaceSource(rescueXML, filepath.Join(k.ConfigDir, cfg.Name+"-cidata.iso"), rescueISO)
+	customerDisk := fmt.Sprintf(`<disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='%s'/>
+      <target dev='%s' bus='virtio'/>
+    </disk>
+  </devices>`, xmlEscape(diskPath), freeVirtioTarget(rescueXML))
+	rescueXML = strings.Replace(rescueXML, "</devices>", customerDisk, 1)
+
+	if err := os.WriteFile(normalXML, xml, 0600); err != nil {
+		k.removeRescueFiles(cfg.Name)
+		return fmt.Errorf("failed to save normal definition: %w", err)
+	}
+
+	// 4. Swap definitions and boot
+	wasRunning := isRunning(cfg.Name)
+	_ = exec.Command("virsh", "destroy", cfg.Name).Run()
+	if err := defineXML(rescueXML); err != nil {
+		// Put back what we saved and power it on again if it was running
+		_ = defineXML(string(xml))
+		if wasRunning {
+			_ = exec.Command("virsh", "start", cfg.Name).Run()
+		}
+		_ = os.Remove(normalXML)
+		k.removeRescueFiles(cfg.Name)
+		return err
+	}
+	return exec.Command("virsh", "start", cfg.Name).Run()
+}
+
+// UnrescueVM restores the saved definition and boots from the customer disk again
+func (k *KVMDriver) UnrescueVM(id string) error {
+	normalXML := k.normalXMLPath(id)
+	xml, err := os.ReadFile(normalXML)
+	if err != nil {
+		return fmt.Errorf("vm '%s' is not in rescue mode", id)
+	}
+
+	wasRunning := isRunning(id)
+	_ = exec.Command("virsh", "destroy", id).Run()
+	if err := defineXML(string(xml)); err != nil {
+		// The rescue definition is still in place (and the saved one kept
+		// for a retry): boot it again if it was running
+		if wasRunning {
+			_ = exec.Command("virsh", "start", id).Run()
+		}
+		return err
+	}
+
+	k.removeRescueFiles(id)
+	_ = os.Remove(normalXML)
+	return exec.Command("virsh", "start", id).Run()
+}
+
+// --- PRIVATE HELPERS ---
+
+func (k *KVMDriver) normalXMLPath(name string) string {
+	return filepath.Join(k.ConfigDir, name+"-normal.xml")
+}
+
+func isRunning(id string) bool {
+	state, _ := exec.Command("virsh", "domstate", id).Output()
+	return strings.TrimSpace(string(state)) == "running"
+}
+
+func (k *KVMDriver) removeRescueFiles(name string) {
+	_ = os.Remove(filepath.Join(k.DiskDir, name+"-rescue.qcow2"))
+	_ = os.Remove(filepath.Join(k.ConfigDir, name+"-rescue-cidata.iso"))
+}
+
+// libvirt writes single quotes, hand-written XML may not
+func replaceSource(xml, oldPath, newPath string) string {
+	oldPath, newPath = xmlEscape(oldPath), xmlEscape(newPath)
+	xml = strings.ReplaceAll(xml, fmt.Sprintf("file='%s'", oldPath), fmt.Sprintf("file='%s'", newPath))
+	return strings.ReplaceAll(xml, fmt.Sprintf(`file="%s"`, oldPath), fmt.Sprintf("file='%s'", newPath))
+}
+
+func freeVirtioTarget(xml string) string {
+	for c := 'b'; c <= 'z'; c++ {
+		dev := fmt.Sprintf("vd%c", c)
+		if !strings.Contains(xml, "dev='"+dev+"'") && !strings.Contains(xml, `dev="`+dev+`"`) {
+			return dev
+		}
+	}
+	return "vdz"
+}
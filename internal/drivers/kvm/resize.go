@@ -0,0 +1,70 @@
+package kvm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+var (
+	memoryRe        = regexp.MustCompile(`<memory unit='KiB'>\d+</memory>`)
+	currentMemoryRe = regexp.MustCompile(`<currentMemory unit='KiB'>\d+</currentMemory>`)
+	vcpuRe          = regexp.MustCompile(`<vcpu( [^>]*)?>\d+</vcpu>`)
+)
+
+// ResizeVM rewrites CPU/RAM in the persistent definition and grows the root disk.
+// The domain has to be shut off: the new definition is used from the next start
+// and the disk can't safely grow under a running guest. Disks never shrink.
+func (k *KVMDriver) ResizeVM(cfg core.VMConfig) error {
+	state, err := exec.Command("virsh", "domstate", cfg.Name).Output()
+	if err != nil {
+		return fmt.Errorf("vm '%s' not found", cfg.Name)
+	}
+	if strings.TrimSpace(string(state)) != "shut off" {
+		return fmt.Errorf("vm '%s' must be shut off to resize", cfg.Name)
+	}
+	// Its current definition is the rescue one: that's not what should grow
+	if _, err := os.Stat(k.normalXMLPath(cfg.Name)); err == nil {
+		return fmt.Errorf("vm '%s' is in rescue mode, unrescue it before resizing", cfg.Name)
+	}
+
+	diskPath := filepath.Join(k.DiskDir, cfg.Name+".qcow2")
+	if cfg.DiskSize > 0 {
+		current, err := virtualSizeGB(diskPath)
+		if err != nil {
+			return err
+		}
+		if cfg.DiskSize < current {
+			return fmt.Errorf("disk can't shrink from %dG to %dG", current, cfg.DiskSize)
+		}
+		if cfg.DiskSize > current {
+			if out, err := exec.Command("qemu-img", "resize", diskPath, fmt.Sprintf("%dG", cfg.DiskSize)).CombinedOutput(); err != nil {
+				return fmt.Errorf("qemu-img resize: %s", strings.TrimSpace(string(out)))
+			}
+		}
+	}
+
+	if cfg.RAM == 0 && cfg.CPUCores == 0 {
+		return nil
+	}
+	out, err := exec.Command("virsh", "dumpxml", "--inactive", "--security-info", cfg.Name).Output()
+	if err != nil {
+		return fmt.Errorf("dumpxml failed: %w", err)
+	}
+	xml := string(out)
+	if cfg.RAM > 0 {
+		ramKB := cfg.RAM * 1024
+		xml = memoryRe.ReplaceAllString(xml, fmt.Sprintf("<memory unit='KiB'>%d</memory>", ramKB))
+		xml = currentMemoryRe.ReplaceAllString(xml, fmt.Sprintf("<currentMemory unit='KiB'>%d</currentMemory>", ramKB))
+	}
+	if cfg.CPUCores > 0 {
+		// Drops current='N' too, it could be above the new count
+		xml = vcpuRe.ReplaceAllString(xml, fmt.Sprintf("<vcpu placement='static'>%d</vcpu>", cfg.CPUCores))
+	}
+	return defineXML(xml)
+}
@@ -0,0 +1,44 @@
+package kvm
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+// step is one unit of a multi-step operation with its compensating action
+type step struct {
+	name string
+	do   func() error
+	undo func() // Optional: removes whatever do created
+}
+
+// runSteps executes steps in order. On failure the completed steps are
+// undone in reverse and the error names the step that failed.
+func runSteps(steps []step, progress func(string)) error {
+	for i, s := range steps {
+		if progress != nil {
+			progress(s.name)
+		}
+		if err := s.do(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if steps[j].undo != nil {
+					steps[j].undo()
+				}
+			}
+			return &core.StepError{Step: s.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// virsh runs a virsh subcommand and keeps its message on failure (not just "exit status 1")
+func virsh(args ...string) error {
+	out, err := exec.Command("virsh", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("virsh %s failed: %s", args[0], strings.TrimSpace(string(out)))
+	}
+	return nil
+}
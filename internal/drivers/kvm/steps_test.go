@@ -0,0 +1,61 @@
+package kvm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+func TestRunSteps(t *testing.T) {
+	cases := []struct {
+		name   string
+		failAt string // "" = every step succeeds
+		log    []string
+	}{
+		{"all succeed", "", []string{"do disk", "do seed", "do define", "do start"}},
+		{"first fails", "disk", []string{"do disk"}},
+		{"rolls back in reverse", "start", []string{"do disk", "do seed", "do define", "do start", "undo define", "undo disk"}},
+	}
+	for _, tc := range cases {
+		var log, progress []string
+		mk := func(name string, undo bool) step {
+			s := step{name: name, do: func() error {
+				log = append(log, "do "+name)
+				if name == tc.failAt {
+					return fmt.Errorf("%s broke", name)
+				}
+				return nil
+			}}
+			if undo { // The seed step has nothing to undo
+				s.undo = func() { log = append(log, "undo "+name) }
+			}
+			return s
+		}
+		steps := []step{mk("disk", true), mk("seed", false), mk("define", true), mk("start", true)}
+
+		err := runSteps(steps, func(msg string) { progress = append(progress, msg) })
+		var stepErr *core.StepError
+		switch {
+		case tc.failAt == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.failAt != "" && (!errors.As(err, &stepErr) || stepErr.Step != tc.failAt):
+			t.Errorf("%s: got %v, want a StepError for %s", tc.name, err, tc.failAt)
+		}
+		if !slices.Equal(log, tc.log) {
+			t.Errorf("%s: ran %q, want %q", tc.name, log, tc.log)
+		}
+		var started []string // Progress names each step as it starts
+		for _, entry := range tc.log {
+			if name, ok := strings.CutPrefix(entry, "do "); ok {
+				started = append(started, name)
+			}
+		}
+		if !slices.Equal(progress, started) {
+			t.Errorf("%s: progress %q, want %q", tc.name, progress, started)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package kvm
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+// The domain XML must hold whatever it's given as plain values, never as markup
+func TestDomainXMLEscapes(t *testing.T) {
+	k := &KVMDriver{DiskDir: "/var/lib/vps", ConfigDir: "/etc/vps"}
+	evil := "default'/></interface><disk type='file' device='disk'><source file='/etc/shadow'/>"
+	doc := k.domainXML("web&1", 1024, 1, "/var/lib/vps/web1.qcow2", "/etc/vps/web1-cidata.iso",
+		[]string{"network:" + evil, evil})
+
+	var domain struct {
+		Name    string `xml:"name"`
+		Devices struct {
+			Disks []struct {
+				Source struct {
+					File string `xml:"file,attr"`
+				} `xml:"source"`
+			} `xml:"disk"`
+			Interfaces []struct {
+				Source struct {
+					Network string `xml:"network,attr"`
+					Bridge  string `xml:"bridge,attr"`
+				} `xml:"source"`
+			} `xml:"interface"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(doc), &domain); err != nil {
+		t.Fatalf("not valid XML: %v\n%s", err, doc)
+	}
+	if domain.Name != "web&1" {
+		t.Errorf("name = %q", domain.Name)
+	}
+	if n := len(domain.Devices.Disks); n != 2 {
+		t.Errorf("%d disks, want the system disk and the seed:\n%s", n, doc)
+	}
+	nics := domain.Devices.Interfaces
+	if len(nics) != 2 || nics[0].Source.Network != evil || nics[1].Source.Bridge != evil {
+		t.Errorf("interfaces = %+v, want the names kept as values", nics)
+	}
+}
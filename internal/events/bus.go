@@ -0,0 +1,65 @@
+// Package events is a small in-process publish/subscribe bus. VM state
+// changes, image downloads and libvirt notifications all go through it so
+// API streams, audit and notifications can react without knowing each other.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is one thing that happened
+type Event struct {
+	Type    string    `json:"type"`    // "vm.state", "image.ready"...
+	Subject string    `json:"subject"` // VM or image name
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	Reason  string    `json:"reason,omitempty"` // Action or cause behind the change
+	Time    time.Time `json:"time"`
+}
+
+// Bus fans events out to subscribers. Publishing never blocks: a
+// subscriber that falls behind loses events rather than stalling the VM code.
+type Bus struct {
+	subs map[int]chan Event
+	next int
+	mu   sync.Mutex
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]chan Event)}
+}
+
+// Publish sends e to every subscriber
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of future events and a cancel func
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	ch := make(chan Event, buffer)
+	b.subs[id] = ch
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[id]; ok {
+			delete(b.subs, id)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package fleet
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// Change actions, in the order Apply runs them (deletes first free up room)
+const (
+	ActionDelete  = "delete"
+	ActionResize  = "resize"
+	ActionRebuild = "rebuild"
+	ActionUpdate  = "update" // Labels / SSH keys, no downtime
+	ActionCreate  = "create"
+)
+
+// Change is one step towards the spec
+type Change struct {
+	Action  string
+	Name    string
+	Details []string // Human readable: "plan Starter -> Professional"
+	Spec    VMSpec   // Desired state (empty for deletes)
+}
+
+// Plan compares the spec with the live VMs and the inventory.
+// VMs that aren't in the spec are only deleted when prune is set.
+func Plan(b backend.Backend, spec *Spec, prune bool) ([]Change, []string, error) {
+	vms, err := b.ListVMs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list vms: %w", err)
+	}
+	var live []string
+	for _, v := range vms {
+		live = append(live, v.Name)
+	}
+
+	var changes []Change
+	var warnings []string
+	wanted := map[string]bool{}
+
+	for _, want := range spec.VMs {
+		wanted[want.Name] = true
+		if !slices.Contains(live, want.Name) {
+			changes = append(changes, Change{Action: ActionCreate, Name: want.Name, Spec: want,
+				Details: []string{fmt.Sprintf("%s on %s", want.Plan, want.Image)}})
+			continue
+		}
+
+		info, err := b.VMInfo(want.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		var rec vm.Record
+		known := info.Record != nil
+		if known {
+			rec = *info.Record
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: not in the inventory (created outside vps-manager?), plan and image can't be compared", want.Name))
+		}
+
+		if known && rec.Plan != "" && !strings.EqualFold(rec.Plan, want.Plan) {
+			changes = append(changes, Change{Action: ActionResize, Name: want.Name, Spec: want,
+				Details: []string{fmt.Sprintf("plan %s -> %s", rec.Plan, want.Plan)}})
+		}
+		if known && rec.Image != "" && rec.Image != want.Image {
+			changes = append(changes, Change{Action: ActionRebuild, Name: want.Name, Spec: want,
+				Details: []string{fmt.Sprintf("image %s -> %s (disk is wiped)", rec.Image, want.Image)}})
+		}
+
+		var details []string
+		if !maps.Equal(rec.Labels, want.Labels) {
+			details = append(details, fmt.Sprintf("labels %s -> %s", FormatLabels(rec.Labels), FormatLabels(want.Labels)))
+		}
+		for _, key := range want.SSHKeys {
+			if !slices.Contains(rec.SSHKeys, key) {
+				details = append(details, "add ssh key "+shortKey(key))
+			}
+		}
+		if len(details) > 0 {
+			changes = append(changes, Change{Action: ActionUpdate, Name: want.Name, Spec: want, Details: details})
+		}
+
+		if known && len(want.Networks) > 0 && !slices.Equal(rec.Networks, want.Networks) {
+			warnings = append(warnings, fmt.Sprintf("%s: networks %v -> %v can't be changed in place (delete and re-create it)", want.Name, rec.Networks, want.Networks))
+		}
+		if known && rec.Username != "" && rec.Username != want.Username {
+			warnings = append(warnings, fmt.Sprintf("%s: username %s -> %s can't be changed in place (delete and re-create it)", want.Name, rec.Username, want.Username))
+		}
+	}
+
+	for _, name := range live {
+		if wanted[name] {
+			continue
+		}
+		if prune {
+			changes = append(changes, Change{Action: ActionDelete, Name: name, Details: []string{"not in spec"}})
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: not in spec (use --prune to delete)", name))
+		}
+	}
+
+	order := []string{ActionDelete, ActionResize, ActionRebuild, ActionUpdate, ActionCreate}
+	slices.SortStableFunc(changes, func(a, b Change) int {
+		return slices.Index(order, a.Action) - slices.Index(order, b.Action)
+	})
+	return changes, warnings, nil
+}
+
+// Print writes the plan the way `vps-manager plan` shows it
+func Print(w io.Writer, changes []Change, warnings []string) {
+	symbols := map[string]string{ActionCreate: "+", ActionDelete: "-", ActionResize: "~", ActionRebuild: "!", ActionUpdate: "~"}
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s %-8s %-20s %s\n", symbols[c.Action], c.Action, c.Name, strings.Join(c.Details, "; "))
+	}
+	for _, msg := range warnings {
+		fmt.Fprintf(w, "⚠️  %s\n", msg)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "✅ Fleet matches the spec, nothing to do.")
+		return
+	}
+	fmt.Fprintf(w, "\nPlan: %d change(s).\n", len(changes))
+}
+
+// Apply runs the changes in order. It keeps going after a failure and
+// returns an error listing every change that failed.
+func Apply(b backend.Backend, changes []Change, w io.Writer) error {
+	var failed []string
+	for _, c := range changes {
+		fmt.Fprintf(w, "▶ %s %s\n", c.Action, c.Name)
+		if err := apply(b, c, w); err != nil {
+			fmt.Fprintf(w, "❌ %s %s: %v\n", c.Action, c.Name, err)
+			failed = append(failed, c.Action+" "+c.Name)
+			continue
+		}
+		fmt.Fprintf(w, "✅ %s %s\n", c.Action, c.Name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d change(s) failed: %s", len(failed), len(changes), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func apply(b backend.Backend, c Change, w io.Writer) error {
+	progress := func(step string) { fmt.Fprintf(w, "   %s\n", step) }
+
+	switch c.Action {
+	case ActionDelete:
+		return b.Action(c.Name, "delete", vm.ActionParams{}, progress)
+
+	case ActionResize:
+		return b.ResizeVM(c.Name, c.Spec.Plan, progress)
+
+	case ActionRebuild:
+		pw, generated, err := c.Spec.password()
+		if err != nil {
+			return err
+		}
+		if err := b.RebuildVM(c.Name, c.Spec.Image, pw, progress); err != nil {
+			return err
+		}
+		if generated {
+			// A rebuild keeps the account the VM was created with, not the spec's
+			user := "root"
+			if info, err := b.VMInfo(c.Name); err == nil && info.Record != nil && info.Record.Username != "" {
+				user = info.Record.Username
+			}
+			fmt.Fprintf(w, "🔑 %s: new password for %s is %s\n", c.Name, user, pw)
+		}
+		return nil
+
+	case ActionUpdate:
+		info, err := b.VMInfo(c.Name)
+		if err != nil {
+			return err
+		}
+		for _, key := range c.Spec.SSHKeys {
+			if info.Record != nil && slices.Contains(info.Record.SSHKeys, key) {
+				continue
+			}
+			params := vm.ActionParams{Username: c.Spec.Username, SSHKey: key}
+			if err := b.Action(c.Name, "add-ssh-key", params, progress); err != nil {
+				return err
+			}
+		}
+		return b.SetLabels(c.Name, c.Spec.Labels)
+
+	case ActionCreate:
+		pw, generated, err := c.Spec.password()
+		if err != nil {
+			return err
+		}
+		err = b.CreateVM(vm.CreateOptions{
+			Name:     c.Spec.Name,
+			Image:    c.Spec.Image,
+			PlanName: c.Spec.Plan,
+			Username: c.Spec.Username,
+			Password: pw,
+			Networks: c.Spec.Networks,
+			SSHKeys:  c.Spec.SSHKeys,
+			Labels:   c.Spec.Labels,
+			Progress: progress,
+		})
+		if err == nil && generated {
+			fmt.Fprintf(w, "🔑 %s: password for %s is %s\n", c.Name, c.Spec.Username, pw)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown change: %s", c.Action)
+}
+
+// FormatLabels prints labels as {k=v,...} in key order
+func FormatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+	var parts []string
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// shortKey shows the key type and comment instead of the whole blob
+func shortKey(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) >= 3 {
+		return fields[0] + " " + strings.Join(fields[2:], " ")
+	}
+	if len(fields) > 0 {
+		return fields[0]
+	}
+	return key
+}
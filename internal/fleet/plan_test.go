@@ -0,0 +1,172 @@
+package fleet
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+const opsKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ5K0hR0mz7Y7r2c0d8zq3fW7c8T3p8J2o9xk4yM1n2b ops"
+
+// fakeBackend serves live VMs from a map (nil record = created outside vps-manager)
+type fakeBackend struct {
+	backend.Backend
+	vms map[string]*vm.Record
+}
+
+func (b *fakeBackend) ListVMs() ([]core.VMState, error) {
+	var list []core.VMState
+	for name := range b.vms {
+		list = append(list, core.VMState{ID: name, Name: name})
+	}
+	slices.SortFunc(list, func(a, b core.VMState) int { return strings.Compare(a.Name, b.Name) })
+	return list, nil
+}
+
+func (b *fakeBackend) VMInfo(name string) (vm.Info, error) {
+	rec, ok := b.vms[name]
+	if !ok {
+		return vm.Info{}, fmt.Errorf("vm '%s' not found", name)
+	}
+	return vm.Info{VMState: core.VMState{ID: name, Name: name}, Record: rec}, nil
+}
+
+func TestPlan(t *testing.T) {
+	web := vm.Record{Name: "web1", Image: "ubuntu-24.04", Plan: "Starter", Username: "root",
+		Networks: []string{"default"}, SSHKeys: []string{opsKey}, Labels: map[string]string{"role": "web"}}
+	want := VMSpec{Name: "web1", Image: "ubuntu-24.04", Plan: "Starter", Username: "root",
+		Networks: []string{"default"}, SSHKeys: []string{opsKey}, Labels: map[string]string{"role": "web"}}
+
+	cases := []struct {
+		name     string
+		live     map[string]*vm.Record
+		spec     func(v *VMSpec)
+		prune    bool
+		changes  []string // "action name: details"
+		warnings []string // Substrings, in order
+	}{
+		{
+			name: "in sync",
+			live: map[string]*vm.Record{"web1": &web},
+		},
+		{
+			name:    "missing vm",
+			live:    map[string]*vm.Record{},
+			changes: []string{"create web1: Starter on ubuntu-24.04"},
+		},
+		{
+			name: "plan differs only in case",
+			live: map[string]*vm.Record{"web1": &web},
+			spec: func(v *VMSpec) { v.Plan = "starter" },
+		},
+		{
+			name:    "new plan and image",
+			live:    map[string]*vm.Record{"web1": &web},
+			spec:    func(v *VMSpec) { v.Plan = "Professional"; v.Image = "debian-12" },
+			changes: []string{"resize web1: plan Starter -> Professional", "rebuild web1: image ubuntu-24.04 -> debian-12 (disk is wiped)"},
+		},
+		{
+			name: "labels and a new key",
+			live: map[string]*vm.Record{"web1": &web},
+			spec: func(v *VMSpec) {
+				v.Labels = map[string]string{"role": "db", "env": "prod"}
+				v.SSHKeys = append(v.SSHKeys, strings.TrimSuffix(opsKey, " ops")+" ci")
+			},
+			changes: []string{"update web1: labels {role=web} -> {env=prod,role=db}; add ssh key ssh-ed25519 ci"},
+		},
+		{
+			name: "fewer keys in the spec",
+			live: map[string]*vm.Record{"web1": &web},
+			spec: func(v *VMSpec) { v.SSHKeys = nil },
+		},
+		{
+			name:     "networks and username can't change",
+			live:     map[string]*vm.Record{"web1": &web},
+			spec:     func(v *VMSpec) { v.Networks = []string{"br0"}; v.Username = "ops" },
+			warnings: []string{"web1: networks [default] -> [br0]", "web1: username root -> ops"},
+		},
+		{
+			name:     "unknown to the inventory",
+			live:     map[string]*vm.Record{"web1": nil},
+			spec:     func(v *VMSpec) { v.Labels = nil; v.SSHKeys = nil; v.Plan = "Beast" },
+			warnings: []string{"web1: not in the inventory"},
+		},
+		{
+			name:     "extra vm without prune",
+			live:     map[string]*vm.Record{"web1": &web, "old": {Name: "old"}},
+			warnings: []string{"old: not in spec (use --prune to delete)"},
+		},
+		{
+			name:    "extra vm with prune, deletes first",
+			live:    map[string]*vm.Record{"old": {Name: "old"}},
+			prune:   true,
+			changes: []string{"delete old: not in spec", "create web1: Starter on ubuntu-24.04"},
+		},
+	}
+	for _, tc := range cases {
+		v := want
+		v.Labels = map[string]string{"role": "web"}
+		if tc.spec != nil {
+			tc.spec(&v)
+		}
+		changes, warnings, err := Plan(&fakeBackend{vms: tc.live}, &Spec{VMs: []VMSpec{v}}, tc.prune)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got []string
+		for _, c := range changes {
+			got = append(got, fmt.Sprintf("%s %s: %s", c.Action, c.Name, strings.Join(c.Details, "; ")))
+		}
+		if !slices.Equal(got, tc.changes) {
+			t.Errorf("%s: changes\n  %q\nwant\n  %q", tc.name, got, tc.changes)
+		}
+		if len(warnings) != len(tc.warnings) {
+			t.Errorf("%s: warnings %q, want %q", tc.name, warnings, tc.warnings)
+			continue
+		}
+		for i, w := range tc.warnings {
+			if !strings.Contains(warnings[i], w) {
+				t.Errorf("%s: warning %q, want %q", tc.name, warnings[i], w)
+			}
+		}
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		vms  []VMSpec
+		err  string // "" = valid
+	}{
+		{"defaults", []VMSpec{{Name: "web1", Image: "ubuntu-24.04"}}, ""},
+		{"plan spelling", []VMSpec{{Name: "web1", Image: "ubuntu-24.04", Plan: "PROFESSIONAL"}}, ""},
+		{"no name", []VMSpec{{Image: "ubuntu-24.04"}}, "no name"},
+		{"twice", []VMSpec{{Name: "web1", Image: "a"}, {Name: "web1", Image: "b"}}, "listed twice"},
+		{"no image", []VMSpec{{Name: "web1"}}, "no image"},
+		{"unknown plan", []VMSpec{{Name: "web1", Image: "a", Plan: "Huge"}}, "unknown plan"},
+		{"bad network", []VMSpec{{Name: "web1", Image: "a", Networks: []string{"br0'/>"}}}, "invalid network"},
+	}
+	for _, tc := range cases {
+		spec := Spec{VMs: tc.vms}
+		err := spec.validate()
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: got %v, want %q", tc.name, err, tc.err)
+		}
+	}
+
+	spec := Spec{VMs: []VMSpec{{Name: "web1", Image: "a", Plan: "PROFESSIONAL"}}}
+	if err := spec.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if v := spec.VMs[0]; v.Plan != "Professional" || v.Username != "root" {
+		t.Errorf("validate left plan %q, username %q", v.Plan, v.Username)
+	}
+}
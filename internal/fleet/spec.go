@@ -0,0 +1,113 @@
+// Package fleet describes VMs declaratively and reconciles the host towards that description.
+package fleet
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/vm"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the fleet file:
+//
+//	vms:
+//	  - name: web1
+//	    image: ubuntu-24.04
+//	    plan: Starter
+//	    networks: [default, br0]
+//	    ssh_keys: ["ssh-ed25519 AAAA... ops"]
+//	    labels: {role: web}
+type Spec struct {
+	VMs []VMSpec `yaml:"vms"`
+}
+
+// VMSpec is one desired VM
+type VMSpec struct {
+	Name        string            `yaml:"name"`
+	Image       string            `yaml:"image"`
+	Plan        string            `yaml:"plan"`
+	Username    string            `yaml:"username"`     // Default root
+	Password    string            `yaml:"password"`     // Prefer password_env
+	PasswordEnv string            `yaml:"password_env"` // Read the password from this variable
+	Networks    []string          `yaml:"networks"`
+	SSHKeys     []string          `yaml:"ssh_keys"`
+	Labels      map[string]string `yaml:"labels"`
+}
+
+// Load reads and validates a fleet file
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet spec: %w", err)
+	}
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+func (s *Spec) validate() error {
+	seen := map[string]bool{}
+	for i := range s.VMs {
+		v := &s.VMs[i]
+		if v.Name == "" {
+			return fmt.Errorf("vm #%d has no name", i+1)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("vm '%s' is listed twice", v.Name)
+		}
+		seen[v.Name] = true
+		if v.Image == "" {
+			return fmt.Errorf("vm '%s' has no image", v.Name)
+		}
+		if v.Plan == "" {
+			v.Plan = plans.Available[0].Name
+		}
+		plan, ok := findPlan(v.Plan)
+		if !ok {
+			return fmt.Errorf("vm '%s': unknown plan '%s'", v.Name, v.Plan)
+		}
+		v.Plan = plan.Name // Canonical spelling, so diffs are exact
+		if v.Username == "" {
+			v.Username = "root"
+		}
+		for _, n := range v.Networks {
+			if err := core.CheckNetwork(n); err != nil {
+				return fmt.Errorf("vm '%s': %w", v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// password resolves the initial password; generated is true when we had to make one up
+func (v VMSpec) password() (pw string, generated bool, err error) {
+	if v.PasswordEnv != "" {
+		pw = os.Getenv(v.PasswordEnv)
+		if pw == "" {
+			return "", false, fmt.Errorf("vm '%s': $%s is empty", v.Name, v.PasswordEnv)
+		}
+		return pw, false, nil
+	}
+	if v.Password != "" {
+		return v.Password, false, nil
+	}
+	return vm.RandomPassword(), true, nil
+}
+
+func findPlan(name string) (plans.VMPlan, bool) {
+	for _, p := range plans.Available {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return plans.VMPlan{}, false
+}
@@ -2,11 +2,15 @@ package images
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -19,6 +23,16 @@ type ImageInfo struct {
 	Status    string `json:"status"`     // READY, DOWNLOADING, ERROR
 }
 
+// ErrInvalidName: image names become file names in CacheDir
+var ErrInvalidName = errors.New("image name must be 1-63 letters, digits, '-', '_' or '.'")
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,62}$`)
+
+// ValidName reports whether name is safe to use as a cache file name
+func ValidName(name string) bool {
+	return validName.MatchString(name) && !strings.Contains(name, "..")
+}
+
 // Store handles the logic of mapping Names -> Files
 type Store struct {
 	RegistryPath string // Path to images.json
@@ -67,6 +81,9 @@ func (s *Store) Resolve(name string) (ImageInfo, error) {
 
 // Register adds/updates an image in the DB
 func (s *Store) Register(name, url, checksum string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("'%s': %w", name, ErrInvalidName)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -84,6 +101,58 @@ func (s *Store) Register(name, url, checksum string) error {
 	return s.saveRegistry()
 }
 
+// Get returns a registered image without downloading it
+func (s *Store) Get(name string) (ImageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	img, ok := s.images[name]
+	if !ok {
+		return ImageInfo{}, fmt.Errorf("image '%s' not registered", name)
+	}
+	return img, nil
+}
+
+// List returns all registered images sorted by name
+func (s *Store) List() []ImageInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]ImageInfo, 0, len(s.images))
+	for _, img := range s.images {
+		list = append(list, img)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Remove unregisters an image and deletes its cached file.
+// Callers must make sure no VM disk still uses it as a backing file.
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	img, ok := s.images[name]
+	if !ok {
+		return fmt.Errorf("image '%s' not registered", name)
+	}
+	if !s.inCache(img.LocalPath) {
+		return fmt.Errorf("refusing to delete %s: not in %s", img.LocalPath, s.CacheDir)
+	}
+	if err := os.Remove(img.LocalPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", img.LocalPath, err)
+	}
+	delete(s.images, name)
+	return s.saveRegistry()
+}
+
+// inCache reports whether path is a file directly inside CacheDir
+func (s *Store) inCache(path string) bool {
+	dir, err := filepath.Abs(s.CacheDir)
+	if err != nil {
+		return false
+	}
+	file, err := filepath.Abs(path)
+	return err == nil && filepath.Dir(file) == dir
+}
+
 // Internal: Downloads the file
 func (s *Store) downloadImage(img *ImageInfo) error {
 	fmt.Printf("⬇️  Pulling Image: %s from %s...\n", img.Name, img.URL)
@@ -0,0 +1,92 @@
+package images
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidName(t *testing.T) {
+	cases := []struct {
+		name string
+		ok   bool
+	}{
+		{"ubuntu-24.04", true},
+		{"rescue", true},
+		{"_base", true},
+		{"Debian_12.qcow2", true},
+		{"a", true},
+
+		{"", false},
+		{".hidden", false},
+		{"-rf", false},
+		{"../etc/passwd", false},
+		{"a..b", false},
+		{"images/ubuntu", false},
+		{"ubuntu 24.04", false},
+		{"ubuntu\n", false},
+		{"x234567890123456789012345678901234567890123456789012345678901234", false}, // 64 characters
+	}
+	for _, tc := range cases {
+		if got := ValidName(tc.name); got != tc.ok {
+			t.Errorf("ValidName(%q) = %v, want %v", tc.name, got, tc.ok)
+		}
+	}
+}
+
+// Remove only deletes files directly inside the cache, whatever the registry says
+func TestRemoveStaysInCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	outside := filepath.Join(dir, "precious.qcow2")
+	nested := filepath.Join(cache, "sub", "nested.qcow2")
+	inside := filepath.Join(cache, "ubuntu.qcow2")
+	for _, f := range []string{outside, nested, inside} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("qcow2"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := filepath.Join(dir, "images.json")
+	data, _ := json.Marshal(map[string]ImageInfo{
+		"outside":   {Name: "outside", LocalPath: outside},
+		"traversal": {Name: "traversal", LocalPath: filepath.Join(cache, "..", "precious.qcow2")},
+		"nested":    {Name: "nested", LocalPath: nested},
+		"ubuntu":    {Name: "ubuntu", LocalPath: inside},
+	})
+	if err := os.WriteFile(registry, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(registry, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		file string
+		ok   bool
+	}{
+		{"outside", outside, false},
+		{"traversal", outside, false},
+		{"nested", nested, false},
+		{"ubuntu", inside, true},
+	}
+	for _, tc := range cases {
+		err := s.Remove(tc.name)
+		if (err == nil) != tc.ok {
+			t.Errorf("Remove(%s) = %v, want ok=%v", tc.name, err, tc.ok)
+		}
+		_, statErr := os.Stat(tc.file)
+		if exists := statErr == nil; exists == tc.ok {
+			t.Errorf("Remove(%s): %s exists=%v", tc.name, tc.file, exists)
+		}
+	}
+	if _, err := s.Get("ubuntu"); err == nil {
+		t.Error("removed image is still registered")
+	}
+}
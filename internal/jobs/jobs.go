@@ -0,0 +1,271 @@
+// Package jobs runs long VM/image operations in the background and keeps
+// their progress on disk so callers can poll (or stream) the outcome.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Succeeded State = "succeeded"
+	Failed    State = "failed"
+)
+
+// MaxJobs is how many finished jobs we keep on disk
+const MaxJobs = 500
+
+// Step is one progress message
+type Step struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Job is a single background operation
+type Job struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`   // "vm.create", "image.download"...
+	Target     string     `json:"target"` // VM or image name
+	State      State      `json:"state"`
+	Steps      []Step     `json:"steps"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job reached a final state
+func (j Job) Done() bool {
+	return j.State == Succeeded || j.State == Failed
+}
+
+// Progress lets a running job report what it is doing
+type Progress func(message string)
+
+// Store keeps jobs in memory and mirrors them to a JSON file
+type Store struct {
+	Path string
+	jobs map[string]*Job
+	subs map[string][]chan Job
+	mu   sync.Mutex
+}
+
+// NewStore loads previous jobs. Their functions aren't persisted, so anything
+// that was queued or running when the daemon stopped is marked failed; the
+// caller has to submit it again.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		Path: path,
+		jobs: make(map[string]*Job),
+		subs: make(map[string][]chan Job),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read jobs: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.jobs); err != nil {
+			return nil, fmt.Errorf("failed to parse jobs %s: %w", path, err)
+		}
+	}
+
+	now := time.Now()
+	for _, j := range s.jobs {
+		switch j.State {
+		case Queued:
+			j.State = Failed
+			j.Error = "not started before the daemon restarted; submit it again"
+			j.FinishedAt = &now
+		case Running:
+			j.State = Failed
+			j.Error = "interrupted by daemon restart"
+			j.FinishedAt = &now
+		}
+	}
+	return s, s.save()
+}
+
+// Submit queues fn and returns immediately
+func (s *Store) Submit(jobType, target string, fn func(Progress) error) Job {
+	j := &Job{
+		ID:        newID(),
+		Type:      jobType,
+		Target:    target,
+		State:     Queued,
+		Steps:     []Step{},
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	s.prune()
+	s.changed(j)
+	snapshot := s.copyOf(j)
+	s.mu.Unlock()
+
+	go s.run(j, fn)
+	return snapshot
+}
+
+// Get returns a copy of a job
+func (s *Store) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return s.copyOf(j), true
+}
+
+// List returns jobs, newest first
+func (s *Store) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		list = append(list, s.copyOf(j))
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.After(list[b].CreatedAt) })
+	return list
+}
+
+// Subscribe streams every change of one job. The channel is closed once the
+// job finishes; call cancel to stop listening early.
+func (s *Store) Subscribe(id string) (<-chan Job, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, func() {}, false
+	}
+	ch := make(chan Job, 16)
+	ch <- s.copyOf(j)
+	if j.Done() {
+		close(ch)
+		return ch, func() {}, true
+	}
+	s.subs[id] = append(s.subs[id], ch)
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		subs := s.subs[id]
+		for i, c := range subs {
+			if c == ch {
+				s.subs[id] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+	return ch, cancel, true
+}
+
+// --- PRIVATE HELPERS ---
+
+func (s *Store) run(j *Job, fn func(Progress) error) {
+	s.update(j, func() {
+		now := time.Now()
+		j.State = Running
+		j.StartedAt = &now
+	})
+
+	err := fn(func(msg string) {
+		s.update(j, func() { j.Steps = append(j.Steps, Step{Time: time.Now(), Message: msg}) })
+	})
+
+	s.update(j, func() {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.State = Succeeded
+		if err != nil {
+			j.State = Failed
+			j.Error = err.Error()
+		}
+	})
+}
+
+func (s *Store) update(j *Job, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+	s.changed(j)
+}
+
+// changed persists and notifies subscribers (caller holds mu)
+func (s *Store) changed(j *Job) {
+	if err := s.save(); err != nil {
+		fmt.Printf("⚠️  Failed to save jobs: %v\n", err)
+	}
+
+	// Every snapshot holds all steps so far, so a slow reader may skip some,
+	// but never the final one: make room for it by dropping the oldest.
+	// We're the only sender (under mu), so the send after that can't block.
+	snapshot := s.copyOf(j)
+	for _, ch := range s.subs[j.ID] {
+		select {
+		case ch <- snapshot:
+		default:
+			if j.Done() {
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- snapshot
+			}
+		}
+	}
+	if j.Done() {
+		for _, ch := range s.subs[j.ID] {
+			close(ch)
+		}
+		delete(s.subs, j.ID)
+	}
+}
+
+// prune drops the oldest finished jobs past MaxJobs (caller holds mu)
+func (s *Store) prune() {
+	if len(s.jobs) <= MaxJobs {
+		return
+	}
+	var done []*Job
+	for _, j := range s.jobs {
+		if j.Done() {
+			done = append(done, j)
+		}
+	}
+	sort.Slice(done, func(a, b int) bool { return done[a].CreatedAt.Before(done[b].CreatedAt) })
+	for i := 0; i < len(done) && len(s.jobs) > MaxJobs; i++ {
+		delete(s.jobs, done[i].ID)
+	}
+}
+
+func (s *Store) copyOf(j *Job) Job {
+	c := *j
+	c.Steps = append([]Step(nil), j.Steps...)
+	return c
+}
+
+func (s *Store) save() error {
+	data, _ := json.MarshalIndent(s.jobs, "", "  ")
+	return os.WriteFile(s.Path, data, 0644)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// A subscriber that doesn't read while the job runs still ends on its final state
+func TestSubscribeSlowReader(t *testing.T) {
+	s, err := NewStore(t.TempDir() + "/jobs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := make(chan struct{})
+	job := s.Submit("vm.create", "web1", func(p Progress) error {
+		<-start
+		for i := 0; i < 100; i++ {
+			p(fmt.Sprintf("step %d", i))
+		}
+		return nil
+	})
+	updates, cancel, ok := s.Subscribe(job.ID)
+	if !ok {
+		t.Fatal("job not found")
+	}
+	defer cancel()
+	close(start)
+
+	for { // Don't read until the job is over
+		if j, _ := s.Get(job.ID); j.Done() {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var last Job
+	for j := range updates {
+		last = j
+	}
+	if last.State != Succeeded || len(last.Steps) != 100 {
+		t.Fatalf("last update: %s with %d steps, want succeeded with 100", last.State, len(last.Steps))
+	}
+}
+
+// Job functions aren't persisted: unfinished jobs come back failed, finished ones as they were
+func TestNewStoreFailsUnfinished(t *testing.T) {
+	path := t.TempDir() + "/jobs.json"
+	done := time.Now().Add(-time.Hour)
+	before := map[string]*Job{
+		"q": {ID: "q", State: Queued},
+		"r": {ID: "r", State: Running, StartedAt: &done},
+		"s": {ID: "s", State: Succeeded, FinishedAt: &done},
+		"f": {ID: "f", State: Failed, Error: "disk full", FinishedAt: &done},
+	}
+	data, _ := json.Marshal(before)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		id    string
+		state State
+		error string
+	}{
+		{"q", Failed, "not started before the daemon restarted; submit it again"},
+		{"r", Failed, "interrupted by daemon restart"},
+		{"s", Succeeded, ""},
+		{"f", Failed, "disk full"},
+	}
+	for _, tc := range cases {
+		j, ok := s.Get(tc.id)
+		if !ok {
+			t.Fatalf("job %s lost", tc.id)
+		}
+		if j.State != tc.state || j.Error != tc.error || j.FinishedAt == nil {
+			t.Errorf("job %s: state %s, error %q, finished %v; want %s, %q", tc.id, j.State, j.Error, j.FinishedAt, tc.state, tc.error)
+		}
+	}
+
+	// And that's what the next daemon reads
+	again, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := again.Get("r"); j.State != Failed {
+		t.Errorf("job r is %s after a second restart", j.State)
+	}
+}
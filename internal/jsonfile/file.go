@@ -0,0 +1,137 @@
+// Package jsonfile keeps a JSON map on disk that several processes change:
+// the listen daemon and every CLI command hold their own copy. Each change
+// re-reads the file under flock(2) and replaces it with a rename, and reads
+// pick up the file again when another process has replaced it.
+package jsonfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// File is one JSON object on disk, keyed by name
+type File[V any] struct {
+	Path string
+	Perm os.FileMode
+
+	mu   sync.Mutex
+	seen os.FileInfo // What the last read or write left on disk
+}
+
+func New[V any](path string, perm os.FileMode) *File[V] {
+	return &File[V]{Path: path, Perm: perm}
+}
+
+// Load reads the file if it changed since the last Load or Update. changed
+// is false (and m nil) when the copy the caller has is still current; a
+// missing file reads as empty.
+func (f *File[V]) Load() (m map[string]V, changed bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.Path)
+	if err == nil && f.seen != nil && os.SameFile(info, f.seen) &&
+		info.ModTime().Equal(f.seen.ModTime()) && info.Size() == f.seen.Size() {
+		return nil, false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+	m, err = f.read()
+	if err != nil {
+		return nil, false, err
+	}
+	return m, true, nil
+}
+
+// Update locks the file against other processes, reads its current content,
+// lets change edit it and writes the result back atomically. It returns the
+// new content; nothing is written if change fails.
+func (f *File[V]) Update(change func(m map[string]V) error) (map[string]V, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lock, err := os.OpenFile(f.Path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", f.Path, err)
+	}
+	defer lock.Close() // Closing releases the flock
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", f.Path, err)
+	}
+
+	m, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := change(m); err != nil {
+		f.seen = nil // The caller's copy may be older than what we just read
+		return nil, err
+	}
+	if err := f.write(m); err != nil {
+		f.seen = nil
+		return nil, err
+	}
+	return m, nil
+}
+
+// read loads the file and remembers which version it saw (caller holds mu)
+func (f *File[V]) read() (map[string]V, error) {
+	m := map[string]V{}
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		f.seen = nil
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+	if info.Size() > 0 {
+		if err := json.NewDecoder(file).Decode(&m); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+		}
+	}
+	if m == nil { // The file said null
+		m = map[string]V{}
+	}
+	f.seen = info
+	return m, nil
+}
+
+// write replaces the file through a temporary file and a rename, so readers
+// never see half of it (caller holds mu and the flock)
+func (f *File[V]) write(m map[string]V) error {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), "."+filepath.Base(f.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	if err := tmp.Chmod(f.Perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	info, err := os.Stat(f.Path)
+	if err == nil {
+		f.seen = info
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package signing computes the HMAC-SHA256 signatures we put on webhook
+// payloads (outbound event deliveries and watcher -> server releases).
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderTimestamp = "X-VPS-Timestamp"
+	HeaderSignature = "X-VPS-Signature"
+)
+
+// Sign returns "sha256=<hex>" over "<timestamp>.<body>".
+// Including the timestamp lets receivers reject replays of old payloads.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders signs body with the current time and sets both headers on req
+func SetHeaders(req *http.Request, secret string, body []byte) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(secret, ts, body))
+}
@@ -0,0 +1,69 @@
+package signing
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func signed(ts string, body []byte, secret string) *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, "/webhook", nil)
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderSignature, Sign(secret, ts, body))
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"distro":"Ubuntu","version":"24.04"}`)
+	unix := func(d time.Duration) string { return strconv.FormatInt(time.Now().Add(d).Unix(), 10) }
+
+	cases := []struct {
+		name string
+		req  func() *http.Request
+		want error
+	}{
+		{"fresh", func() *http.Request { return signed(unix(0), body, testSecret) }, nil},
+		{"slightly behind", func() *http.Request { return signed(unix(-MaxSkew+time.Minute), body, testSecret) }, nil},
+		{"stale", func() *http.Request { return signed(unix(-MaxSkew-time.Minute), body, testSecret) }, ErrStale},
+		{"from the future", func() *http.Request { return signed(unix(MaxSkew+time.Minute), body, testSecret) }, ErrStale},
+		{"not a timestamp", func() *http.Request { return signed("yesterday", body, testSecret) }, ErrStale},
+		{"wrong secret", func() *http.Request { return signed(unix(0), body, "another secret") }, ErrBadSignature},
+		{"body changed", func() *http.Request { return signed(unix(0), []byte(`{"distro":"Evil"}`), testSecret) }, ErrBadSignature},
+		{"timestamp changed", func() *http.Request {
+			r := signed(unix(-time.Hour), body, testSecret)
+			r.Header.Set(HeaderTimestamp, unix(0)) // Re-dating an old capture breaks the signature
+			return r
+		}, ErrBadSignature},
+		{"unsigned", func() *http.Request { r, _ := http.NewRequest(http.MethodPost, "/webhook", nil); return r }, ErrMissingSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := NewVerifier(testSecret).Verify(tc.req(), body); !errors.Is(err, tc.want) {
+				t.Fatalf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	v := NewVerifier(testSecret)
+	body := []byte(`{"distro":"Rocky","version":"9"}`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	if err := v.Verify(signed(ts, body, testSecret), body); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := v.Verify(signed(ts, body, testSecret), body); !errors.Is(err, ErrReplay) {
+		t.Fatalf("same request again = %v, want %v", err, ErrReplay)
+	}
+
+	// A new signature for the same body (the watcher's next run) is fine
+	next := strconv.FormatInt(time.Now().Unix()+1, 10)
+	if err := v.Verify(signed(next, body, testSecret), body); err != nil {
+		t.Fatalf("new timestamp: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package subscriptions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/events"
+	"github.com/Shaman786/vps-manager/internal/signing"
+)
+
+// Retry policy: 1s, 2s, 4s ... capped at MaxBackoff, MaxAttempts tries in total
+const (
+	MaxAttempts    = 8
+	InitialBackoff = time.Second
+	MaxBackoff     = 10 * time.Minute
+)
+
+// Payload is the JSON body receivers get
+type Payload struct {
+	ID      string    `json:"id"` // Delivery ID (stable across retries)
+	Type    string    `json:"type"`
+	Subject string    `json:"subject"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Dispatcher turns bus events into deliveries
+type Dispatcher struct {
+	Store  *Store
+	Client *http.Client
+}
+
+func NewDispatcher(store *Store) *Dispatcher {
+	transport := &http.Transport{DialContext: safeDialer().DialContext, TLSHandshakeTimeout: 10 * time.Second}
+	return &Dispatcher{Store: store, Client: &http.Client{Timeout: 10 * time.Second, Transport: transport}}
+}
+
+// Start resumes unfinished deliveries and follows the bus
+func (d *Dispatcher) Start(bus *events.Bus) {
+	for _, del := range d.Store.pending() {
+		go func() {
+			if del.NextAttemptAt != nil {
+				time.Sleep(time.Until(*del.NextAttemptAt))
+			}
+			d.deliver(del)
+		}()
+	}
+
+	stream, _ := bus.Subscribe(256)
+	go func() {
+		for e := range stream {
+			for _, t := range PublicTypes(e) {
+				d.dispatch(t, e)
+			}
+		}
+	}()
+}
+
+// PublicTypes maps an internal event to the types subscribers filter on
+func PublicTypes(e events.Event) []string {
+	if e.Type != "vm.state" {
+		return []string{e.Type}
+	}
+	// The daemon (re)discovering VMs at startup isn't news to anyone
+	if e.Reason == "discovered" {
+		return nil
+	}
+	types := []string{"vm.state"}
+	switch {
+	case e.To == "running" && e.From == "provisioning":
+		types = append(types, "vm.created")
+	case e.To == "running" && (e.From == "stopped" || e.From == "paused" || e.From == "error"):
+		types = append(types, "vm.started")
+	case e.To == "stopped":
+		types = append(types, "vm.stopped")
+	case e.To == "deleted":
+		types = append(types, "vm.deleted")
+	case e.To == "error":
+		types = append(types, "vm.failed")
+	}
+	return types
+}
+
+func (d *Dispatcher) dispatch(eventType string, e events.Event) {
+	for _, sub := range d.Store.matching(eventType) {
+		id := newID(8)
+		payload, _ := json.Marshal(Payload{
+			ID:      id,
+			Type:    eventType,
+			Subject: e.Subject,
+			From:    e.From,
+			To:      e.To,
+			Reason:  e.Reason,
+			Time:    e.Time,
+		})
+		del := &Delivery{
+			ID:             id,
+			SubscriptionID: sub.ID,
+			EventType:      eventType,
+			Subject:        e.Subject,
+			State:          Pending,
+			CreatedAt:      time.Now(),
+			Payload:        payload,
+		}
+		d.Store.addDelivery(del)
+		go d.deliver(del)
+	}
+}
+
+// deliver retries with exponential backoff until a 2xx or MaxAttempts
+func (d *Dispatcher) deliver(del *Delivery) {
+	for {
+		sub, ok := d.Store.Get(del.SubscriptionID)
+		if !ok {
+			d.Store.updateDelivery(del, func() {
+				del.State = Failed
+				del.LastError = "subscription removed"
+				del.NextAttemptAt = nil
+			})
+			return
+		}
+
+		status, err := d.post(sub, del)
+
+		var wait time.Duration
+		d.Store.updateDelivery(del, func() {
+			del.Attempts++
+			del.LastStatus = status
+			del.LastError = ""
+			del.NextAttemptAt = nil
+			switch {
+			case err == nil:
+				del.State = Delivered
+			case del.Attempts >= MaxAttempts:
+				del.State = Failed
+				del.LastError = err.Error()
+			default:
+				del.LastError = err.Error()
+				wait = backoff(del.Attempts)
+				next := time.Now().Add(wait)
+				del.NextAttemptAt = &next
+			}
+		})
+		if wait == 0 {
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+func (d *Dispatcher) post(sub Subscription, del *Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "vps-manager-webhooks")
+	req.Header.Set("X-VPS-Event", del.EventType)
+	req.Header.Set("X-VPS-Delivery", del.ID)
+	signing.SetHeaders(req, sub.Secret, del.Payload)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func backoff(attempt int) time.Duration {
+	wait := InitialBackoff << (attempt - 1)
+	if wait > MaxBackoff || wait <= 0 {
+		return MaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,236 @@
+// Package subscriptions delivers events to external systems (billing,
+// monitoring) over signed HTTP webhooks with retries.
+package subscriptions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxDeliveries is how much delivery history we keep
+const MaxDeliveries = 1000
+
+// Event types a subscription can ask for
+var EventTypes = []string{"vm.created", "vm.started", "vm.stopped", "vm.deleted", "vm.failed", "vm.state", "vm.restarted", "vm.crashloop", "vm.health", "image.ready", "image.failed"}
+
+// Subscription is one registered target
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"` // Empty = everything; "vm.*" matches a prefix
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants this event type
+func (s Subscription) Matches(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, f := range s.Events {
+		if f == eventType || f == "*" || (strings.HasSuffix(f, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(f, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryState string
+
+const (
+	Pending   DeliveryState = "pending"
+	Delivered DeliveryState = "delivered"
+	Failed    DeliveryState = "failed"
+)
+
+// Delivery is one event sent (or being sent) to one subscription
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	Subject        string          `json:"subject"`
+	State          DeliveryState   `json:"state"`
+	Attempts       int             `json:"attempts"`
+	LastStatus     int             `json:"last_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Store persists subscriptions and delivery history to a JSON file
+type Store struct {
+	Path string
+
+	subs       map[string]Subscription
+	deliveries []*Delivery
+	mu         sync.Mutex
+}
+
+type storeFile struct {
+	Subscriptions map[string]Subscription `json:"subscriptions"`
+	Deliveries    []*Delivery             `json:"deliveries"`
+}
+
+func NewStore(path string) (*Store, error) {
+	s := &Store{Path: path, subs: make(map[string]Subscription)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse subscriptions %s: %w", path, err)
+	}
+	if f.Subscriptions != nil {
+		s.subs = f.Subscriptions
+	}
+	s.deliveries = f.Deliveries
+	return s, nil
+}
+
+// Add validates and stores a subscription; a secret is generated if missing
+func (s *Store) Add(sub Subscription) (Subscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	if err := checkTarget(u.Hostname()); err != nil {
+		return Subscription{}, fmt.Errorf("url not allowed: %w", err)
+	}
+	for _, f := range sub.Events {
+		if f != "*" && !strings.HasSuffix(f, ".*") && !knownType(f) {
+			return Subscription{}, fmt.Errorf("unknown event type %q (known: %s)", f, strings.Join(EventTypes, ", "))
+		}
+	}
+	if sub.Secret == "" {
+		sub.Secret = newID(24)
+	}
+	sub.ID = newID(8)
+	sub.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.ID] = sub
+	return sub, s.save()
+}
+
+// Remove deletes a subscription (its history stays)
+func (s *Store) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[id]; !ok {
+		return fmt.Errorf("subscription '%s' not found", id)
+	}
+	delete(s.subs, id)
+	return s.save()
+}
+
+// Get returns one subscription
+func (s *Store) Get(id string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	return sub, ok
+}
+
+// List returns subscriptions without their secrets
+func (s *Store) List() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		sub.Secret = ""
+		list = append(list, sub)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Deliveries returns delivery history, newest first (subscriptionID "" = all)
+func (s *Store) Deliveries(subscriptionID string) []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Delivery
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		if d := s.deliveries[i]; subscriptionID == "" || d.SubscriptionID == subscriptionID {
+			list = append(list, *d)
+		}
+	}
+	return list
+}
+
+// --- PRIVATE HELPERS (callers hold mu unless noted) ---
+
+func (s *Store) matching(eventType string) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Subscription
+	for _, sub := range s.subs {
+		if sub.Matches(eventType) {
+			list = append(list, sub)
+		}
+	}
+	return list
+}
+
+func (s *Store) addDelivery(d *Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, d)
+	if len(s.deliveries) > MaxDeliveries {
+		s.deliveries = s.deliveries[len(s.deliveries)-MaxDeliveries:]
+	}
+	_ = s.save()
+}
+
+func (s *Store) updateDelivery(d *Delivery, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+	_ = s.save()
+}
+
+func (s *Store) pending() []*Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*Delivery
+	for _, d := range s.deliveries {
+		if d.State == Pending {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+func (s *Store) save() error {
+	data, _ := json.MarshalIndent(storeFile{Subscriptions: s.subs, Deliveries: s.deliveries}, "", "  ")
+	return os.WriteFile(s.Path, data, 0600)
+}
+
+func knownType(t string) bool {
+	for _, k := range EventTypes {
+		if k == t {
+			return true
+		}
+	}
+	return false
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
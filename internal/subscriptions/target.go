@@ -0,0 +1,51 @@
+package subscriptions
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// blockedIP reports addresses subscriptions may not point at: the host
+// itself, link-local (incl. cloud metadata at 169.254.169.254) and private
+// networks, so an API key can't turn the dispatcher against internal services
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
+
+// checkTarget refuses hosts that are, or resolve to, a blocked address
+func checkTarget(host string) error {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = net.LookupIP(host); err != nil {
+			return fmt.Errorf("cannot resolve %s: %w", host, err)
+		}
+	}
+	for _, ip := range ips {
+		if blockedIP(ip) {
+			return fmt.Errorf("%s is a loopback, link-local or private address", ip)
+		}
+	}
+	return nil
+}
+
+// safeDialer re-checks every connection, since DNS may change after the
+// subscription was added
+func safeDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return fmt.Errorf("refusing to deliver to %s", address)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,187 @@
+// Package tenants keeps the tenants (customers/projects) VMs and API keys
+// belong to, and their quotas. The admin tenant is built in: it owns VMs
+// created before tenants existed, has no quota and sees everything.
+package tenants
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/jsonfile"
+)
+
+// Admin is the built-in tenant of the host's operators
+const Admin = "admin"
+
+var (
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrNotAllowed    = errors.New("not allowed")
+)
+
+// Resources is an amount of capacity: a quota, a tenant's usage or what one VM takes.
+// In a quota, 0 means unlimited.
+type Resources struct {
+	VMs       int `json:"vms" yaml:"vms"`
+	VCPUs     int `json:"vcpus" yaml:"vcpus"`
+	RAMMB     int `json:"ram_mb" yaml:"ram_mb"`
+	DiskGB    int `json:"disk_gb" yaml:"disk_gb"`
+	PublicIPs int `json:"public_ips" yaml:"public_ips"` // Bridged NICs
+}
+
+// Add returns r + o (o may be negative, e.g. when a resize shrinks a VM)
+func (r Resources) Add(o Resources) Resources {
+	return Resources{
+		VMs:       r.VMs + o.VMs,
+		VCPUs:     r.VCPUs + o.VCPUs,
+		RAMMB:     r.RAMMB + o.RAMMB,
+		DiskGB:    r.DiskGB + o.DiskGB,
+		PublicIPs: r.PublicIPs + o.PublicIPs,
+	}
+}
+
+// Sub returns r - o
+func (r Resources) Sub(o Resources) Resources {
+	return r.Add(Resources{-o.VMs, -o.VCPUs, -o.RAMMB, -o.DiskGB, -o.PublicIPs})
+}
+
+// Check reports whether adding add to used stays within the quota q.
+// Only growing dimensions are checked, so shrinking is always allowed.
+func (q Resources) Check(used, add Resources) error {
+	var over []string
+	limit := func(name string, max, used, add int) {
+		if max > 0 && add > 0 && used+add > max {
+			over = append(over, fmt.Sprintf("%s %d/%d", name, used+add, max))
+		}
+	}
+	limit("vms", q.VMs, used.VMs, add.VMs)
+	limit("vcpus", q.VCPUs, used.VCPUs, add.VCPUs)
+	limit("ram_mb", q.RAMMB, used.RAMMB, add.RAMMB)
+	limit("disk_gb", q.DiskGB, used.DiskGB, add.DiskGB)
+	limit("public_ips", q.PublicIPs, used.PublicIPs, add.PublicIPs)
+	if len(over) > 0 {
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, strings.Join(over, ", "))
+	}
+	return nil
+}
+
+// Tenant owns VMs and API keys
+type Tenant struct {
+	Name      string    `json:"name"`
+	Quota     Resources `json:"quota"`
+	Networks  []string  `json:"networks,omitempty"` // Bridges/"network:<name>" it may attach to, besides "default"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MayUse reports whether VMs of the tenant may attach to network
+func (t Tenant) MayUse(network string) bool {
+	return t.Name == Admin || network == "default" || slices.Contains(t.Networks, network)
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Store persists tenants to a JSON file. `tenant create/set/rm` and the
+// listen daemon share it, so quota changes apply without a restart.
+type Store struct {
+	Path    string
+	file    *jsonfile.File[Tenant]
+	tenants map[string]Tenant
+	mu      sync.Mutex
+}
+
+func NewStore(path string) (*Store, error) {
+	s := &Store{Path: path, file: jsonfile.New[Tenant](path, 0644)}
+	tenants, _, err := s.file.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenants: %w", err)
+	}
+	s.tenants = tenants
+	return s, nil
+}
+
+// current returns the tenants, re-read if another process changed the file.
+// The map is never modified afterwards, so callers may read it unlocked.
+func (s *Store) current() map[string]Tenant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tenants, changed, err := s.file.Load(); err == nil && changed {
+		s.tenants = tenants
+	}
+	return s.tenants
+}
+
+// update applies change to the tenants on disk
+func (s *Store) update(change func(tenants map[string]Tenant) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tenants, err := s.file.Update(change)
+	if err != nil {
+		return err
+	}
+	s.tenants = tenants
+	return nil
+}
+
+// Get returns a tenant; Admin always exists
+func (s *Store) Get(name string) (Tenant, error) {
+	if name == "" || name == Admin {
+		return Tenant{Name: Admin}, nil
+	}
+	t, ok := s.current()[name]
+	if !ok {
+		return Tenant{}, fmt.Errorf("tenant '%s' not found", name)
+	}
+	return t, nil
+}
+
+// List returns all tenants (Admin first, then by name)
+func (s *Store) List() []Tenant {
+	tenants := s.current()
+	list := make([]Tenant, 0, len(tenants)+1)
+	for _, t := range tenants {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return append([]Tenant{{Name: Admin}}, list...)
+}
+
+// Put creates or updates a tenant
+func (s *Store) Put(t Tenant) error {
+	if t.Name == Admin {
+		return fmt.Errorf("the %s tenant is built in and has no quota", Admin)
+	}
+	if !validName.MatchString(t.Name) {
+		return fmt.Errorf("tenant name must be lowercase letters, digits and dashes")
+	}
+	for _, n := range t.Networks {
+		if err := core.CheckNetwork(n); err != nil {
+			return err
+		}
+	}
+	return s.update(func(tenants map[string]Tenant) error {
+		if old, ok := tenants[t.Name]; ok {
+			t.CreatedAt = old.CreatedAt
+		} else if t.CreatedAt.IsZero() {
+			t.CreatedAt = time.Now()
+		}
+		tenants[t.Name] = t
+		return nil
+	})
+}
+
+// Delete removes a tenant. Callers check it owns nothing anymore.
+func (s *Store) Delete(name string) error {
+	return s.update(func(tenants map[string]Tenant) error {
+		if _, ok := tenants[name]; !ok {
+			return fmt.Errorf("tenant '%s' not found", name)
+		}
+		delete(tenants, name)
+		return nil
+	})
+}
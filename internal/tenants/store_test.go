@@ -0,0 +1,103 @@
+package tenants
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResourcesCheck(t *testing.T) {
+	quota := Resources{VMs: 3, VCPUs: 4, RAMMB: 4096, DiskGB: 100}
+	full := Resources{VMs: 3, VCPUs: 4, RAMMB: 4096, DiskGB: 100}
+	small := Resources{VMs: 1, VCPUs: 1, RAMMB: 1024, DiskGB: 25}
+
+	cases := []struct {
+		name        string
+		quota, used Resources
+		add         Resources
+		ok          bool
+	}{
+		{"create within quota", quota, small, small, true},
+		{"create up to the limit", quota, Resources{VMs: 2, VCPUs: 3, RAMMB: 3072, DiskGB: 75}, small, true},
+		{"create over vms", quota, Resources{VMs: 3}, small, false},
+		{"create over ram", quota, Resources{VMs: 1, RAMMB: 3584}, small, false},
+		{"grow over vcpus", quota, Resources{VMs: 2, VCPUs: 4}, Resources{VCPUs: 1}, false},
+		{"grow within", quota, small, Resources{VCPUs: 1, RAMMB: 1024}, true},
+
+		// Shrinking always works, even above a quota that was lowered later
+		{"shrink at the limit", quota, full, Resources{VCPUs: -2, RAMMB: -2048}, true},
+		{"shrink above a lowered quota", Resources{VCPUs: 2, RAMMB: 2048}, full, Resources{VCPUs: -1, RAMMB: -1024}, true},
+		{"delete above a lowered quota", Resources{VMs: 1}, full, Resources{VMs: -1, VCPUs: -1, RAMMB: -1024, DiskGB: -25}, true},
+		{"grow one dimension, shrink another", quota, full, Resources{VCPUs: -2, DiskGB: 10}, false},
+		{"no change while above a lowered quota", Resources{VMs: 1}, full, Resources{}, true},
+
+		// 0 means unlimited
+		{"unlimited", Resources{}, full, full, true},
+		{"only vms limited", Resources{VMs: 4}, full, small, true},
+		{"public ips", Resources{PublicIPs: 1}, Resources{PublicIPs: 1}, Resources{PublicIPs: 1}, false},
+	}
+	for _, tc := range cases {
+		err := tc.quota.Check(tc.used, tc.add)
+		if tc.ok && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, ErrQuotaExceeded)
+		}
+	}
+}
+
+func TestResourcesCheckResize(t *testing.T) {
+	starter := Resources{VMs: 1, VCPUs: 1, RAMMB: 1024, DiskGB: 25}
+	pro := Resources{VMs: 1, VCPUs: 4, RAMMB: 8192, DiskGB: 100}
+	quota := Resources{VCPUs: 4, RAMMB: 8192}
+
+	// A resize counts only the difference, and the VM itself isn't counted again
+	if err := quota.Check(starter, pro.Sub(starter)); err != nil {
+		t.Errorf("starter -> pro with the room: %v", err)
+	}
+	if err := quota.Check(pro, starter.Sub(pro)); err != nil {
+		t.Errorf("pro -> starter: %v", err)
+	}
+	if err := quota.Check(starter.Add(starter), pro.Sub(starter)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("starter -> pro next to another VM: %v, want %v", err, ErrQuotaExceeded)
+	}
+}
+
+// `tenant set` runs in another process than the daemon enforcing the quota
+func TestStoreSharedFile(t *testing.T) {
+	path := t.TempDir() + "/tenants.json"
+	daemon, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Put(Tenant{Name: "acme", Quota: Resources{VMs: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cli, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Put(Tenant{Name: "acme", Quota: Resources{VMs: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Put(Tenant{Name: "globex"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := daemon.Get("acme"); got.Quota.VMs != 5 {
+		t.Fatalf("daemon sees quota %+v, want the CLI's", got.Quota)
+	}
+
+	if err := daemon.Put(Tenant{Name: "initech"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Get("globex"); err != nil {
+		t.Fatalf("tenant lost after the daemon saved: %v", err)
+	}
+	if err := cli.Delete("initech"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.Get("initech"); err == nil {
+		t.Fatal("deleted tenant still known to the daemon")
+	}
+}
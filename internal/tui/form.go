@@ -0,0 +1,157 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// imageChoice is one entry of the image picker
+type imageChoice struct {
+	Name string // Logical name used for the VM
+	URL  string // Set for catalog entries that still need registering
+}
+
+func (c imageChoice) label() string {
+	if c.URL != "" {
+		return c.Name + " (catalog, will download)"
+	}
+	return c.Name
+}
+
+// field is one line of the create form: free text, or a picker when options is set
+type field struct {
+	label   string
+	value   string
+	secret  bool
+	options []string
+	choice  int
+}
+
+func (f *field) text() string {
+	if f.options != nil {
+		return "◀ " + f.options[f.choice] + " ▶"
+	}
+	if f.secret {
+		return strings.Repeat("*", len(f.value))
+	}
+	return f.value
+}
+
+// createForm collects CreateOptions
+type createForm struct {
+	fields []*field
+	focus  int
+	images []imageChoice
+	err    string
+}
+
+const (
+	fieldName = iota
+	fieldImage
+	fieldPlan
+	fieldUser
+	fieldPassword
+)
+
+// newCreateForm offers registered images first, then catalog images not registered yet
+func newCreateForm(registered []images.ImageInfo, catalog []images.OSImage) *createForm {
+	f := &createForm{}
+	seen := map[string]bool{}
+	for _, img := range registered {
+		f.images = append(f.images, imageChoice{Name: img.Name})
+		seen[img.Name] = true
+	}
+	for _, img := range catalog {
+		name := img.Distro + "-" + img.Version
+		if !seen[name] {
+			f.images = append(f.images, imageChoice{Name: name, URL: img.DownloadURL})
+			seen[name] = true
+		}
+	}
+
+	var imageLabels, planLabels []string
+	for _, c := range f.images {
+		imageLabels = append(imageLabels, c.label())
+	}
+	for _, p := range plans.Available {
+		planLabels = append(planLabels, fmt.Sprintf("%s (%d CPU, %d MB, %s)", p.Name, p.CPUs, p.RAM, p.Disk))
+	}
+
+	f.fields = []*field{
+		{label: "Name"},
+		{label: "Image", options: imageLabels},
+		{label: "Plan", options: planLabels},
+		{label: "Username", value: "root"},
+		{label: "Password", secret: true},
+	}
+	if len(imageLabels) == 0 {
+		f.fields[fieldImage] = &field{label: "Image"} // Nothing to pick from: type it
+	}
+	return f
+}
+
+// key handles one key press; it returns true when the form is submitted
+func (f *createForm) key(k rune) bool {
+	cur := f.fields[f.focus]
+	switch k {
+	case keyTab, keyDown:
+		f.focus = (f.focus + 1) % len(f.fields)
+	case keyBackTab, keyUp:
+		f.focus = (f.focus + len(f.fields) - 1) % len(f.fields)
+	case keyLeft:
+		if cur.options != nil {
+			cur.choice = (cur.choice + len(cur.options) - 1) % len(cur.options)
+		}
+	case keyRight:
+		if cur.options != nil {
+			cur.choice = (cur.choice + 1) % len(cur.options)
+		}
+	case keyBackspace:
+		if cur.options == nil && cur.value != "" {
+			cur.value = cur.value[:len(cur.value)-1]
+		}
+	case keyEnter:
+		if f.focus < len(f.fields)-1 {
+			f.focus++
+			return false
+		}
+		return true
+	default:
+		if cur.options == nil && k >= ' ' && k < keyUp {
+			cur.value += string(k)
+		}
+	}
+	return false
+}
+
+// options turns the form into CreateOptions plus the catalog URL to register first (if any)
+func (f *createForm) options() (vm.CreateOptions, string, error) {
+	opts := vm.CreateOptions{
+		Name:     strings.TrimSpace(f.fields[fieldName].value),
+		PlanName: plans.Available[f.fields[fieldPlan].choice].Name,
+		Username: strings.TrimSpace(f.fields[fieldUser].value),
+		Password: f.fields[fieldPassword].value,
+	}
+	if opts.Name == "" {
+		return opts, "", fmt.Errorf("name is required")
+	}
+	if opts.Username == "" {
+		opts.Username = "root"
+	}
+
+	var url string
+	if img := f.fields[fieldImage]; img.options != nil {
+		choice := f.images[img.choice]
+		opts.Image, url = choice.Name, choice.URL
+	} else {
+		opts.Image = strings.TrimSpace(img.value)
+	}
+	if opts.Image == "" {
+		return opts, "", fmt.Errorf("image is required")
+	}
+	return opts, url, nil
+}
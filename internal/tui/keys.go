@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+)
+
+// Keys we care about besides printable runes
+const (
+	keyUp = iota + 0x110000 // Outside the Unicode range
+	keyDown
+	keyLeft
+	keyRight
+	keyEnter
+	keyEsc
+	keyTab
+	keyBackTab
+	keyBackspace
+	keyCtrlC
+)
+
+// readKeys decodes raw-mode stdin into key codes (runes or the constants above)
+func readKeys(r io.Reader, keys chan<- rune) {
+	in := bufio.NewReader(r)
+	defer close(keys)
+	for {
+		ch, _, err := in.ReadRune()
+		if err != nil {
+			return
+		}
+		switch ch {
+		case '\r', '\n':
+			keys <- keyEnter
+		case '\t':
+			keys <- keyTab
+		case 127, '\b':
+			keys <- keyBackspace
+		case 3:
+			keys <- keyCtrlC
+		case 27:
+			keys <- escape(in)
+		default:
+			keys <- ch
+		}
+	}
+}
+
+// escape decodes ESC [ A style sequences; a lone ESC is keyEsc
+func escape(in *bufio.Reader) rune {
+	if in.Buffered() == 0 {
+		return keyEsc
+	}
+	if b, _ := in.ReadByte(); b != '[' && b != 'O' {
+		return keyEsc
+	}
+	b, _ := in.ReadByte()
+	switch b {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'Z':
+		return keyBackTab
+	}
+	// Unknown sequence (F-keys, Home...): skip to its final byte
+	for b >= '0' && b <= '?' && in.Buffered() > 0 {
+		b, _ = in.ReadByte()
+	}
+	return keyEsc
+}
@@ -0,0 +1,178 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reverse = "\x1b[7m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	reset   = "\x1b[0m"
+)
+
+// draw repaints the whole screen in one write (no flicker)
+func (d *Dashboard) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 40 || height < 12 {
+		width, height = 100, 30
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var lines []string
+	lines = append(lines, bold+pad(" HOST-PALACE VPS MANAGER", width)+reset)
+
+	// VM table: takes what the log pane leaves
+	logRows := max(height/3, 5)
+	tableRows := height - logRows - 4
+	header := fmt.Sprintf(" %-20s %-13s %-15s %-13s %6s %15s %-9s", "NAME", "STATE", "IP", "PLAN", "CPU", "RAM", "HEALTH")
+	lines = append(lines, dim+pad(header, width)+reset)
+
+	start := 0
+	if d.selected >= tableRows {
+		start = d.selected - tableRows + 1
+	}
+	for i := start; i < len(d.vms) && i < start+tableRows; i++ {
+		line := formatRow(d.vms[i])
+		if i == d.selected {
+			line = reverse + pad(line, width) + reset
+		} else {
+			line = colorState(d.vms[i].State) + pad(line, width) + reset
+		}
+		lines = append(lines, line)
+	}
+	if len(d.vms) == 0 {
+		lines = append(lines, dim+" No VMs yet. Press c to create one."+reset)
+	}
+	for len(lines) < tableRows+2 {
+		lines = append(lines, "")
+	}
+
+	// Log pane
+	lines = append(lines, dim+pad(" ── Log ", width)+reset)
+	from := max(len(d.logs)-logRows, 0)
+	for _, l := range d.logs[from:] {
+		lines = append(lines, " "+truncate(l, width-1))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	// Footer / prompt
+	footer := " ↑↓ select  s start  x stop  r reboot  d delete  c create  g refresh  q quit"
+	switch {
+	case d.mode == modeConfirm:
+		footer = fmt.Sprintf(" %s%s %s? (y/n)%s", yellow+bold, d.pending, d.current(), reset)
+	case d.lastErr != "":
+		footer = red + " " + d.lastErr + reset
+	}
+	lines = append(lines, footer)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines[:min(len(lines), height)] {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		b.WriteString("\x1b[K") // Clear what the previous frame left on this line
+	}
+	b.WriteString("\x1b[J")
+
+	if d.mode == modeForm {
+		drawForm(&b, d.form, width, height)
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+// drawForm paints the create form as a box over the table
+func drawForm(b *strings.Builder, f *createForm, width, height int) {
+	boxWidth := min(70, width-4)
+	top := max((height-len(f.fields)-6)/2, 1)
+	left := max((width-boxWidth)/2, 1)
+
+	put := func(row int, text string) {
+		fmt.Fprintf(b, "\x1b[%d;%dH%s", top+row, left, text)
+	}
+	put(0, reverse+pad(" Create VM", boxWidth)+reset)
+	for i, fld := range f.fields {
+		line := fmt.Sprintf(" %-9s %s", fld.label+":", fld.text())
+		if i == f.focus {
+			line = bold + pad(line, boxWidth) + reset
+		} else {
+			line = pad(line, boxWidth)
+		}
+		put(i+1, line)
+	}
+	n := len(f.fields) + 1
+	put(n, pad("", boxWidth))
+	if f.err != "" {
+		put(n+1, red+pad(" "+f.err, boxWidth)+reset)
+	} else {
+		put(n+1, pad(" Empty password = generated (shown in the log)", boxWidth))
+	}
+	put(n+2, dim+pad(" Tab/↑↓ field  ←→ choose  Enter next/submit  Esc cancel", boxWidth)+reset)
+}
+
+func formatRow(r row) string {
+	cpu := "-"
+	if r.CPU >= 0 {
+		cpu = fmt.Sprintf("%.0f%%", r.CPU)
+	}
+	ram := "-"
+	if r.MemTotal > 0 {
+		ram = fmt.Sprintf("%d/%d MB", int(r.MemUsed/1024), int(r.MemTotal/1024))
+	}
+	health := "-"
+	if r.Health != nil {
+		health = r.Health.Status
+	}
+	ip := r.IP
+	if ip == "Unknown" {
+		ip = "-"
+	}
+	return fmt.Sprintf(" %-20s %-13s %-15s %-13s %6s %15s %-9s",
+		truncate(r.Name, 20), r.State, ip, truncate(r.Plan, 13), cpu, ram, health)
+}
+
+func colorState(state string) string {
+	switch state {
+	case "running":
+		return green
+	case "error":
+		return red
+	case "stopped":
+		return dim
+	}
+	return yellow
+}
+
+// pad fills s with spaces up to width (visible runes, escapes not counted)
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width <= 1 {
+		return string(r[:max(width, 0)])
+	}
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,305 @@
+// Package tui is a full-screen dashboard: a live VM table, keyboard actions,
+// a create form and a log pane with job progress. It only needs a terminal
+// (raw mode via x/term, drawing with ANSI escapes) and works against any backend.
+package tui
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/vm"
+	"golang.org/x/term"
+)
+
+// RefreshInterval is how often the VM table and usage figures update
+const RefreshInterval = 2 * time.Second
+
+const maxLogLines = 200
+
+type mode int
+
+const (
+	modeTable mode = iota
+	modeConfirm
+	modeForm
+)
+
+// row is a VM plus its usage derived from two metric samples
+type row struct {
+	core.VMState
+	CPU      float64 // Percent of its vCPUs, -1 when unknown
+	MemUsed  float64 // KiB, 0 when unknown
+	MemTotal float64 // KiB
+}
+
+type sample struct {
+	cpuTime float64
+	at      time.Time
+}
+
+// Dashboard holds everything on screen; mu guards it all
+type Dashboard struct {
+	backend backend.Backend
+
+	mu       sync.Mutex
+	vms      []row
+	samples  map[string]sample
+	selected int
+	mode     mode
+	pending  string // Action waiting for y/n
+	form     *createForm
+	logs     []string
+	lastErr  string
+	redraw   chan struct{}
+
+	refreshing atomic.Bool // A slow remote shouldn't pile up refreshes
+}
+
+func New(b backend.Backend) *Dashboard {
+	return &Dashboard{
+		backend: b,
+		samples: map[string]sample{},
+		redraw:  make(chan struct{}, 1),
+	}
+}
+
+// Run takes over the terminal until the user quits
+func (d *Dashboard) Run() error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("the dashboard needs an interactive terminal")
+	}
+
+	// The catalog may scrape mirrors and print: do it before taking the screen
+	if err := images.RefreshCatalog(); err != nil {
+		d.log("⚠️  image catalog unavailable: %v", err)
+	}
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l") // Alternate screen, hide cursor
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(int(os.Stdin.Fd()), oldState)
+	}()
+
+	keys := make(chan rune, 16)
+	go readKeys(os.Stdin, keys)
+
+	d.refresh()
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+	for {
+		d.draw()
+		select {
+		case k, ok := <-keys:
+			if !ok || d.key(k) {
+				return nil
+			}
+		case <-ticker.C:
+			go func() { d.refresh(); d.wake() }()
+		case <-d.redraw:
+		}
+	}
+}
+
+// wake asks the main loop to redraw
+func (d *Dashboard) wake() {
+	select {
+	case d.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// log appends a line to the log pane
+func (d *Dashboard) log(format string, args ...any) {
+	d.mu.Lock()
+	d.logs = append(d.logs, time.Now().Format("15:04:05 ")+fmt.Sprintf(format, args...))
+	if len(d.logs) > maxLogLines {
+		d.logs = d.logs[len(d.logs)-maxLogLines:]
+	}
+	d.mu.Unlock()
+	d.wake()
+}
+
+// refresh reloads the VM list and usage figures; it does nothing while
+// another refresh is still running
+func (d *Dashboard) refresh() {
+	if !d.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer d.refreshing.Store(false)
+
+	vms, err := d.backend.ListVMs()
+	if err != nil {
+		d.mu.Lock()
+		d.lastErr = err.Error()
+		d.mu.Unlock()
+		return
+	}
+
+	rows := make([]row, 0, len(vms))
+	now := time.Now()
+	for _, v := range vms {
+		r := row{VMState: v, CPU: -1}
+		if v.State == "running" {
+			if m, err := d.backend.Metrics(v.Name); err == nil {
+				r.MemTotal = m["mem_kb"]
+				r.MemUsed = m["mem_used_kb"]
+				if r.MemUsed == 0 {
+					r.MemUsed = m["mem_rss_kb"]
+				}
+				d.mu.Lock()
+				if prev, ok := d.samples[v.Name]; ok && m["vcpus"] > 0 {
+					elapsed := float64(now.Sub(prev.at).Nanoseconds())
+					r.CPU = (m["cpu_time_ns"] - prev.cpuTime) / elapsed / m["vcpus"] * 100
+				}
+				d.samples[v.Name] = sample{cpuTime: m["cpu_time_ns"], at: now}
+				d.mu.Unlock()
+			}
+		}
+		rows = append(rows, r)
+	}
+
+	d.mu.Lock()
+	d.vms = rows
+	d.lastErr = ""
+	if d.selected >= len(rows) {
+		d.selected = max(len(rows)-1, 0)
+	}
+	d.mu.Unlock()
+}
+
+// key handles one key press; true means quit
+func (d *Dashboard) key(k rune) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.mode {
+	case modeConfirm:
+		if k == 'y' || k == 'Y' {
+			d.start(d.pending, d.current())
+		}
+		d.mode, d.pending = modeTable, ""
+		return false
+
+	case modeForm:
+		if k == keyEsc || k == keyCtrlC {
+			d.mode, d.form = modeTable, nil
+			return false
+		}
+		if d.form.key(k) {
+			opts, url, err := d.form.options()
+			if err != nil {
+				d.form.err = err.Error()
+				return false
+			}
+			d.mode, d.form = modeTable, nil
+			go d.create(opts, url)
+		}
+		return false
+	}
+
+	switch k {
+	case 'q', keyCtrlC:
+		return true
+	case keyUp, 'k':
+		if d.selected > 0 {
+			d.selected--
+		}
+	case keyDown, 'j':
+		if d.selected < len(d.vms)-1 {
+			d.selected++
+		}
+	case 's', 'x', 'r', 'd':
+		if d.current() != "" {
+			d.mode = modeConfirm
+			d.pending = map[rune]string{'s': "start", 'x': "stop", 'r': "reboot", 'd': "delete"}[k]
+		}
+	case 'c':
+		go d.openForm()
+	case 'g':
+		go func() { d.refresh(); d.wake() }()
+	}
+	return false
+}
+
+// current is the selected VM name ("" when the table is empty); mu must be held
+func (d *Dashboard) current() string {
+	if d.selected < len(d.vms) {
+		return d.vms[d.selected].Name
+	}
+	return ""
+}
+
+// openForm builds the create form. Listing images may be a network call,
+// so it runs without mu.
+func (d *Dashboard) openForm() {
+	registered, err := d.backend.ListImages()
+
+	d.mu.Lock()
+	defer d.wake()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.lastErr = err.Error()
+	}
+	if d.mode != modeTable { // The user moved on meanwhile
+		return
+	}
+	d.form = newCreateForm(registered, images.Catalog)
+	d.mode = modeForm
+}
+
+// start runs an action in the background and logs its progress; mu must be held
+func (d *Dashboard) start(action, name string) {
+	if name == "" {
+		return
+	}
+	go func() {
+		d.log("▶ %s %s", action, name)
+		err := d.backend.Action(name, action, vm.ActionParams{}, d.progress(name))
+		d.done(action, name, err)
+	}()
+}
+
+// create registers a catalog image if needed, then creates the VM
+func (d *Dashboard) create(opts vm.CreateOptions, url string) {
+	d.log("▶ create %s (%s on %s)", opts.Name, opts.PlanName, opts.Image)
+	if url != "" {
+		if err := d.backend.RegisterImage(opts.Image, url, ""); err != nil {
+			d.done("create", opts.Name, err)
+			return
+		}
+	}
+	generated := opts.Password == ""
+	if generated {
+		opts.Password = vm.RandomPassword()
+	}
+	opts.Progress = d.progress(opts.Name)
+	err := d.backend.CreateVM(opts)
+	if err == nil && generated {
+		d.log("🔑 %s: password for %s is %s", opts.Name, opts.Username, opts.Password)
+	}
+	d.done("create", opts.Name, err)
+}
+
+func (d *Dashboard) progress(name string) backend.Progress {
+	return func(step string) { d.log("  [%s] %s", name, step) }
+}
+
+func (d *Dashboard) done(action, name string, err error) {
+	if err != nil {
+		d.log("❌ %s %s: %v", action, name, err)
+	} else {
+		d.log("✅ %s %s", action, name)
+	}
+	d.refresh()
+	d.wake()
+}
@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/events"
+)
+
+// WatchDomainEvents keeps the state model in sync with what libvirt reports
+// and re-publishes every notification as a "domain.lifecycle" event
+// (auto-restart and notifications listen for those).
+func (m *Manager) WatchDomainEvents(stop <-chan struct{}) error {
+	stream, err := m.Driver.WatchEvents(stop)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for e := range stream {
+			m.HandleDomainEvent(e)
+		}
+	}()
+	return nil
+}
+
+// HandleDomainEvent applies one hypervisor notification
+func (m *Manager) HandleDomainEvent(e core.DomainEvent) {
+	reason := strings.ToLower(strings.TrimSpace("libvirt: " + e.Event + " " + e.Detail))
+
+	m.Events.Publish(events.Event{
+		Type:    "domain.lifecycle",
+		Subject: e.Domain,
+		To:      e.Event,
+		Reason:  e.Detail,
+	})
+
+	switch e.Event {
+	case "Started", "Resumed":
+		m.reconcile(e.Domain, Running, reason)
+	case "Suspended", "PMSuspended":
+		m.reconcile(e.Domain, Paused, reason)
+	case "Stopped":
+		if e.Detail == "Crashed" || e.Detail == "Failed" {
+			m.reconcile(e.Domain, Error, reason)
+		} else {
+			m.reconcile(e.Domain, Stopped, reason)
+		}
+	case "Shutdown":
+		// "Shutdown Finished" is followed by "Stopped Shutdown": wait for that one
+	case "Crashed":
+		m.reconcile(e.Domain, Error, reason)
+	case "Defined":
+		if m.tracked(e.Domain) {
+			return // Our own (re)definition, or an update of a known VM
+		}
+		m.reconcile(e.Domain, Stopped, reason)
+	case "Undefined":
+		if !m.tracked(e.Domain) {
+			return
+		}
+		m.reconcile(e.Domain, Deleted, reason)
+		if err := m.Inventory.Delete(e.Domain); err != nil {
+			fmt.Printf("⚠️  Failed to drop %s from inventory: %v\n", e.Domain, err)
+		}
+	case "Reboot":
+		if m.State(e.Domain) == Running {
+			m.announce(e.Domain, Running, Running, reason)
+		}
+	}
+}
+
+func (m *Manager) tracked(id string) bool {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	_, ok := m.states[id]
+	return ok
+}
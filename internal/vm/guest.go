@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+)
+
+const (
+	DefaultExecTimeout = 30 * time.Second
+	MaxExecTimeout     = 5 * time.Minute
+)
+
+// Exec runs a command inside the guest via the agent (no SSH credentials needed)
+func (m *Manager) Exec(id string, req core.ExecRequest) (core.ExecResult, error) {
+	if req.Path == "" {
+		return core.ExecResult{}, fmt.Errorf("exec needs a path")
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = DefaultExecTimeout
+	}
+	if req.Timeout > MaxExecTimeout {
+		req.Timeout = MaxExecTimeout
+	}
+	return m.Driver.GuestExec(id, req)
+}
+
+// ReadFile pulls a small file out of the guest
+func (m *Manager) ReadFile(id, path string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file transfer needs a path")
+	}
+	return m.Driver.ReadGuestFile(id, path)
+}
+
+// WriteFile pushes a small file into the guest
+func (m *Manager) WriteFile(id, path string, data []byte) error {
+	if path == "" {
+		return fmt.Errorf("file transfer needs a path")
+	}
+	return m.Driver.WriteGuestFile(id, path, data)
+}
@@ -0,0 +1,260 @@
+package vm
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/events"
+)
+
+// Health check types
+const (
+	CheckTCP   = "tcp"   // Connect to Port
+	CheckHTTP  = "http"  // GET http://ip:Port/Path, expect ExpectStatus
+	CheckAgent = "agent" // qemu-guest-agent ping, no network needed
+)
+
+const (
+	DefaultCheckInterval = 30 * time.Second
+	DefaultCheckTimeout  = 5 * time.Second
+	maxHealthHistory     = 20
+	healthTick           = 5 * time.Second
+
+	// rebootGrace is how long failures don't count after a health reboot,
+	// so a guest that boots slower than RebootAfter*Interval isn't rebooted again
+	rebootGrace = 5 * time.Minute
+)
+
+// HealthCheck is configured per VM and run by the daemon
+type HealthCheck struct {
+	Type            string `json:"type"`
+	Port            int    `json:"port,omitempty"`
+	Path            string `json:"path,omitempty"`
+	ExpectStatus    int    `json:"expect_status,omitempty"` // Default 200
+	IntervalSeconds int    `json:"interval_seconds"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+	RebootAfter     int    `json:"reboot_after,omitempty"` // Consecutive failures, 0 = never reboot
+}
+
+// healthState is what the checker remembers between probes (in memory only)
+type healthState struct {
+	report     core.HealthReport
+	lastRun    time.Time
+	running    bool
+	graceUntil time.Time // Set by a health reboot, ended early by a passing probe
+}
+
+type healthTracker struct {
+	mu   sync.Mutex
+	vms  map[string]*healthState
+	once sync.Once
+}
+
+// SetHealthCheck validates and stores a VM's check (nil removes it)
+func (m *Manager) SetHealthCheck(id string, check *HealthCheck) (*HealthCheck, error) {
+	if check != nil {
+		if err := check.normalize(); err != nil {
+			return nil, err
+		}
+	}
+	if m.State(id) == Deleted {
+		return nil, fmt.Errorf("vm '%s' not found", id)
+	}
+	if err := m.Inventory.Update(id, func(rec *Record) { rec.Health = check }); err != nil {
+		return nil, err
+	}
+	m.health.mu.Lock()
+	delete(m.health.vms, id) // New check, new history
+	m.health.mu.Unlock()
+	return check, nil
+}
+
+func (c *HealthCheck) normalize() error {
+	switch c.Type {
+	case CheckTCP, CheckHTTP:
+		if c.Port <= 0 || c.Port > 65535 {
+			return fmt.Errorf("%s check needs a port (1-65535)", c.Type)
+		}
+	case CheckAgent:
+	default:
+		return fmt.Errorf("check type must be tcp, http or agent")
+	}
+	if c.Type == CheckHTTP {
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		// Anything else could name another host ("@169.254.169.254/...", "//host/")
+		if !strings.HasPrefix(c.Path, "/") || strings.HasPrefix(c.Path, "//") {
+			return fmt.Errorf("http check path must start with a single /")
+		}
+		if c.ExpectStatus == 0 {
+			c.ExpectStatus = http.StatusOK
+		}
+	}
+	if c.IntervalSeconds <= 0 {
+		c.IntervalSeconds = int(DefaultCheckInterval / time.Second)
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = int(DefaultCheckTimeout / time.Second)
+	}
+	if c.RebootAfter < 0 {
+		return fmt.Errorf("reboot_after can't be negative")
+	}
+	return nil
+}
+
+// Health returns the VM's check and latest results (nil if it has no check)
+func (m *Manager) Health(id string) (*HealthCheck, *core.HealthReport) {
+	rec, _ := m.Inventory.Get(id)
+	if rec.Health == nil {
+		return nil, nil
+	}
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	report := core.HealthReport{Status: "unknown", History: []core.HealthResult{}}
+	if st, ok := m.health.vms[id]; ok {
+		report = st.report
+		report.History = append([]core.HealthResult(nil), st.report.History...)
+	}
+	return rec.Health, &report
+}
+
+// StartHealthChecks probes every running VM that has a check, on its own interval
+func (m *Manager) StartHealthChecks() {
+	m.health.once.Do(func() {
+		go func() {
+			for range time.Tick(healthTick) {
+				for _, rec := range m.Inventory.List() {
+					if rec.Health != nil && m.healthDue(rec.Name, rec.Health) {
+						go m.runCheck(rec.Name, *rec.Health)
+					}
+				}
+			}
+		}()
+	})
+}
+
+// healthDue claims the next probe for a VM if its interval has passed
+func (m *Manager) healthDue(id string, check *HealthCheck) bool {
+	if m.State(id) != Running {
+		return false
+	}
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	st, ok := m.health.vms[id]
+	if !ok {
+		st = &healthState{report: core.HealthReport{Status: "unknown"}}
+		m.health.vms[id] = st
+	}
+	if st.running || time.Since(st.lastRun) < time.Duration(check.IntervalSeconds)*time.Second {
+		return false
+	}
+	st.running = true
+	st.lastRun = time.Now()
+	return true
+}
+
+func (m *Manager) runCheck(id string, check HealthCheck) {
+	start := time.Now()
+	err := m.probe(id, check)
+	result := core.HealthResult{Time: start, OK: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Detail = err.Error()
+	}
+
+	m.health.mu.Lock()
+	st := m.health.vms[id]
+	if st == nil { // Check was replaced while we probed
+		m.health.mu.Unlock()
+		return
+	}
+	st.running = false
+	before := st.report.Status
+	st.report.History = append(st.report.History, result)
+	if len(st.report.History) > maxHealthHistory {
+		st.report.History = st.report.History[len(st.report.History)-maxHealthHistory:]
+	}
+	switch {
+	case result.OK:
+		st.report.Status = "healthy"
+		st.report.ConsecutiveFailures = 0
+		st.graceUntil = time.Time{}
+	case start.Before(st.graceUntil):
+		st.report.Status = "unhealthy" // Still booting after our reboot: not counted
+	default:
+		st.report.Status = "unhealthy"
+		st.report.ConsecutiveFailures++
+	}
+	failures := st.report.ConsecutiveFailures
+	reboot := check.RebootAfter > 0 && failures >= check.RebootAfter
+	if reboot {
+		st.report.ConsecutiveFailures = 0 // Give the reboot a fresh count
+		st.graceUntil = time.Now().Add(rebootGrace)
+	}
+	after := st.report.Status
+	m.health.mu.Unlock()
+
+	if before != after {
+		m.Events.Publish(events.Event{Type: "vm.health", Subject: id, From: before, To: after, Reason: result.Detail})
+	}
+	if reboot {
+		fmt.Printf("🩺 %s failed %d health checks in a row, rebooting\n", id, failures)
+		if err := m.PerformAction(id, "reboot"); err != nil {
+			fmt.Printf("⚠️  Health reboot of %s failed: %v\n", id, err)
+		}
+	}
+}
+
+func (m *Manager) probe(id string, check HealthCheck) error {
+	timeout := time.Duration(check.TimeoutSeconds) * time.Second
+	if check.Type == CheckAgent {
+		return m.Driver.PingGuestAgent(id)
+	}
+
+	info, err := m.Driver.GetVMInfo(id)
+	if err != nil {
+		return err
+	}
+	if info.IP == "" || info.IP == "Unknown" {
+		return fmt.Errorf("no IP address yet")
+	}
+	addr := net.JoinHostPort(info.IP, strconv.Itoa(check.Port))
+
+	if check.Type == CheckTCP {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := http.Client{
+		Timeout: timeout,
+		// A redirect's answer is the result: following it would probe whatever host the VM names
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(checkURL(addr, check.Path))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != check.ExpectStatus {
+		return fmt.Errorf("HTTP %d, expected %d", resp.StatusCode, check.ExpectStatus)
+	}
+	return nil
+}
+
+// checkURL builds the probe's URL: path only ever adds a path and a query to
+// the VM's address
+func checkURL(addr, path string) string {
+	path, query, _ := strings.Cut(path, "?")
+	u := url.URL{Scheme: "http", Host: addr, Path: path, RawQuery: query}
+	return u.String()
+}
@@ -0,0 +1,36 @@
+package vm
+
+import "testing"
+
+func TestHealthCheckPath(t *testing.T) {
+	cases := []struct {
+		path string
+		ok   bool
+		url  string
+	}{
+		{"", true, "http://10.0.0.5:80/"},
+		{"/", true, "http://10.0.0.5:80/"},
+		{"/healthz", true, "http://10.0.0.5:80/healthz"},
+		{"/status?full=1", true, "http://10.0.0.5:80/status?full=1"},
+		{"/a@b", true, "http://10.0.0.5:80/a@b"},
+
+		{"@169.254.169.254/latest/meta-data", false, ""},
+		{"healthz", false, ""},
+		{"//evil.example/", false, ""},
+		{".evil.example/", false, ""},
+		{":8080@evil.example/", false, ""},
+	}
+	for _, tc := range cases {
+		c := HealthCheck{Type: CheckHTTP, Port: 80, Path: tc.path}
+		err := c.normalize()
+		if (err == nil) != tc.ok {
+			t.Errorf("path %q: normalize = %v, want ok=%v", tc.path, err, tc.ok)
+			continue
+		}
+		if err == nil {
+			if got := checkURL("10.0.0.5:80", c.Path); got != tc.url {
+				t.Errorf("path %q probes %s, want %s", tc.path, got, tc.url)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/jsonfile"
+)
+
+// Record is what we remember about a VM beyond what libvirt tells us
+type Record struct {
+	Name      string    `json:"name"`
+	Image     string    `json:"image"`
+	Plan      string    `json:"plan"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	RebuiltAt time.Time `json:"rebuilt_at,omitempty"`
+
+	Networks []string          `json:"networks,omitempty"`
+	SSHKeys  []string          `json:"ssh_keys,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"` // Empty = admin
+
+	Restart  *RestartPolicy  `json:"restart,omitempty"`
+	Restarts []RestartRecord `json:"restarts,omitempty"` // Most recent last
+	Health   *HealthCheck    `json:"health_check,omitempty"`
+
+	Rescued bool `json:"rescued,omitempty"` // Booted from the rescue definition (a stop doesn't end that)
+}
+
+// Inventory persists VM records to a JSON file (same approach as the image
+// registry). The CLI and the listen daemon both change it, so every change
+// re-reads the file under a lock and reads pick up the other's changes.
+type Inventory struct {
+	Path    string
+	file    *jsonfile.File[Record]
+	records map[string]Record
+	mu      sync.Mutex
+}
+
+func NewInventory(path string) (*Inventory, error) {
+	inv := &Inventory{Path: path, file: jsonfile.New[Record](path, 0644)}
+	records, _, err := inv.file.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inventory: %w", err)
+	}
+	inv.records = records
+	return inv, nil
+}
+
+// newRecord is what we assume about a VM created outside vps-manager
+func newRecord(name string) Record {
+	return Record{Name: name, Username: "root", CreatedAt: time.Now()}
+}
+
+// Get returns the record for a VM, if we have one
+func (inv *Inventory) Get(name string) (Record, bool) {
+	rec, ok := inv.current()[name]
+	return rec, ok
+}
+
+// Put adds/replaces a record and saves the inventory
+func (inv *Inventory) Put(rec Record) error {
+	return inv.change(func(records map[string]Record) {
+		records[rec.Name] = rec
+	})
+}
+
+// Update edits a VM's record as it is on disk now (starting from a default
+// one if there's none), so changes made meanwhile by another process stay
+func (inv *Inventory) Update(name string, edit func(rec *Record)) error {
+	return inv.change(func(records map[string]Record) {
+		rec, ok := records[name]
+		if !ok {
+			rec = newRecord(name)
+		}
+		edit(&rec)
+		records[name] = rec
+	})
+}
+
+// Delete forgets a VM
+func (inv *Inventory) Delete(name string) error {
+	if _, ok := inv.Get(name); !ok {
+		return nil
+	}
+	return inv.change(func(records map[string]Record) {
+		delete(records, name)
+	})
+}
+
+// List returns all records sorted by name
+func (inv *Inventory) List() []Record {
+	records := inv.current()
+	list := make([]Record, 0, len(records))
+	for _, rec := range records {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// current returns the records, re-read if another process changed the file.
+// The map is never modified afterwards (changes build a new one), so callers
+// may read it without the lock.
+func (inv *Inventory) current() map[string]Record {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if records, changed, err := inv.file.Load(); err == nil && changed {
+		inv.records = records
+	}
+	return inv.records
+}
+
+func (inv *Inventory) change(edit func(records map[string]Record)) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	records, err := inv.file.Update(func(records map[string]Record) error {
+		edit(records)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	inv.records = records
+	return nil
+}
@@ -0,0 +1,45 @@
+package vm
+
+import "testing"
+
+// The CLI and the daemon each hold an Inventory on the same file
+func TestInventorySharedFile(t *testing.T) {
+	path := t.TempDir() + "/inventory.json"
+	daemon, err := NewInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Put(Record{Name: "web1", Plan: "Starter"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cli, err := NewInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Put(Record{Name: "web2", Tenant: "acme"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Update("web1", func(rec *Record) { rec.Labels = map[string]string{"env": "prod"} }); err != nil {
+		t.Fatal(err)
+	}
+
+	// A change by the daemon keeps what the CLI wrote meanwhile
+	if err := daemon.Update("web1", func(rec *Record) { rec.Restarts = append(rec.Restarts, RestartRecord{Attempt: 1}) }); err != nil {
+		t.Fatal(err)
+	}
+	if rec, ok := daemon.Get("web2"); !ok || rec.Tenant != "acme" {
+		t.Fatalf("web2 = %+v, %v; want the CLI's record", rec, ok)
+	}
+	rec, _ := cli.Get("web1")
+	if rec.Labels["env"] != "prod" || len(rec.Restarts) != 1 || rec.Plan != "Starter" {
+		t.Fatalf("web1 = %+v; want labels, restart and plan", rec)
+	}
+
+	if err := cli.Delete("web2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := daemon.Get("web2"); ok {
+		t.Fatal("deleted record still known to the daemon")
+	}
+}
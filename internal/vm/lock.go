@@ -0,0 +1,85 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxHeavyOps caps concurrent disk creations/downloads on one host
+const DefaultMaxHeavyOps = 2
+
+var ErrOperationInProgress = errors.New("operation in progress")
+
+// Locker serialises lifecycle operations per VM and caps heavy work.
+// It uses flock(2) on files in Dir, so the CLI and the listen daemon
+// (separate processes) see each other's locks.
+type Locker struct {
+	Dir      string
+	MaxHeavy int
+}
+
+func NewLocker(dir string, maxHeavy int) (*Locker, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock dir: %w", err)
+	}
+	if maxHeavy < 1 {
+		maxHeavy = 1
+	}
+	return &Locker{Dir: dir, MaxHeavy: maxHeavy}, nil
+}
+
+// Lock takes the VM's lock or fails right away saying who holds it
+func (l *Locker) Lock(id, op string) (unlock func(), err error) {
+	path := filepath.Join(l.Dir, "vm-"+strings.ReplaceAll(id, "/", "_")+".lock")
+	f, err := tryFlock(path)
+	if err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			holder, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%w on %s: %s", ErrOperationInProgress, id, strings.TrimSpace(string(holder)))
+		}
+		return nil, err
+	}
+
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(fmt.Sprintf("%s (pid %d, since %s)", op, os.Getpid(), time.Now().Format(time.TimeOnly))), 0)
+	return func() {
+		_ = f.Truncate(0)
+		_ = f.Close() // Closing releases the flock
+	}, nil
+}
+
+// Heavy blocks until one of MaxHeavy slots is free (queueing instead of failing).
+// waiting is called once if the caller has to wait.
+func (l *Locker) Heavy(waiting func()) (release func()) {
+	notified := false
+	for {
+		for i := 0; i < l.MaxHeavy; i++ {
+			f, err := tryFlock(filepath.Join(l.Dir, fmt.Sprintf("heavy-%d.lock", i)))
+			if err == nil {
+				return func() { _ = f.Close() }
+			}
+		}
+		if !notified && waiting != nil {
+			waiting()
+			notified = true
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func tryFlock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -1,20 +1,60 @@
 package vm
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Shaman786/vps-manager/internal/cloudinit"
 	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/events"
 	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/tenants"
 )
 
+// DefaultRescueImage is the logical image rescue mode boots (register it like any other image)
+const DefaultRescueImage = "rescue"
+
 type Manager struct {
-	Driver core.HypervisorDriver
+	Driver      core.HypervisorDriver
+	Inventory   *Inventory
+	Locks       *Locker
+	Events      *events.Bus // Every state transition is published here
+	RescueImage string
+	Tenants     *tenants.Store // Optional: quotas and allowed networks per tenant
+
+	states     map[string]State
+	lastAction map[string]time.Time
+	stateMu    sync.Mutex
+	health     healthTracker
+
+	owners   map[string]string // Tenant of VMs without a record (being created, just deleted)
+	reserved map[string]tenants.Resources
+	tenantMu sync.Mutex
+
+	restarting map[string]bool // VMs with an automatic restart pending
+	restartMu  sync.Mutex
 }
 
-func NewManager(driver core.HypervisorDriver) *Manager {
-	return &Manager{Driver: driver}
+func NewManager(driver core.HypervisorDriver, inv *Inventory, locks *Locker) *Manager {
+	return &Manager{
+		Driver:      driver,
+		Inventory:   inv,
+		Locks:       locks,
+		Events:      events.NewBus(),
+		RescueImage: DefaultRescueImage,
+		states:      make(map[string]State),
+		lastAction:  make(map[string]time.Time),
+		health:      healthTracker{vms: make(map[string]*healthState)},
+		owners:      make(map[string]string),
+		reserved:    make(map[string]tenants.Resources),
+		restarting:  make(map[string]bool),
+	}
 }
 
 // CreateOptions packages all the user's desires
@@ -24,33 +64,65 @@ type CreateOptions struct {
 	PlanName string // "Starter", "Professional"
 	Username string // "admin"
 	Password string // "secret123"
+
+	Networks []string          // Optional: NICs in order, default is one NAT NIC
+	SSHKeys  []string          // Optional: authorized for the user and root
+	Labels   map[string]string // Optional: free-form, e.g. role=web
+	Tenant   string            // Optional: owner, default admin (quotas are checked)
+
+	Progress func(step string) // Optional: job progress reporting
+}
+
+func (o CreateOptions) step(msg string) {
+	if o.Progress != nil {
+		o.Progress(msg)
+	}
 }
 
 // CreateServer now orchestrates Plans + CloudInit + Driver
-func (m *Manager) CreateServer(opts CreateOptions) error {
+func (m *Manager) CreateServer(opts CreateOptions) (err error) {
+	unlock, err := m.Locks.Lock(opts.Name, "create")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// 1. FIND THE PLAN
 	// We look up CPU/RAM/Disk from your plans.go file
-	var selectedPlan plans.VMPlan
-	found := false
-	for _, p := range plans.Available {
-		if strings.EqualFold(p.Name, opts.PlanName) {
-			selectedPlan = p
-			found = true
-			break
-		}
-	}
+	opts.step("resolving plan")
+	selectedPlan, found := findPlan(opts.PlanName)
 	if !found {
 		// Fallback to first plan if invalid
 		selectedPlan = plans.Available[0]
 		fmt.Printf("⚠️  Plan '%s' not found. Defaulting to %s.\n", opts.PlanName, selectedPlan.Name)
 	}
 
+	// Quota is held until the record exists and counts on its own
+	if err := m.CheckNetworks(opts.Tenant, opts.Networks); err != nil {
+		return err
+	}
+	releaseQuota, err := m.reserve(opts.Tenant, resourcesOf(selectedPlan, opts.Networks))
+	if err != nil {
+		return err
+	}
+	defer releaseQuota()
+
+	// Nothing by that name exists (we hold its lock): events from here on are the tenant's
+	if m.State(opts.Name) == Deleted {
+		m.setOwner(opts.Name, opts.Tenant)
+	}
+
+	previous, err := m.begin(opts.Name, "create")
+	if err != nil {
+		return err
+	}
+	defer func() { m.finish(opts.Name, "create", previous, err) }()
+
 	// 2. PARSE DISK SIZE
-	// plans.go has "10G", core needs int(10). Simple parse:
-	var diskInt int
-	fmt.Sscanf(selectedPlan.Disk, "%dG", &diskInt)
+	diskInt := parseDiskGB(selectedPlan.Disk)
 
 	// 3. GENERATE CLOUD-INIT
+	opts.step("generating cloud-init")
 	// We use your new generator.go logic
 	configData := cloudinit.ConfigData{
 		Hostname:       opts.Name,
@@ -58,6 +130,7 @@ func (m *Manager) CreateServer(opts CreateOptions) error {
 		UserPass:       opts.Password,
 		RootPass:       opts.Password, // Sync root pass for now
 		AllowRootLogin: true,
+		SSHKeys:        opts.SSHKeys,
 	}
 
 	userData, err := cloudinit.Generate(configData)
@@ -72,13 +145,182 @@ func (m *Manager) CreateServer(opts CreateOptions) error {
 		CPUCores: selectedPlan.CPUs,
 		RAM:      selectedPlan.RAM,
 		DiskSize: diskInt,
-		Network:  "default",
+		Networks: opts.Networks,
 		UserData: userData,
-		MetaData: fmt.Sprintf("instance-id: %s\nlocal-hostname: %s", opts.Name, opts.Name),
+		MetaData: metaData(opts.Name, opts.Name),
+		OnStep:   opts.Progress,
 	}
 
+	release := m.Locks.Heavy(func() { opts.step("waiting for a free provisioning slot") })
+	defer release()
+
 	fmt.Printf("📦 PROVISIONING: %s | %s | %s\n", opts.Name, selectedPlan.Name, opts.Image)
-	return m.Driver.CreateVM(config)
+	opts.step(fmt.Sprintf("provisioning %s on %s (%s)", opts.Name, opts.Image, selectedPlan.Name))
+	if err := m.Driver.CreateVM(config); err != nil {
+		return err
+	}
+	opts.step("vm defined and started")
+
+	// 5. REMEMBER IT (rebuild needs the plan and user later)
+	return m.Inventory.Put(Record{
+		Name:      opts.Name,
+		Image:     opts.Image,
+		Plan:      selectedPlan.Name,
+		Username:  opts.Username,
+		Networks:  opts.Networks,
+		SSHKeys:   opts.SSHKeys,
+		Labels:    opts.Labels,
+		Tenant:    opts.Tenant,
+		CreatedAt: time.Now(),
+	})
+}
+
+// SetLabels replaces a VM's labels (they only live in the inventory)
+func (m *Manager) SetLabels(id string, labels map[string]string) error {
+	if m.State(id) == Deleted {
+		return fmt.Errorf("vm '%s' not found", id)
+	}
+	return m.Inventory.Update(id, func(rec *Record) { rec.Labels = labels })
+}
+
+// ResizeServer moves a VM to another plan. A running VM is powered off for the
+// change and started again; disks only grow.
+func (m *Manager) ResizeServer(id, planName string) (err error) {
+	plan, ok := findPlan(planName)
+	if !ok {
+		return fmt.Errorf("unknown plan '%s'", planName)
+	}
+
+	unlock, err := m.Locks.Lock(id, "resize")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	rec := m.record(id)
+	releaseQuota, err := m.reserve(rec.Tenant, resizeDelta(rec, plan))
+	if err != nil {
+		return err
+	}
+	defer releaseQuota()
+
+	previous, err := m.begin(id, "resize")
+	if err != nil {
+		return err
+	}
+	defer func() { m.finish(id, "resize", previous, err) }()
+
+	release := m.Locks.Heavy(nil)
+	defer release()
+
+	fmt.Printf("📐 RESIZING: %s -> %s\n", id, plan.Name)
+	if previous == Running {
+		if err := m.shutdown(id, "resize"); err != nil {
+			return fmt.Errorf("failed to stop for resize: %w", err)
+		}
+	}
+	err = m.Driver.ResizeVM(core.VMConfig{
+		Name:     id,
+		CPUCores: plan.CPUs,
+		RAM:      plan.RAM,
+		DiskSize: parseDiskGB(plan.Disk),
+	})
+	if previous == Running {
+		// Bring it back even if the resize failed, the old definition still works
+		if startErr := m.Driver.StartVM(id); startErr != nil && err == nil {
+			err = fmt.Errorf("resized but failed to start: %w", startErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.Inventory.Update(id, func(rec *Record) { rec.Plan = plan.Name })
+}
+
+// shutdownGrace is how long a guest gets to power off cleanly. It stays
+// under ownActionWindow, so its stop event still counts as ours.
+const shutdownGrace = 30 * time.Second
+
+// shutdown asks the guest to power off and pulls the plug if it hasn't
+// within shutdownGrace (or has no ACPI support at all)
+func (m *Manager) shutdown(id, action string) error {
+	if err := m.Driver.ShutdownVM(id); err == nil {
+		for deadline := time.Now().Add(shutdownGrace); time.Now().Before(deadline); time.Sleep(time.Second) {
+			if info, err := m.Driver.GetVMInfo(id); err == nil && stateFromStatus(info.Status) == Stopped {
+				return nil
+			}
+		}
+		fmt.Printf("⚠️  %s didn't shut down within %s, forcing it off\n", id, shutdownGrace)
+	}
+	m.markAction(id, action)
+	return m.Driver.StopVM(id)
+}
+
+// RebuildServer reinstalls a VM from another image.
+// The domain (name, MAC, plan, networks, extra disks) stays; only the root
+// overlay and the cloud-init seed are replaced. A fresh instance-id makes
+// cloud-init treat the new disk as a first boot.
+func (m *Manager) RebuildServer(id, image, newPassword string) (err error) {
+	if image == "" {
+		return fmt.Errorf("rebuild needs an image")
+	}
+	if newPassword == "" {
+		return fmt.Errorf("rebuild needs a new password")
+	}
+
+	unlock, err := m.Locks.Lock(id, "rebuild")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	previous, err := m.begin(id, "rebuild")
+	if err != nil {
+		return err
+	}
+	defer func() { m.finish(id, "rebuild", previous, err) }()
+
+	// VMs created before the inventory existed have no record: keep their disk size
+	rec := m.record(id)
+
+	diskInt := 0
+	if p, ok := findPlan(rec.Plan); ok {
+		diskInt = parseDiskGB(p.Disk)
+	}
+
+	userData, err := cloudinit.Generate(cloudinit.ConfigData{
+		Hostname:       id,
+		Username:       rec.Username,
+		UserPass:       newPassword,
+		RootPass:       newPassword,
+		SSHKeys:        rec.SSHKeys, // The new disk starts with no authorized_keys
+		AllowRootLogin: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate cloud-config: %w", err)
+	}
+
+	config := core.VMConfig{
+		Name:     id,
+		Image:    image,
+		DiskSize: diskInt,
+		UserData: userData,
+		MetaData: metaData(fmt.Sprintf("%s-%d", id, time.Now().Unix()), id),
+	}
+
+	release := m.Locks.Heavy(nil)
+	defer release()
+
+	fmt.Printf("♻️  REBUILDING: %s | %s\n", id, image)
+	if err := m.Driver.RebuildVM(config); err != nil {
+		return err
+	}
+
+	return m.Inventory.Update(id, func(rec *Record) {
+		rec.Image = image
+		rec.RebuiltAt = time.Now()
+	})
 }
 
 // ... (ListServers and PerformAction remain unchanged) ...
@@ -90,13 +332,131 @@ func (m *Manager) ListServers() ([]core.VMState, error) {
 	var list []core.VMState
 	for _, id := range ids {
 		if info, err := m.Driver.GetVMInfo(id); err == nil {
+			m.observe(id, info.Status)
+			info.State = string(m.State(id))
+			_, info.Health = m.Health(id)
+			if rec, ok := m.Inventory.Get(id); ok {
+				info.Plan, info.Image = rec.Plan, rec.Image
+			}
+			info.Tenant = m.Owner(id)
 			list = append(list, info)
 		}
 	}
 	return list, nil
 }
 
+// Info is everything we know about one VM (vm info, GET /api/v1/vms/{id})
+type Info struct {
+	core.VMState
+	Record      *Record         `json:"record,omitempty"` // Nil for VMs created outside vps-manager
+	Restart     RestartPolicy   `json:"restart_policy"`
+	Restarts    []RestartRecord `json:"restarts,omitempty"`
+	HealthCheck *HealthCheck    `json:"health_check,omitempty"`
+}
+
+// ErrNotFound is returned for VMs the hypervisor doesn't know
+var ErrNotFound = errors.New("not found")
+
+// Info gathers the hypervisor view, the inventory record and the daemon's policies
+func (m *Manager) Info(id string) (Info, error) {
+	info, err := m.Driver.GetVMInfo(id)
+	if err != nil || m.State(id) == Deleted {
+		return Info{}, fmt.Errorf("vm '%s': %w", id, ErrNotFound)
+	}
+	info.State = string(m.State(id))
+
+	out := Info{VMState: info}
+	if rec, ok := m.Inventory.Get(id); ok {
+		out.Plan, out.Image = rec.Plan, rec.Image
+		out.Record = &rec
+	}
+	out.Tenant = m.Owner(id)
+	out.HealthCheck, out.Health = m.Health(id)
+	out.Restart, out.Restarts = m.RestartPolicyOf(id)
+	return out, nil
+}
+
+// Metrics returns the hypervisor's raw counters for a VM (see the driver's GetMetrics)
+func (m *Manager) Metrics(id string) (map[string]float64, error) {
+	if m.State(id) == Deleted {
+		return nil, fmt.Errorf("vm '%s': %w", id, ErrNotFound)
+	}
+	return m.Driver.GetMetrics(id)
+}
+
+// ImageUsers lists VMs whose disk is an overlay on the image (removing it would break them)
+func (m *Manager) ImageUsers(image string) []string {
+	var users []string
+	for _, rec := range m.Inventory.List() {
+		if rec.Image == image {
+			users = append(users, rec.Name)
+		}
+	}
+	return users
+}
+
+// ActionParams carries the extra input some actions need
+type ActionParams struct {
+	Username string // reset-password/add-ssh-key: guest account (default: the VM's user)
+	Password string // rescue: temporary root password; reset-password: the new one
+	SSHKey   string // rescue: temporary root key; add-ssh-key: the key to append
+}
+
+// Audit returns the params that were set, for the audit log (which redacts the password)
+func (p ActionParams) Audit() map[string]any {
+	out := map[string]any{}
+	for k, v := range map[string]string{"username": p.Username, "password": p.Password, "ssh_key": p.SSHKey} {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// RunHeavy runs fn (e.g. an image download) inside the host-wide cap on heavy operations
+func (m *Manager) RunHeavy(waiting func(), fn func() error) error {
+	release := m.Locks.Heavy(waiting)
+	defer release()
+	return fn()
+}
+
+// Console returns the VM's (localhost-only) VNC endpoint
+func (m *Manager) Console(id string) (core.ConsoleInfo, error) {
+	return m.Driver.GetConsole(id)
+}
+
+// Serial returns the VM's serial pty and console log location
+func (m *Manager) Serial(id string) (core.SerialInfo, error) {
+	return m.Driver.GetSerial(id)
+}
+
 func (m *Manager) PerformAction(id, action string) error {
+	return m.PerformActionWithParams(id, action, ActionParams{})
+}
+
+func (m *Manager) PerformActionWithParams(id, action string, params ActionParams) (err error) {
+	if _, ok := transitions[action]; !ok || action == "create" || action == "rebuild" || action == "resize" {
+		return fmt.Errorf("unknown action: %s", action)
+	}
+
+	// Guest-agent actions don't touch the domain: no need to wait for lifecycle ops
+	if action != "reset-password" && action != "add-ssh-key" {
+		unlock, err := m.Locks.Lock(id, action)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	previous, err := m.begin(id, action)
+	if err != nil {
+		return err
+	}
+	defer func() { m.finish(id, action, previous, err) }()
+
 	switch action {
 	case "start":
 		return m.Driver.StartVM(id)
@@ -105,7 +465,120 @@ func (m *Manager) PerformAction(id, action string) error {
 	case "reboot":
 		return m.Driver.Reboot(id)
 	case "delete":
-		return m.Driver.DeleteVM(id)
+		if err := m.Driver.DeleteVM(id); err != nil {
+			return err
+		}
+		// The vm.deleted event still goes to the owner
+		m.setOwner(id, m.Owner(id))
+		return m.Inventory.Delete(id)
+	case "rescue":
+		if err := m.RescueServer(id, params); err != nil {
+			return err
+		}
+		return m.Inventory.Update(id, func(rec *Record) { rec.Rescued = true })
+	case "unrescue":
+		if err := m.Driver.UnrescueVM(id); err != nil {
+			return err
+		}
+		return m.Inventory.Update(id, func(rec *Record) { rec.Rescued = false })
+	case "reset-password":
+		if params.Password == "" {
+			return fmt.Errorf("reset-password needs a password")
+		}
+		return m.Driver.SetUserPassword(id, m.guestUser(id, params), params.Password)
+	case "add-ssh-key":
+		if params.SSHKey == "" {
+			return fmt.Errorf("add-ssh-key needs an ssh key")
+		}
+		if err := m.Driver.AddSSHKey(id, m.guestUser(id, params), params.SSHKey); err != nil {
+			return err
+		}
+		// Remembered so a fleet spec doesn't add it again
+		return m.Inventory.Update(id, func(rec *Record) {
+			if !slices.Contains(rec.SSHKeys, params.SSHKey) {
+				rec.SSHKeys = append(rec.SSHKeys, params.SSHKey)
+			}
+		})
 	}
 	return fmt.Errorf("unknown action: %s", action)
 }
+
+// RescueServer boots the VM from the rescue image with its own disk attached
+// as a secondary device. The credentials only live on the rescue system.
+func (m *Manager) RescueServer(id string, params ActionParams) error {
+	if params.Password == "" && params.SSHKey == "" {
+		return fmt.Errorf("rescue needs a temporary password or ssh key")
+	}
+
+	// Key-only rescue still needs *some* root password in chpasswd: make it unguessable
+	if params.Password == "" {
+		params.Password = RandomPassword()
+	}
+
+	configData := cloudinit.ConfigData{
+		Hostname:       id + "-rescue",
+		RootPass:       params.Password,
+		AllowRootLogin: true,
+	}
+	if params.SSHKey != "" {
+		configData.SSHKeys = []string{params.SSHKey}
+	}
+	userData, err := cloudinit.Generate(configData)
+	if err != nil {
+		return fmt.Errorf("failed to generate cloud-config: %w", err)
+	}
+
+	config := core.VMConfig{
+		Name:     id,
+		Image:    m.RescueImage,
+		UserData: userData,
+		MetaData: metaData(fmt.Sprintf("%s-rescue-%d", id, time.Now().Unix()), id+"-rescue"),
+	}
+
+	release := m.Locks.Heavy(nil)
+	defer release()
+
+	fmt.Printf("🛟 RESCUE: %s | %s\n", id, m.RescueImage)
+	return m.Driver.RescueVM(config)
+}
+
+// --- HELPERS ---
+
+func findPlan(name string) (plans.VMPlan, bool) {
+	for _, p := range plans.Available {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return plans.VMPlan{}, false
+}
+
+// plans.go has "10G", core needs int(10)
+func parseDiskGB(disk string) int {
+	var gb int
+	fmt.Sscanf(disk, "%dG", &gb)
+	return gb
+}
+
+// guestUser is the account an agent action targets: the one asked for, else
+// the one the VM was created with
+func (m *Manager) guestUser(id string, params ActionParams) string {
+	if params.Username != "" {
+		return params.Username
+	}
+	if rec := m.record(id); rec.Username != "" {
+		return rec.Username
+	}
+	return "root"
+}
+
+// RandomPassword makes a password for when the caller didn't pick one
+func RandomPassword() string {
+	b := make([]byte, 18)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func metaData(instanceID, hostname string) string {
+	return fmt.Sprintf("instance-id: %s\nlocal-hostname: %s", instanceID, hostname)
+}
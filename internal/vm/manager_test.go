@@ -0,0 +1,90 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/events"
+)
+
+// fakeDriver is a hypervisor with VMs in fixed states; it records what it was asked
+type fakeDriver struct {
+	core.HypervisorDriver
+	status  map[string]string // libvirt's view: "running", "shut off"...
+	rebuilt []core.VMConfig
+}
+
+func (d *fakeDriver) GetVMInfo(id string) (core.VMState, error) {
+	return core.VMState{ID: id, Name: id, Status: d.status[id]}, nil
+}
+
+func (d *fakeDriver) RebuildVM(cfg core.VMConfig) error {
+	d.rebuilt = append(d.rebuilt, cfg)
+	return nil
+}
+
+func (d *fakeDriver) StartVM(id string) error { d.status[id] = "running"; return nil }
+
+func newTestManager(t *testing.T, status map[string]string) (*Manager, *fakeDriver) {
+	t.Helper()
+	dir := t.TempDir()
+	inv, err := NewInventory(dir + "/inventory.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locks, err := NewLocker(dir+"/locks", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := &fakeDriver{status: status}
+	return NewManager(driver, inv, locks), driver
+}
+
+func TestRebuildKeepsSSHKeys(t *testing.T) {
+	m, driver := newTestManager(t, map[string]string{"web1": "shut off"})
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ5K0hR0mz7Y7r2c0d8zq3fW7c8T3p8J2o9xk4yM1n2b ops"
+	if err := m.Inventory.Put(Record{Name: "web1", Image: "ubuntu-22.04", Plan: "Starter", Username: "ops", SSHKeys: []string{key}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RebuildServer("web1", "ubuntu-24.04", "n3w-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	if len(driver.rebuilt) != 1 {
+		t.Fatalf("driver rebuilt %d times", len(driver.rebuilt))
+	}
+	if !strings.Contains(driver.rebuilt[0].UserData, "AAAAC3NzaC1lZDI1NTE5AAAAIJ5K0hR0mz7Y7r2c0d8zq3fW7c8T3p8J2o9xk4yM1n2b") {
+		t.Fatalf("the new seed lost the VM's SSH key:\n%s", driver.rebuilt[0].UserData)
+	}
+}
+
+// One crash shows up as several lifecycle events; it's still one restart
+func TestSuperviseOneRestartPerCrash(t *testing.T) {
+	m, _ := newTestManager(t, map[string]string{"web1": "shut off"})
+	policy := RestartPolicy{Mode: RestartOnCrash, MaxRetries: 3, BackoffSeconds: 1}
+	if err := m.Inventory.Put(Record{Name: "web1", Restart: &policy}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.supervise(events.Event{Type: "domain.lifecycle", Subject: "web1", To: "Crashed", Reason: "Panicked"})
+	m.supervise(events.Event{Type: "domain.lifecycle", Subject: "web1", To: "Stopped", Reason: "Crashed"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.restartMu.Lock()
+		pending := m.restarting["web1"]
+		m.restartMu.Unlock()
+		if !pending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("restart still pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, restarts := m.RestartPolicyOf("web1"); len(restarts) != 1 {
+		t.Fatalf("%d restarts recorded, want 1: %+v", len(restarts), restarts)
+	}
+}
@@ -0,0 +1,236 @@
+package vm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/events"
+)
+
+// Restart modes
+const (
+	RestartNever   = "never"
+	RestartOnCrash = "on-crash"
+	RestartAlways  = "always" // Also after a shutdown from inside the guest
+)
+
+const (
+	DefaultMaxRetries = 5
+	DefaultBackoff    = 10 * time.Second
+	maxRestartHistory = 20
+
+	// A VM that stayed up this long is healthy again: the retry count resets
+	stableAfter = 10 * time.Minute
+	// Stops right after one of our own actions are ours, not the guest's
+	ownActionWindow = time.Minute
+)
+
+// RestartPolicy says what the daemon does when a VM goes down on its own
+type RestartPolicy struct {
+	Mode           string `json:"mode"`
+	MaxRetries     int    `json:"max_retries"`
+	BackoffSeconds int    `json:"backoff_seconds"` // Doubles with every consecutive restart
+	CrashLoop      bool   `json:"crash_loop"`      // Set when MaxRetries ran out
+}
+
+// RestartRecord is one automatic restart
+type RestartRecord struct {
+	Time    time.Time `json:"time"`
+	Reason  string    `json:"reason"`
+	Attempt int       `json:"attempt"`
+	Result  string    `json:"result"`
+}
+
+// SetRestartPolicy validates and stores the policy and mirrors it to libvirt autostart
+func (m *Manager) SetRestartPolicy(id string, p RestartPolicy) (RestartPolicy, error) {
+	switch p.Mode {
+	case RestartNever, RestartOnCrash, RestartAlways:
+	case "":
+		p.Mode = RestartNever
+	default:
+		return p, fmt.Errorf("restart mode must be never, on-crash or always")
+	}
+	if p.MaxRetries <= 0 {
+		p.MaxRetries = DefaultMaxRetries
+	}
+	if p.BackoffSeconds <= 0 {
+		p.BackoffSeconds = int(DefaultBackoff / time.Second)
+	}
+	p.CrashLoop = false // Setting a policy is also how an operator clears a crash loop
+
+	if m.State(id) == Deleted {
+		return p, fmt.Errorf("vm '%s' not found", id)
+	}
+	if err := m.Driver.SetAutostart(id, p.Mode != RestartNever); err != nil {
+		return p, err
+	}
+
+	return p, m.Inventory.Update(id, func(rec *Record) { rec.Restart = &p })
+}
+
+// RestartPolicyOf returns the policy (never if none was set) and the restart history
+func (m *Manager) RestartPolicyOf(id string) (RestartPolicy, []RestartRecord) {
+	rec, _ := m.Inventory.Get(id)
+	if rec.Restart == nil {
+		return RestartPolicy{Mode: RestartNever}, rec.Restarts
+	}
+	return *rec.Restart, rec.Restarts
+}
+
+// CrashLoops lists VMs whose restart policy gave up
+func (m *Manager) CrashLoops() []Record {
+	var list []Record
+	for _, rec := range m.Inventory.List() {
+		if rec.Restart != nil && rec.Restart.CrashLoop {
+			list = append(list, rec)
+		}
+	}
+	return list
+}
+
+// StartSupervisor enforces restart policies from the domain.lifecycle events
+// published by WatchDomainEvents
+func (m *Manager) StartSupervisor() {
+	stream, _ := m.Events.Subscribe(64)
+	go func() {
+		for e := range stream {
+			m.supervise(e)
+		}
+	}()
+}
+
+// supervise starts a restart for an unexpected stop, unless one is already
+// pending: libvirt reports a crash more than once (Crashed, then Stopped)
+func (m *Manager) supervise(e events.Event) {
+	if e.Type != "domain.lifecycle" {
+		return
+	}
+	reason, ok := m.unexpectedStop(e)
+	if !ok {
+		return
+	}
+	m.restartMu.Lock()
+	defer m.restartMu.Unlock()
+	if m.restarting[e.Subject] {
+		return
+	}
+	m.restarting[e.Subject] = true
+	go func() {
+		defer func() {
+			m.restartMu.Lock()
+			delete(m.restarting, e.Subject)
+			m.restartMu.Unlock()
+		}()
+		m.autoRestart(e.Subject, reason)
+	}()
+}
+
+// unexpectedStop decides whether an event is a crash/guest shutdown we should react to
+func (m *Manager) unexpectedStop(e events.Event) (string, bool) {
+	crashed := e.To == "Crashed" || (e.To == "Stopped" && (e.Reason == "Crashed" || e.Reason == "Failed"))
+	stopped := e.To == "Stopped" && !crashed
+
+	if !crashed && !stopped {
+		return "", false
+	}
+	if m.recentAction(e.Subject) {
+		return "", false
+	}
+
+	policy, _ := m.RestartPolicyOf(e.Subject)
+	switch {
+	case policy.CrashLoop || policy.Mode == RestartNever:
+		return "", false
+	case crashed:
+		return "crashed (" + e.Reason + ")", true
+	case policy.Mode == RestartAlways:
+		return "stopped outside vps-manager (" + e.Reason + ")", true
+	}
+	return "", false
+}
+
+func (m *Manager) autoRestart(id, reason string) {
+	rec := m.record(id)
+	if rec.Restart == nil {
+		return
+	}
+	policy := *rec.Restart
+
+	// Count consecutive restarts: a run ends at the first gap longer than stableAfter
+	attempt := 1
+	next := time.Now()
+	for i := len(rec.Restarts) - 1; i >= 0; i-- {
+		if next.Sub(rec.Restarts[i].Time) > stableAfter {
+			break
+		}
+		next = rec.Restarts[i].Time
+		attempt++
+	}
+
+	if attempt > policy.MaxRetries {
+		fmt.Printf("🔁 %s is crash looping (%d restarts), giving up\n", id, policy.MaxRetries)
+		policy.CrashLoop = true
+		_ = m.Inventory.Update(id, func(rec *Record) { rec.Restart = &policy })
+		m.setState(id, Error, "crash loop")
+		m.Events.Publish(events.Event{Type: "vm.crashloop", Subject: id, Reason: reason})
+		return
+	}
+
+	wait := time.Duration(policy.BackoffSeconds) * time.Second << (attempt - 1)
+	fmt.Printf("🔁 %s %s: restarting in %s (attempt %d/%d)\n", id, reason, wait, attempt, policy.MaxRetries)
+	time.Sleep(wait)
+
+	started := m.PerformAction(id, "start")
+
+	// Edit what's stored now: the policy may have changed while we slept
+	err := m.Inventory.Update(id, func(rec *Record) {
+		rec.Restarts = append(rec.Restarts, RestartRecord{Time: time.Now(), Reason: reason, Attempt: attempt, Result: resultOf(started)})
+		if len(rec.Restarts) > maxRestartHistory {
+			rec.Restarts = rec.Restarts[len(rec.Restarts)-maxRestartHistory:]
+		}
+	})
+	if err != nil {
+		fmt.Printf("⚠️  Failed to record restart of %s: %v\n", id, err)
+	}
+	m.Events.Publish(events.Event{Type: "vm.restarted", Subject: id, Reason: reason})
+}
+
+// downActions take the domain down, so a stop event right after them is ours.
+// Starts aren't among them: a crash right after one (even the supervisor's
+// own) must still count towards the crash loop.
+var downActions = map[string]bool{
+	"stop": true, "reboot": true, "delete": true, "rebuild": true,
+	"resize": true, "rescue": true, "unrescue": true,
+}
+
+// markAction remembers when we last took a VM down ourselves
+func (m *Manager) markAction(id, action string) {
+	if !downActions[action] {
+		return
+	}
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.lastAction[id] = time.Now()
+}
+
+func (m *Manager) recentAction(id string) bool {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return time.Since(m.lastAction[id]) < ownActionWindow
+}
+
+// record returns the inventory record, or a fresh one for VMs created before the inventory
+func (m *Manager) record(id string) Record {
+	rec, ok := m.Inventory.Get(id)
+	if !ok {
+		rec = newRecord(id)
+	}
+	return rec
+}
+
+func resultOf(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
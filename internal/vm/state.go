@@ -0,0 +1,207 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/events"
+)
+
+// State is our view of a VM's lifecycle (richer than virsh domstate)
+type State string
+
+const (
+	Provisioning State = "provisioning"
+	Running      State = "running"
+	Stopped      State = "stopped"
+	Paused       State = "paused"
+	Rebuilding   State = "rebuilding"
+	Rescued      State = "rescued"
+	Migrating    State = "migrating"
+	Error        State = "error"
+	Deleted      State = "deleted"
+)
+
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// transition describes what an action needs and where it leads
+type transition struct {
+	from     []State // Allowed starting states
+	during   State   // Shown while the action runs ("" = no intermediate state)
+	to       State   // Final state on success
+	noRescue bool    // Refused while the VM has its rescue definition, even shut off
+}
+
+var transitions = map[string]transition{
+	"create":         {from: []State{Deleted}, during: Provisioning, to: Running},
+	"start":          {from: []State{Stopped, Error}, to: Running},
+	"stop":           {from: []State{Running, Paused, Rescued, Error}, to: Stopped},
+	"reboot":         {from: []State{Running}, to: Running},
+	"delete":         {from: []State{Running, Stopped, Paused, Rescued, Error}, to: Deleted},
+	"rebuild":        {from: []State{Running, Stopped, Error}, during: Rebuilding, to: Running, noRescue: true},
+	"resize":         {from: []State{Running, Stopped}, noRescue: true},
+	"rescue":         {from: []State{Running, Stopped, Error}, to: Rescued},
+	"unrescue":       {from: []State{Rescued, Running, Stopped}, to: Running}, // After a restart we can't tell rescue from running
+	"reset-password": {from: []State{Running, Rescued}},
+	"add-ssh-key":    {from: []State{Running, Rescued}},
+}
+
+// State returns the tracked state, or derives it from the hypervisor
+func (m *Manager) State(id string) State {
+	m.stateMu.Lock()
+	st, ok := m.states[id]
+	m.stateMu.Unlock()
+	if ok {
+		return st
+	}
+
+	info, err := m.Driver.GetVMInfo(id)
+	if err != nil {
+		return Deleted
+	}
+	st = stateFromStatus(info.Status)
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if cur, ok := m.states[id]; ok {
+		return cur // Someone else got there first
+	}
+	if st != Deleted {
+		m.states[id] = st
+	}
+	return st
+}
+
+// begin validates action against the current state and enters the
+// intermediate state if it has one. It returns the state to restore on failure.
+func (m *Manager) begin(id, action string) (State, error) {
+	t, ok := transitions[action]
+	if !ok {
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+
+	current := m.State(id)
+	if !slices.Contains(t.from, current) {
+		return current, fmt.Errorf("%w: cannot %s %s while it is %s", ErrInvalidTransition, action, id, describe(current))
+	}
+	if rec, _ := m.Inventory.Get(id); t.noRescue && rec.Rescued {
+		return current, fmt.Errorf("%w: cannot %s %s while it is in rescue mode (unrescue it first)", ErrInvalidTransition, action, id)
+	}
+	if t.during != "" {
+		m.setState(id, t.during, action)
+	}
+	m.markAction(id, action)
+	return current, nil
+}
+
+// finish moves to the action's final state, or to error/back on failure
+func (m *Manager) finish(id, action string, previous State, err error) {
+	t := transitions[action]
+	switch {
+	case err == nil && action == "reboot":
+		m.announce(id, previous, Running, action)
+	case err == nil && t.to != "":
+		m.setState(id, t.to, action)
+	case err == nil:
+		// Actions without a final state (guest agent) leave the VM as it was
+	case action == "create":
+		// The driver rolled everything back: the VM is gone again
+		m.setState(id, Error, action+": "+err.Error())
+		m.forget(id)
+	case t.during != "":
+		m.setState(id, Error, action+": "+err.Error())
+	default:
+		m.setState(id, previous, action+" failed")
+	}
+}
+
+// setState records a transition and tells everyone about it
+func (m *Manager) setState(id string, to State, reason string) {
+	m.stateMu.Lock()
+	from, known := m.states[id]
+	if !known {
+		from = Deleted
+	}
+	if from == to {
+		m.stateMu.Unlock()
+		return
+	}
+	if to == Deleted {
+		delete(m.states, id)
+	} else {
+		m.states[id] = to
+	}
+	m.stateMu.Unlock()
+
+	m.announce(id, from, to, reason)
+}
+
+// announce publishes a transition (also used for running -> running reboots)
+func (m *Manager) announce(id string, from, to State, reason string) {
+	m.Events.Publish(events.Event{
+		Type:    "vm.state",
+		Subject: id,
+		From:    string(from),
+		To:      string(to),
+		Reason:  reason,
+	})
+}
+
+func (m *Manager) forget(id string) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	delete(m.states, id)
+}
+
+// stateFromStatus maps "virsh domstate" output to our states
+func stateFromStatus(status string) State {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "running", "in shutdown", "idle":
+		return Running
+	case "shut off", "shutoff":
+		return Stopped
+	case "paused", "pmsuspended":
+		return Paused
+	case "crashed", "dying":
+		return Error
+	case "":
+		return Deleted
+	}
+	return Error
+}
+
+func describe(s State) string {
+	if s == Deleted {
+		return "not present"
+	}
+	return string(s)
+}
+
+// observe reconciles the tracked state with what the hypervisor reports
+// (a guest shutting itself down, an admin using virsh...)
+func (m *Manager) observe(id, status string) {
+	if !m.tracked(id) {
+		m.setState(id, stateFromStatus(status), "discovered")
+		return
+	}
+	m.reconcile(id, stateFromStatus(status), "observed")
+}
+
+// reconcile moves to seen unless one of our own operations is in charge
+func (m *Manager) reconcile(id string, seen State, reason string) {
+	m.stateMu.Lock()
+	current, known := m.states[id]
+	m.stateMu.Unlock()
+	if !known {
+		current = Deleted
+	}
+
+	switch {
+	case current == Provisioning || current == Rebuilding || current == Migrating:
+	case current == Rescued && seen == Running:
+	case current != seen:
+		m.setState(id, seen, reason)
+	}
+}
@@ -0,0 +1,166 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStateFromStatus(t *testing.T) {
+	cases := map[string]State{
+		"running":     Running,
+		"Running\n":   Running,
+		"in shutdown": Running,
+		"idle":        Running,
+		"shut off":    Stopped,
+		"shutoff":     Stopped,
+		"paused":      Paused,
+		"pmsuspended": Paused,
+		"crashed":     Error,
+		"dying":       Error,
+		"":            Deleted,
+		"no state":    Error,
+	}
+	for status, want := range cases {
+		if got := stateFromStatus(status); got != want {
+			t.Errorf("stateFromStatus(%q) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	cases := []struct {
+		from    State
+		action  string
+		during  State // "" = stays in from while it runs
+		success State
+		failure State
+	}{
+		{Stopped, "start", "", Running, Stopped},
+		{Error, "start", "", Running, Error},
+		{Running, "stop", "", Stopped, Running},
+		{Rescued, "stop", "", Stopped, Rescued},
+		{Running, "reboot", "", Running, Running},
+		{Stopped, "delete", "", Deleted, Stopped},
+		{Running, "rebuild", Rebuilding, Running, Error},
+		{Stopped, "resize", "", Stopped, Stopped},
+		{Running, "rescue", "", Rescued, Running},
+		{Rescued, "unrescue", "", Running, Rescued},
+		{Running, "unrescue", "", Running, Running}, // Rescue we can't see after a restart
+		{Rescued, "reset-password", "", Rescued, Rescued},
+		{Running, "add-ssh-key", "", Running, Running},
+	}
+	for _, tc := range cases {
+		for _, failed := range []bool{false, true} {
+			m, _ := newTestManager(t, map[string]string{})
+			m.setState("web1", tc.from, "test")
+
+			previous, err := m.begin("web1", tc.action)
+			if err != nil {
+				t.Fatalf("%s from %s: %v", tc.action, tc.from, err)
+			}
+			during := tc.during
+			if during == "" {
+				during = tc.from
+			}
+			if st := m.State("web1"); st != during {
+				t.Errorf("%s from %s: %s while running, want %s", tc.action, tc.from, st, during)
+			}
+
+			var result error
+			want := tc.success
+			if failed {
+				result, want = fmt.Errorf("boom"), tc.failure
+			}
+			m.finish("web1", tc.action, previous, result)
+			if st := m.State("web1"); st != want {
+				t.Errorf("%s from %s (failed=%v): ended %s, want %s", tc.action, tc.from, failed, st, want)
+			}
+		}
+	}
+}
+
+func TestTransitionsRefused(t *testing.T) {
+	cases := []struct {
+		from   State
+		action string
+	}{
+		{Running, "start"},
+		{Rescued, "start"},
+		{Stopped, "stop"},
+		{Stopped, "reboot"},
+		{Paused, "reboot"},
+		{Rebuilding, "delete"},
+		{Provisioning, "delete"},
+		{Rebuilding, "rebuild"},
+		{Rescued, "rebuild"},
+		{Rescued, "resize"},
+		{Error, "resize"},
+		{Paused, "resize"},
+		{Rescued, "rescue"},
+		{Error, "unrescue"},
+		{Stopped, "reset-password"},
+		{Stopped, "add-ssh-key"},
+		{Running, "create"},
+	}
+	for _, tc := range cases {
+		m, _ := newTestManager(t, map[string]string{})
+		m.setState("web1", tc.from, "test")
+		if _, err := m.begin("web1", tc.action); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s from %s: got %v, want ErrInvalidTransition", tc.action, tc.from, err)
+		}
+		if st := m.State("web1"); st != tc.from {
+			t.Errorf("%s from %s: refused but moved to %s", tc.action, tc.from, st)
+		}
+	}
+}
+
+// What the hypervisor reports doesn't override our own operations
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		current State
+		seen    State
+		want    State
+	}{
+		{Running, Stopped, Stopped},
+		{Stopped, Running, Running},
+		{Error, Running, Running},
+		{Rescued, Running, Rescued},
+		{Rescued, Stopped, Stopped},
+		{Provisioning, Stopped, Provisioning},
+		{Rebuilding, Stopped, Rebuilding},
+		{Migrating, Paused, Migrating},
+	}
+	for _, tc := range cases {
+		m, _ := newTestManager(t, map[string]string{})
+		m.setState("web1", tc.current, "test")
+		m.reconcile("web1", tc.seen, "observed")
+		if st := m.State("web1"); st != tc.want {
+			t.Errorf("%s, saw %s: now %s, want %s", tc.current, tc.seen, st, tc.want)
+		}
+	}
+}
+
+// A rescued VM that was stopped still has the rescue definition: resizing or
+// rebuilding it would work on the wrong domain
+func TestNoResizeInRescue(t *testing.T) {
+	m, driver := newTestManager(t, map[string]string{"web1": "shut off"})
+	if err := m.Inventory.Put(Record{Name: "web1", Image: "ubuntu-22.04", Plan: "Starter", Rescued: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []string{"resize", "rebuild"} {
+		if _, err := m.begin("web1", action); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s in rescue: got %v, want ErrInvalidTransition", action, err)
+		}
+	}
+	if err := m.RebuildServer("web1", "ubuntu-24.04", "n3w-Passw0rd"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("RebuildServer in rescue: got %v", err)
+	}
+	if len(driver.rebuilt) != 0 {
+		t.Fatal("the driver rebuilt a VM in rescue")
+	}
+	if _, err := m.begin("web1", "unrescue"); err != nil {
+		t.Fatalf("unrescue: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+)
+
+// Owner returns the tenant a VM belongs to. VMs without a record (created
+// before tenants, or outside vps-manager) belong to the admin tenant.
+func (m *Manager) Owner(id string) string {
+	if rec, ok := m.Inventory.Get(id); ok {
+		return tenantName(rec.Tenant)
+	}
+	// Being created, or just deleted: the record isn't there (yet/anymore)
+	m.tenantMu.Lock()
+	defer m.tenantMu.Unlock()
+	if t, ok := m.owners[id]; ok {
+		return t
+	}
+	return tenants.Admin
+}
+
+// Owns reports whether tenant may see and act on the VM
+func (m *Manager) Owns(tenant, id string) bool {
+	tenant = tenantName(tenant)
+	return tenant == tenants.Admin || m.Owner(id) == tenant
+}
+
+// ListServersFor lists the VMs of one tenant (everything for admin)
+func (m *Manager) ListServersFor(tenant string) ([]core.VMState, error) {
+	all, err := m.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	var list []core.VMState
+	for _, v := range all {
+		if m.Owns(tenant, v.Name) {
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
+
+// Usage adds up what a tenant's VMs take, per their plans
+func (m *Manager) Usage(tenant string) tenants.Resources {
+	tenant = tenantName(tenant)
+	var used tenants.Resources
+	for _, rec := range m.Inventory.List() {
+		if tenantName(rec.Tenant) == tenant {
+			plan, _ := findPlan(rec.Plan)
+			used = used.Add(resourcesOf(plan, rec.Networks))
+		}
+	}
+	return used
+}
+
+// CheckQuota fails with tenants.ErrQuotaExceeded if the tenant can't take add
+// on top of what it uses (including creates/resizes still running)
+func (m *Manager) CheckQuota(tenant string, add tenants.Resources) error {
+	release, err := m.reserve(tenant, add)
+	if err == nil {
+		release()
+	}
+	return err
+}
+
+// CheckResize fails if moving the VM to the plan would exceed its tenant's quota
+func (m *Manager) CheckResize(id, planName string) error {
+	plan, ok := findPlan(planName)
+	if !ok {
+		return fmt.Errorf("unknown plan '%s'", planName)
+	}
+	rec := m.record(id)
+	return m.CheckQuota(rec.Tenant, resizeDelta(rec, plan))
+}
+
+// CheckNetworks fails if the tenant may not attach to one of the networks
+func (m *Manager) CheckNetworks(tenant string, networks []string) error {
+	for _, n := range networks {
+		if err := core.CheckNetwork(n); err != nil {
+			return err
+		}
+	}
+	if m.Tenants == nil {
+		return nil
+	}
+	t, err := m.Tenants.Get(tenantName(tenant))
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if !t.MayUse(n) {
+			return fmt.Errorf("%w: tenant '%s' may not use network '%s'", tenants.ErrNotAllowed, t.Name, n)
+		}
+	}
+	return nil
+}
+
+// reserve checks the quota and holds add until release is called, so two
+// concurrent creates can't both squeeze into the last slot
+func (m *Manager) reserve(tenant string, add tenants.Resources) (func(), error) {
+	tenant = tenantName(tenant)
+	if m.Tenants == nil || tenant == tenants.Admin {
+		return func() {}, nil
+	}
+	t, err := m.Tenants.Get(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	m.tenantMu.Lock()
+	defer m.tenantMu.Unlock()
+	used := m.Usage(tenant).Add(m.reserved[tenant])
+	if err := t.Quota.Check(used, add); err != nil {
+		return nil, fmt.Errorf("tenant '%s': %w", tenant, err)
+	}
+	m.reserved[tenant] = m.reserved[tenant].Add(add)
+	return func() {
+		m.tenantMu.Lock()
+		defer m.tenantMu.Unlock()
+		m.reserved[tenant] = m.reserved[tenant].Sub(add)
+	}, nil
+}
+
+// resizeDelta is what a resize adds: only the difference to the current plan counts
+func resizeDelta(rec Record, plan plans.VMPlan) tenants.Resources {
+	current, _ := findPlan(rec.Plan)
+	return resourcesOf(plan, nil).Sub(resourcesOf(current, nil))
+}
+
+func (m *Manager) setOwner(id, tenant string) {
+	m.tenantMu.Lock()
+	defer m.tenantMu.Unlock()
+	m.owners[id] = tenantName(tenant)
+}
+
+// PlanResources is what a VM on the plan with these NICs counts against a quota
+func PlanResources(planName string, networks []string) (tenants.Resources, error) {
+	plan, ok := findPlan(planName)
+	if !ok {
+		return tenants.Resources{}, fmt.Errorf("unknown plan '%s'", planName)
+	}
+	return resourcesOf(plan, networks), nil
+}
+
+func resourcesOf(plan plans.VMPlan, networks []string) tenants.Resources {
+	return tenants.Resources{
+		VMs:       1,
+		VCPUs:     plan.CPUs,
+		RAMMB:     plan.RAM,
+		DiskGB:    parseDiskGB(plan.Disk),
+		PublicIPs: publicIPs(networks),
+	}
+}
+
+// publicIPs counts bridged NICs; "default" and libvirt networks are NATed
+func publicIPs(networks []string) int {
+	n := 0
+	for _, net := range networks {
+		if net != "default" && !strings.HasPrefix(net, "network:") {
+			n++
+		}
+	}
+	return n
+}
+
+func tenantName(t string) string {
+	if t == "" {
+		return tenants.Admin
+	}
+	return t
+}
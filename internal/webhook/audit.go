@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+)
+
+type startKey struct{}
+
+// timed remembers when a request came in, for the audit log's durations
+func timed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), startKey{}, time.Now())))
+	})
+}
+
+// record writes an audit entry for an API call
+func record(log *audit.Log, r *http.Request, action, target string, params map[string]any, err error) {
+	start, _ := r.Context().Value(startKey{}).(time.Time)
+	recordSince(log, entryFor(r), start, action, target, params, err)
+}
+
+// auditedJob wraps a job body so its entry is written when the job ends,
+// with the job's result and full duration
+func auditedJob(log *audit.Log, r *http.Request, action, target string, params map[string]any, fn func(jobs.Progress) error) func(jobs.Progress) error {
+	who := entryFor(r) // The request is gone by the time the job finishes
+	return func(progress jobs.Progress) error {
+		start := time.Now()
+		err := fn(progress)
+		recordSince(log, who, start, action, target, params, err)
+		return err
+	}
+}
+
+func recordSince(log *audit.Log, e audit.Entry, start time.Time, action, target string, params map[string]any, err error) {
+	if log == nil {
+		return
+	}
+	e.Action, e.Target, e.Params, e.Result = action, target, params, audit.Result(err)
+	if !start.IsZero() {
+		e.DurationMS = time.Since(start).Milliseconds()
+	}
+	_ = log.Record(e)
+}
+
+// entryFor fills in who made the request
+func entryFor(r *http.Request) audit.Entry {
+	e := audit.Entry{Actor: actor(r), SourceIP: sourceIP(r), RequestID: requestID(r)}
+	if key, ok := auth.FromContext(r.Context()); ok {
+		e.Tenant, e.KeyID = key.TenantName(), key.ID
+	}
+	return e
+}
+
+// actor names the API key behind a request; X-Actor (e.g. the end user of
+// a panel sharing one key) is kept alongside it
+func actor(r *http.Request) string {
+	a := r.Header.Get("X-Actor")
+	key, ok := auth.FromContext(r.Context())
+	switch {
+	case ok && a != "":
+		return key.Name + " (" + a + ")"
+	case ok:
+		return key.Name
+	case a != "":
+		return a
+	}
+	return "anonymous"
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// GET /api/v1/audit?actor=&tenant=&action=vm.&target=&result=ok|failed&since=24h&until=&limit=
+// Accept: application/x-ndjson (or ?format=jsonl) exports JSON lines.
+// Keys of other tenants only see their own tenant's entries.
+func handleAudit(log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		q := r.URL.Query()
+		f := audit.Filter{
+			Actor:  q.Get("actor"),
+			Tenant: q.Get("tenant"),
+			Action: q.Get("action"),
+			Target: q.Get("target"),
+		}
+		if t := tenantOf(r); t != tenants.Admin {
+			f.Tenant = t
+		}
+		switch q.Get("result") {
+		case "":
+		case "ok":
+			f.Failed = new(bool)
+		case "failed", "error":
+			f.Failed = new(bool)
+			*f.Failed = true
+		default:
+			writeError(w, r, "result must be ok or failed", 400)
+			return
+		}
+		var err error
+		if f.Since, err = parseTime(q.Get("since")); err != nil {
+			writeError(w, r, "since: "+err.Error(), 400)
+			return
+		}
+		if f.Until, err = parseTime(q.Get("until")); err != nil {
+			writeError(w, r, "until: "+err.Error(), 400)
+			return
+		}
+		if l := q.Get("limit"); l != "" {
+			if f.Limit, err = strconv.Atoi(l); err != nil || f.Limit < 0 {
+				writeError(w, r, "limit must be a positive number", 400)
+				return
+			}
+		}
+
+		list, err := log.Query(f)
+		if err != nil {
+			writeError(w, r, err.Error(), 500)
+			return
+		}
+		if q.Get("format") == "jsonl" || r.Header.Get("Accept") == "application/x-ndjson" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+			enc := json.NewEncoder(w)
+			for _, e := range list {
+				enc.Encode(wireAuditEntry(e))
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wireList(list, wireAuditEntry))
+	}
+}
+
+// parseTime takes RFC 3339 or a duration back from now ("24h")
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
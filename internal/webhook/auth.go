@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/auth"
+)
+
+// publicPaths answer without an API key. The console pages/websockets carry
+// their own short-lived signed token instead; the API description and the
+// console's scripts (under publicPrefix) are public.
+var publicPaths = map[string]bool{
+	"/healthz":           true,
+	api.SpecPath:         true,
+	"/console/vnc":       true,
+	"/console/vnc/ws":    true,
+	"/console/serial/ws": true,
+}
+
+const publicPrefix = "/console/static/"
+
+// requireKey rejects requests without a valid API key or client certificate
+// (401) or without the scope the route needs (403)
+func requireKey(keys *auth.Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, publicPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A bearer token wins; otherwise a verified client certificate (mTLS)
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		var key auth.Key
+		var err error
+		switch {
+		case ok && token != "":
+			key, err = keys.Authenticate(token)
+		case r.TLS != nil && len(r.TLS.VerifiedChains) > 0:
+			key, err = keys.AuthenticateCert(r.TLS.VerifiedChains[0][0].Subject.CommonName)
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vps-manager"`)
+			writeError(w, r, "API key required", 401)
+			return
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vps-manager", error="invalid_token"`)
+			writeError(w, r, err.Error(), 401)
+			return
+		}
+		scope := requiredScope(r.Method, apiPath(r.URL.Path))
+		if !key.Allows(scope) {
+			writeError(w, r, "API key '"+key.Name+"' lacks scope "+scope, 403)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), key)))
+	})
+}
+
+// requiredScope maps a route (unversioned, see apiPath) to the scope needed to call it
+func requiredScope(method, path string) string {
+	read := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case strings.HasPrefix(path, "/api/subscriptions"):
+		return auth.ScopeAdmin
+	case path == "/webhook":
+		return auth.ScopeImagesWrite
+	case strings.HasPrefix(path, "/api/images"):
+		if read {
+			return auth.ScopeRead
+		}
+		return auth.ScopeImagesWrite
+	// Guest exec/files and console tokens give shell-level access, even via GET
+	case strings.HasSuffix(path, "/exec"), strings.HasSuffix(path, "/files"),
+		strings.HasPrefix(path, "/api/vms/") && strings.HasSuffix(path, "/console"):
+		return auth.ScopeVMWrite
+	case read:
+		return auth.ScopeRead
+	default:
+		return auth.ScopeVMWrite
+	}
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
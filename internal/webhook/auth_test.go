@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Shaman786/vps-manager/internal/auth"
+)
+
+func TestRequiredScope(t *testing.T) {
+	cases := []struct {
+		method, path, want string
+	}{
+		{"GET", "/api/v1/vms", auth.ScopeRead},
+		{"HEAD", "/api/v1/vms/web1", auth.ScopeRead},
+		{"POST", "/api/v1/vms", auth.ScopeVMWrite},
+		{"DELETE", "/api/v1/vms/web1", auth.ScopeVMWrite},
+		{"POST", "/api/v1/vms/web1/reboot", auth.ScopeVMWrite},
+		{"GET", "/api/v1/vms/web1/metrics", auth.ScopeRead},
+		{"GET", "/api/v1/vms/web1/console-log", auth.ScopeRead},
+		{"GET", "/api/v1/jobs/abc", auth.ScopeRead},
+
+		// Shell-level access needs vm:write even to read
+		{"GET", "/api/v1/vms/web1/exec", auth.ScopeVMWrite},
+		{"GET", "/api/v1/vms/web1/files", auth.ScopeVMWrite},
+		{"POST", "/api/v1/vms/web1/console", auth.ScopeVMWrite},
+		{"GET", "/api/v1/vms/web1/console", auth.ScopeVMWrite},
+
+		// Host-wide areas
+		{"GET", "/api/v1/images", auth.ScopeRead},
+		{"POST", "/api/v1/images", auth.ScopeImagesWrite},
+		{"DELETE", "/api/v1/images/ubuntu-24.04", auth.ScopeImagesWrite},
+		{"POST", "/api/v1/images/ubuntu-24.04/pull", auth.ScopeImagesWrite},
+		{"POST", "/webhook", auth.ScopeImagesWrite},
+		{"GET", "/api/v1/subscriptions", auth.ScopeAdmin},
+		{"DELETE", "/api/v1/subscriptions/abc", auth.ScopeAdmin},
+		{"GET", "/api/v1/subscriptions/abc/deliveries", auth.ScopeAdmin},
+
+		// The deprecated unversioned routes map the same way
+		{"GET", "/api/vms", auth.ScopeRead},
+		{"POST", "/api/vms/action", auth.ScopeVMWrite},
+		{"GET", "/api/vms/web1/exec", auth.ScopeVMWrite},
+		{"POST", "/api/images", auth.ScopeImagesWrite},
+		{"GET", "/api/subscriptions", auth.ScopeAdmin},
+	}
+	for _, tc := range cases {
+		if got := requiredScope(tc.method, apiPath(tc.path)); got != tc.want {
+			t.Errorf("%s %s needs %q, want %q", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRequireKeyTenants(t *testing.T) {
+	keys, err := auth.NewStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(tenant string, scopes ...string) string {
+		tok, _, err := keys.Create("test", tenant, scopes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	admin := token("", auth.ScopeAdmin)
+	tenantAdmin := token("acme", auth.ScopeAdmin)
+	tenantImages := token("acme", auth.ScopeImagesWrite)
+	tenantVMs := token("acme", auth.ScopeVMWrite)
+
+	cases := []struct {
+		token, method, path string
+		want                int
+	}{
+		{admin, "POST", "/api/v1/images", 200},
+		{admin, "GET", "/api/v1/subscriptions", 200},
+		{tenantAdmin, "POST", "/api/v1/images", 403},
+		{tenantAdmin, "DELETE", "/api/v1/images/ubuntu-24.04", 403},
+		{tenantAdmin, "POST", "/api/images/ubuntu-24.04/pull", 403},
+		{tenantAdmin, "GET", "/api/v1/subscriptions", 403},
+		{tenantAdmin, "POST", "/api/subscriptions", 403},
+		{tenantImages, "POST", "/api/v1/images", 403},
+		{tenantImages, "POST", "/webhook", 403},
+		{tenantAdmin, "GET", "/api/v1/images", 200}, // Reading the catalog is fine
+		{tenantAdmin, "POST", "/api/v1/vms", 200},
+		{tenantVMs, "POST", "/api/v1/vms/web1/exec", 200},
+		{tenantVMs, "GET", "/api/v1/subscriptions", 403},
+		{"vpsm_nope_nope", "GET", "/api/v1/vms", 401},
+	}
+	h := requireKey(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range cases {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			key, _ := keys.Authenticate(tc.token)
+			t.Errorf("%s %s with %s key %v: %d, want %d", tc.method, tc.path, key.TenantName(), key.Scopes, w.Code, tc.want)
+		}
+	}
+}
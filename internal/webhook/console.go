@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/console"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// POST /api/v1/vms/{id}/console[?type=serial] -> short-lived token + ready-to-open URL
+func handleConsoleToken(mgr *vm.Manager, signer *console.Signer, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+
+		id := r.PathValue("id")
+		if r.URL.Query().Get("type") == "serial" {
+			_, err := mgr.Serial(id)
+			record(log, r, "vm.console.serial", id, nil, err)
+			if err != nil {
+				writeError(w, r, err.Error(), 500)
+				return
+			}
+			token, expires := signer.Issue(id, "serial", console.DefaultTokenTTL)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(api.ConsoleToken{
+				Token:     token,
+				ExpiresAt: expires,
+				URL:       "/console/serial/ws?token=" + url.QueryEscape(token),
+			})
+			return
+		}
+
+		info, err := mgr.Console(id)
+		record(log, r, "vm.console", id, nil, err)
+		if err != nil {
+			writeError(w, r, err.Error(), 500)
+			return
+		}
+
+		token, expires := signer.Issue(id, "vnc", console.DefaultTokenTTL)
+		// The password rides in the fragment so it never shows up in server logs
+		pageURL := "/console/vnc?token=" + url.QueryEscape(token) + "#password=" + url.QueryEscape(info.Password)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.ConsoleToken{
+			Token:     token,
+			ExpiresAt: expires,
+			URL:       pageURL,
+			Password:  info.Password,
+		})
+	}
+}
+
+// GET /api/v1/vms/{id}/console-log?lines=200 -> tail of the serial console log
+func handleConsoleLog(mgr *vm.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+
+		lines := 200
+		if v, err := strconv.Atoi(r.URL.Query().Get("lines")); err == nil && v > 0 {
+			lines = min(v, 5000)
+		}
+
+		info, err := mgr.Serial(r.PathValue("id"))
+		if err != nil {
+			writeError(w, r, err.Error(), 404)
+			return
+		}
+		data, err := console.Tail(info.LogPath, lines)
+		if err != nil {
+			if os.IsNotExist(err) {
+				writeError(w, r, "no console output captured yet", 404)
+				return
+			}
+			writeError(w, r, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+	}
+}
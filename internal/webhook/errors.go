@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/cloudinit"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// maxJSONBody caps request bodies we decode
+const maxJSONBody = 1 << 20
+
+// writeError answers with the error envelope (same arguments as http.Error)
+func writeError(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	writeEnvelope(w, r, status, api.ErrorDetail{Code: codeFor(status), Message: msg})
+}
+
+// writeErr answers with the status and code that fit err
+func writeErr(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	code := codeFor(status)
+	if errors.Is(err, tenants.ErrQuotaExceeded) {
+		code = api.CodeQuotaExceeded
+	}
+	writeEnvelope(w, r, status, api.ErrorDetail{Code: code, Message: err.Error()})
+}
+
+func writeEnvelope(w http.ResponseWriter, r *http.Request, status int, e api.ErrorDetail) {
+	e.RequestID = requestID(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(api.ErrorBody{Error: e})
+}
+
+func codeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return api.CodeInvalidRequest
+	case http.StatusUnprocessableEntity:
+		return api.CodeValidation
+	case http.StatusUnauthorized:
+		return api.CodeUnauthorized
+	case http.StatusForbidden:
+		return api.CodeForbidden
+	case http.StatusNotFound:
+		return api.CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return api.CodeMethodNotAllowed
+	case http.StatusConflict:
+		return api.CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return api.CodeTooLarge
+	case http.StatusServiceUnavailable:
+		return api.CodeUnavailable
+	}
+	return api.CodeInternal
+}
+
+// errorStatus maps "come back later" errors to 409, quota to 403, everything else to 500
+func errorStatus(err error) int {
+	if errors.Is(err, images.ErrInvalidName) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, tenants.ErrQuotaExceeded) || errors.Is(err, tenants.ErrNotAllowed) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, vm.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, core.ErrGuestAgentUnavailable) ||
+		errors.Is(err, vm.ErrOperationInProgress) ||
+		errors.Is(err, vm.ErrInvalidTransition) ||
+		errors.Is(err, backend.ErrConflict) {
+		return http.StatusConflict
+	}
+	return 500
+}
+
+// fieldErrors collects what is wrong with a request body
+type fieldErrors []api.FieldError
+
+func (f *fieldErrors) add(field, format string, args ...any) {
+	*f = append(*f, api.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// require flags an empty value
+func (f *fieldErrors) require(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		f.add(field, "is required")
+	}
+}
+
+// check flags a value a validator rejected
+func (f *fieldErrors) check(field string, err error) {
+	if err != nil {
+		f.add(field, "%s", err)
+	}
+}
+
+// username flags a name useradd wouldn't accept; empty means the default
+func (f *fieldErrors) username(field, name string) {
+	if name != "" && !cloudinit.ValidUsername(name) {
+		f.add(field, "must be lowercase letters, digits, '_' or '-', starting with a letter or '_'")
+	}
+}
+
+// write answers 422 with every collected error; false if there were none
+func (f fieldErrors) write(w http.ResponseWriter, r *http.Request) bool {
+	if len(f) == 0 {
+		return false
+	}
+	msg := "invalid " + f[0].Field + ": " + f[0].Message
+	if len(f) > 1 {
+		msg = fmt.Sprintf("%s (and %d more)", msg, len(f)-1)
+	}
+	writeEnvelope(w, r, http.StatusUnprocessableEntity, api.ErrorDetail{Code: api.CodeValidation, Message: msg, Fields: f})
+	return true
+}
+
+// decodeJSON reads the body into v and answers 400 if it isn't valid JSON.
+// An empty body leaves v as it is (bodies of e.g. start are optional).
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+		return true
+	case errors.As(err, &tooLarge):
+		writeError(w, r, "request body too large", http.StatusRequestEntityTooLarge)
+	default:
+		writeError(w, r, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+type requestIDKey struct{}
+
+// withRequestID tags every request with an ID (the caller's X-Request-ID if
+// it sent a sane one), echoed in the response and written to the audit log
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
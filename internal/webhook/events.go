@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/events"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// GET /api/v1/events -> server-sent stream of the event bus (other tenants' VMs left out)
+func handleEvents(mgr *vm.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, r, "streaming unsupported", 500)
+			return
+		}
+		stream, cancel := mgr.Events.Subscribe(64)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(200)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e := <-stream:
+				if !eventVisible(mgr, r, e) {
+					continue
+				}
+				data, _ := json.Marshal(wireEvent(e))
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// eventVisible lets image events through (the catalog is host-wide) and
+// every other event, whatever its type, only to the tenant owning its subject
+func eventVisible(mgr *vm.Manager, r *http.Request, e events.Event) bool {
+	return strings.HasPrefix(e.Type, "image.") || mgr.Owns(tenantOf(r), e.Subject)
+}
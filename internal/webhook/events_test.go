@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/events"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+func TestEventVisible(t *testing.T) {
+	dir := t.TempDir()
+	inv, err := vm.NewInventory(dir + "/inventory.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = inv.Put(vm.Record{Name: "web1", Tenant: "acme"})
+	_ = inv.Put(vm.Record{Name: "db1", Tenant: "globex"})
+	mgr := vm.NewManager(nil, inv, nil)
+
+	cases := []struct {
+		tenant string
+		event  events.Event
+		want   bool
+	}{
+		{"acme", events.Event{Type: "vm.state", Subject: "web1"}, true},
+		{"acme", events.Event{Type: "vm.state", Subject: "db1"}, false},
+		{"acme", events.Event{Type: "domain.lifecycle", Subject: "web1"}, true},
+		{"acme", events.Event{Type: "domain.lifecycle", Subject: "db1"}, false},
+		{"acme", events.Event{Type: "domain.lifecycle", Subject: "unmanaged"}, false}, // Admin's
+		{"acme", events.Event{Type: "something.new", Subject: "db1"}, false},
+		{"acme", events.Event{Type: "image.ready", Subject: "ubuntu-24.04"}, true},
+		{"globex", events.Event{Type: "vm.crashloop", Subject: "db1"}, true},
+		{"", events.Event{Type: "domain.lifecycle", Subject: "db1"}, true}, // Admin sees all
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/api/v1/events", nil)
+		if tc.tenant != "" {
+			r = r.WithContext(auth.WithKey(r.Context(), auth.Key{Tenant: tc.tenant}))
+		}
+		if got := eventVisible(mgr, r, tc.event); got != tc.want {
+			t.Errorf("%s sees %s %s: %v, want %v", tc.tenant, tc.event.Type, tc.event.Subject, got, tc.want)
+		}
+	}
+}
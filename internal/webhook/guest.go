@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// POST /api/v1/vms/{id}/exec  {"path":"/bin/sh","args":["-c","uptime"],"timeout":30}
+func handleGuestExec(mgr *vm.Manager, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+		var req api.ExecRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		id := r.PathValue("id")
+		res, err := mgr.Exec(id, core.ExecRequest{
+			Path:    req.Path,
+			Args:    req.Args,
+			Input:   []byte(req.Input),
+			Timeout: time.Duration(req.Timeout) * time.Second,
+		})
+		record(log, r, "vm.exec", id, map[string]any{"path": req.Path, "args": req.Args, "exit_code": res.ExitCode}, err)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.ExecResult(res))
+	}
+}
+
+// GET /api/v1/vms/{id}/files?path=/etc/hosts  -> raw bytes
+// PUT /api/v1/vms/{id}/files?path=/etc/hosts  <- raw bytes
+func handleGuestFiles(mgr *vm.Manager, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		path := r.URL.Query().Get("path")
+
+		switch r.Method {
+		case http.MethodGet:
+			data, err := mgr.ReadFile(id, path)
+			record(log, r, "vm.file.read", id, map[string]any{"path": path, "bytes": len(data)}, err)
+			if err != nil {
+				writeErr(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(data)
+
+		case http.MethodPut, http.MethodPost:
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, core.MaxGuestFileSize))
+			if err != nil {
+				writeError(w, r, "file too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			err = mgr.WriteFile(id, path, data)
+			record(log, r, "vm.file.write", id, map[string]any{"path": path, "bytes": len(data)}, err)
+			if err != nil {
+				writeErr(w, r, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			writeError(w, r, "GET or PUT only", 405)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// GET    /api/v1/vms/{id}/health-check -> check + recent results
+// PUT    /api/v1/vms/{id}/health-check {"type":"http","port":80,"path":"/","reboot_after":3}
+// DELETE /api/v1/vms/{id}/health-check
+func handleHealthCheck(mgr *vm.Manager, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodGet:
+			check, report := mgr.Health(id)
+			json.NewEncoder(w).Encode(api.HealthCheckStatus{Check: wireHealthCheck(check), Report: wireHealthReport(report)})
+
+		case http.MethodPut:
+			var req api.HealthCheck
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			check, err := mgr.SetHealthCheck(id, (*vm.HealthCheck)(&req))
+			record(log, r, "vm.health-check", id, map[string]any{"type": req.Type, "port": req.Port, "reboot_after": req.RebootAfter}, err)
+			if err != nil {
+				writeError(w, r, err.Error(), 400)
+				return
+			}
+			_, report := mgr.Health(id)
+			json.NewEncoder(w).Encode(api.HealthCheckStatus{Check: wireHealthCheck(check), Report: wireHealthReport(report)})
+
+		case http.MethodDelete:
+			_, err := mgr.SetHealthCheck(id, nil)
+			record(log, r, "vm.health-check.delete", id, nil, err)
+			if err != nil {
+				writeError(w, r, err.Error(), 400)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			writeError(w, r, "GET, PUT or DELETE only", 405)
+		}
+	}
+}
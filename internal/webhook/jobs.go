@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/events"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// Actions that copy or create disks: run them as jobs
+var heavyActions = map[string]bool{
+	"delete":   true,
+	"rescue":   true,
+	"unrescue": true,
+}
+
+// GET /api/v1/jobs
+func handleListJobs(mgr *vm.Manager, queue *jobs.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		list := []api.Job{}
+		for _, job := range queue.List() {
+			if jobVisible(mgr, r, job) {
+				list = append(list, wireJob(job))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// GET /api/v1/jobs/{id}  (Accept: text/event-stream for live updates)
+func handleGetJob(mgr *vm.Manager, queue *jobs.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		id := r.PathValue("id")
+		if job, ok := queue.Get(id); !ok || !jobVisible(mgr, r, job) {
+			writeError(w, r, "job not found", 404)
+			return
+		}
+
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			job, ok := queue.Get(id)
+			if !ok {
+				writeError(w, r, "job not found", 404)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(wireJob(job))
+			return
+		}
+
+		updates, cancel, ok := queue.Subscribe(id)
+		if !ok {
+			writeError(w, r, "job not found", 404)
+			return
+		}
+		defer cancel()
+		streamJob(w, r, updates)
+	}
+}
+
+// jobVisible hides other tenants' VM jobs (image jobs are host-wide)
+func jobVisible(mgr *vm.Manager, r *http.Request, job jobs.Job) bool {
+	return !strings.HasPrefix(job.Type, "vm.") || mgr.Owns(tenantOf(r), job.Target)
+}
+
+// streamJob writes server-sent events until the job finishes or the client leaves
+func streamJob(w http.ResponseWriter, r *http.Request, updates <-chan jobs.Job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, "streaming unsupported", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case job, open := <-updates:
+			if !open {
+				return
+			}
+			data, _ := json.Marshal(wireJob(job))
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", job.State, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeJob answers 202 with the job to poll
+func writeJob(w http.ResponseWriter, job jobs.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", APIPrefix+"/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(api.Accepted{Status: string(job.State), JobID: job.ID, ID: job.Target})
+}
+
+// downloadImage is the job body for pulling a registered image
+func downloadImage(mgr *vm.Manager, store *images.Store, name string) func(jobs.Progress) error {
+	return func(progress jobs.Progress) error {
+		waiting := func() { progress("waiting for a free download slot") }
+		return mgr.RunHeavy(waiting, func() error {
+			progress("downloading " + name)
+			if _, err := store.Resolve(name); err != nil {
+				mgr.Events.Publish(events.Event{Type: "image.failed", Subject: name, Reason: err.Error()})
+				return err
+			}
+			progress("image ready")
+			mgr.Events.Publish(events.Event{Type: "image.ready", Subject: name})
+			return nil
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/signing"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// DefaultImageSources are the mirrors the watcher scrapes
+var DefaultImageSources = []string{"cloud-images.ubuntu.com", "download.rockylinux.org"}
+
+// maxReleaseBody caps what we read (and hash) of a release payload
+const maxReleaseBody = 64 << 10
+
+// handleImageWebhook registers and downloads a new release announced by the
+// watcher. The payload must be signed and point at an allowed mirror.
+func handleImageWebhook(mgr *vm.Manager, store *images.Store, queue *jobs.Store, log *audit.Log, verifier *signing.Verifier, sources []string) http.HandlerFunc {
+	type LegacyImageRelease struct {
+		Distro  string `json:"distro"`
+		Version string `json:"version"`
+		URL     string `json:"url"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+		if verifier == nil {
+			writeError(w, r, "release webhook is not configured", 503)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReleaseBody))
+		if err != nil {
+			writeError(w, r, "payload too large", 413)
+			return
+		}
+		if err := verifier.Verify(r, body); err != nil {
+			fmt.Printf("🚫 Rejected release webhook from %s: %v\n", sourceIP(r), err)
+			record(log, r, "image.register", "", map[string]any{"source": "webhook"}, err)
+			writeError(w, r, err.Error(), 401)
+			return
+		}
+
+		var req LegacyImageRelease
+		if err := json.Unmarshal(body, &req); err != nil || req.Distro == "" || req.Version == "" {
+			writeError(w, r, "Invalid JSON: distro, version and url are required", 400)
+			return
+		}
+		logicalName := strings.ToLower(fmt.Sprintf("%s-%s", req.Distro, req.Version))
+		params := map[string]any{"url": req.URL, "source": "webhook"}
+		if !images.ValidName(logicalName) {
+			writeError(w, r, "invalid distro or version: "+images.ErrInvalidName.Error(), 400)
+			return
+		}
+		if err := allowedSource(req.URL, sources); err != nil {
+			fmt.Printf("🚫 Rejected release '%s': %v\n", logicalName, err)
+			record(log, r, "image.register", logicalName, params, err)
+			writeError(w, r, err.Error(), 403)
+			return
+		}
+
+		fmt.Printf("🔔 Beacon Alert: Update found for '%s'\n", logicalName)
+		err = store.Register(logicalName, req.URL, "")
+		record(log, r, "image.register", logicalName, params, err)
+		if err != nil {
+			writeError(w, r, err.Error(), 500)
+			return
+		}
+		queue.Submit("image.download", logicalName, auditedJob(log, r, "image.pull", logicalName, nil, downloadImage(mgr, store, logicalName)))
+		w.WriteHeader(200)
+	}
+}
+
+// allowedSource accepts https URLs on one of the domains or their subdomains
+func allowedSource(raw string, domains []string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid image url %q", raw)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("image url must be https: %s", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image source %s is not allowed (allowed: %s)", host, strings.Join(domains, ", "))
+}
@@ -0,0 +1,37 @@
+package webhook
+
+import "testing"
+
+func TestAllowedSource(t *testing.T) {
+	domains := []string{"cloud-images.ubuntu.com", ".download.rockylinux.org"}
+	cases := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://cloud-images.ubuntu.com/releases/24.04/release/ubuntu-24.04-server-cloudimg-amd64.img", true},
+		{"https://CLOUD-IMAGES.ubuntu.com/x.img", true},
+		{"https://cloud-images.ubuntu.com:443/x.img", true},
+		{"https://mirror.cloud-images.ubuntu.com/x.img", true},
+		{"https://download.rockylinux.org/pub/rocky/9/images/x86_64/Rocky-9-GenericCloud-Base.latest.x86_64.qcow2", true},
+
+		// Look-alikes
+		{"https://evilcloud-images.ubuntu.com/x.img", false},
+		{"https://cloud-images.ubuntu.com.evil.example/x.img", false},
+		{"https://ubuntu.com/x.img", false},
+		{"https://cloud-images.ubuntu.com@evil.example/x.img", false},
+		{"https://evil.example/cloud-images.ubuntu.com/x.img", false},
+		{"https://evil.example/?u=https://cloud-images.ubuntu.com", false},
+
+		// Wrong scheme or not a URL
+		{"http://cloud-images.ubuntu.com/x.img", false},
+		{"ftp://cloud-images.ubuntu.com/x.img", false},
+		{"cloud-images.ubuntu.com/x.img", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		err := allowedSource(tc.url, domains)
+		if (err == nil) != tc.ok {
+			t.Errorf("allowedSource(%q) = %v, want allowed=%v", tc.url, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// GET /api/v1/vms/{id}/restart-policy -> policy + restart history
+// PUT /api/v1/vms/{id}/restart-policy {"mode":"on-crash","max_retries":5,"backoff_seconds":10}
+func handleRestartPolicy(mgr *vm.Manager, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodGet:
+			policy, restarts := mgr.RestartPolicyOf(id)
+			json.NewEncoder(w).Encode(api.RestartPolicyStatus{Policy: api.RestartPolicy(policy), Restarts: wireRestarts(restarts)})
+
+		case http.MethodPut:
+			var req api.RestartPolicy
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			policy, err := mgr.SetRestartPolicy(id, vm.RestartPolicy(req))
+			record(log, r, "vm.restart-policy", id, map[string]any{"mode": policy.Mode, "max_retries": policy.MaxRetries}, err)
+			if err != nil {
+				writeError(w, r, err.Error(), 400)
+				return
+			}
+			_, restarts := mgr.RestartPolicyOf(id)
+			json.NewEncoder(w).Encode(api.RestartPolicyStatus{Policy: api.RestartPolicy(policy), Restarts: wireRestarts(restarts)})
+
+		default:
+			writeError(w, r, "GET or PUT only", 405)
+		}
+	}
+}
+
+// GET /api/v1/crash-loops -> VMs whose restart policy gave up
+func handleCrashLoops(mgr *vm.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		list := []api.VMRecord{}
+		for _, rec := range mgr.CrashLoops() {
+			if mgr.Owns(tenantOf(r), rec.Name) {
+				list = append(list, wireRecord(rec))
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	}
+}
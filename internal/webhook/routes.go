@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/api"
+)
+
+// APIPrefix is the current API version; unversioned /api paths still work
+// but answer with a Deprecation header pointing here
+const APIPrefix = api.Prefix
+
+// VMActions are the POST /api/v1/vms/{id}/<action> routes
+var VMActions = []string{"start", "stop", "reboot", "rescue", "unrescue", "reset-password", "add-ssh-key"}
+
+// apiMux registers each API route under APIPrefix and its legacy alias
+type apiMux struct{ *http.ServeMux }
+
+// API serves path (e.g. "/vms/{id}") at /api/v1/vms/{id} and /api/vms/{id}
+func (m apiMux) API(path string, h http.HandlerFunc) {
+	m.Handle(APIPrefix+path, h)
+	m.Handle("/api"+path, deprecated(h))
+}
+
+// Legacy serves a path that only exists for old clients
+func (m apiMux) Legacy(path string, h http.HandlerFunc) {
+	m.Handle("/api"+path, deprecated(h))
+}
+
+// deprecated marks answers of unversioned paths (RFC 9745); handlers may
+// override the successor Link if the new route looks different
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+APIPrefix+strings.TrimPrefix(r.URL.Path, "/api")+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiPath maps /api/v1/x to /api/x, so path-based checks see one name per route
+func apiPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, APIPrefix); ok && (rest == "" || rest[0] == '/') {
+		return "/api" + rest
+	}
+	return path
+}
+
+// notFound answers unknown /api routes with the error envelope instead of
+// the mux's plain-text 404
+func notFound(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			if _, pattern := mux.Handler(r); pattern == "" {
+				writeError(w, r, "no such endpoint: "+r.Method+" "+r.URL.Path, 404)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /api/openapi.json -> the OpenAPI 3 document of /api/v1
+func handleSpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, "GET only", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(api.Spec)
+}
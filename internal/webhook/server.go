@@ -1,137 +1,161 @@
 package webhook
 
 import (
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
 
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/cloudinit"
+	"github.com/Shaman786/vps-manager/internal/console"
 	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/signing"
+	"github.com/Shaman786/vps-manager/internal/subscriptions"
+	"github.com/Shaman786/vps-manager/internal/tenants"
 	"github.com/Shaman786/vps-manager/internal/vm"
 )
 
-func Start(mgr *vm.Manager, store *images.Store, port string) {
-	// 1. IMAGE WEBHOOK (Legacy/Automated)
-	http.HandleFunc("/webhook", handleImageWebhook(store))
+// Options wires the control plane to the rest of the system
+type Options struct {
+	Addr    string // ":8080"
+	Manager *vm.Manager
+	Store   *images.Store
+	Audit   *audit.Log
+	Console *console.Signer
+	Jobs    *jobs.Store
+	Subs    *subscriptions.Store
+	Keys    *auth.Store // Every route except /healthz and the token-gated consoles needs a key
+	Tenants *tenants.Store
+	TLS     *tls.Config // Nil serves plain HTTP (e.g. behind a TLS proxy)
+
+	// /webhook only takes releases signed with the watcher's secret whose
+	// URL is on one of these domains (or a subdomain)
+	Releases     *signing.Verifier
+	ImageSources []string
+}
 
-	// 2. IMAGE API (Manual Registration - NEW ADDITION)
-	http.HandleFunc("/api/images", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "POST only", 405)
-			return
-		}
-		var req struct {
-			ID     string `json:"id"`
-			URL    string `json:"url"`
-			Format string `json:"format"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", 400)
-			return
-		}
+// Start serves Handler on opts.Addr until the process exits
+func Start(opts Options) {
+	server := &http.Server{
+		Addr:      opts.Addr,
+		Handler:   Handler(opts),
+		TLSConfig: opts.TLS,
+	}
+	if opts.TLS == nil {
+		fmt.Printf("📡 VPS Control Plane running on http://%s (no TLS)\n", opts.Addr)
+		log.Fatal(server.ListenAndServe())
+	}
+	fmt.Printf("📡 VPS Control Plane running on https://%s\n", opts.Addr)
+	log.Fatal(server.ListenAndServeTLS("", "")) // Certificates come from TLSConfig
+}
 
-		fmt.Printf("📥 Manual Image Registration: %s\n", req.ID)
-		
-		// Register and immediately trigger download
-		store.Register(req.ID, req.URL, req.Format)
-		go func() {
-			fmt.Printf("⬇️  Downloading image: %s...\n", req.ID)
-			// FIX: Ignore the first return value (path) with '_'
-			if _, err := store.Resolve(req.ID); err != nil {
-				fmt.Printf("❌ Download failed for %s: %v\n", req.ID, err)
-			} else {
-				fmt.Printf("✅ Image ready: %s\n", req.ID)
-			}
-		}()
-
-		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(map[string]string{"status": "downloading", "id": req.ID})
-	})
-
-	// 3. VM API
-	http.HandleFunc("/api/vms", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		if r.Method == http.MethodGet {
-			vms, _ := mgr.ListServers()
-			json.NewEncoder(w).Encode(vms)
-			return
-		}
+// Handler is the whole control plane: every route behind request IDs, API
+// keys and tenant checks
+func Handler(opts Options) http.Handler {
+	mgr, store, queue, auditLog := opts.Manager, opts.Store, opts.Jobs, opts.Audit
 
-		if r.Method == http.MethodPost {
-			var req struct {
-				Name     string `json:"name"`
-				Image    string `json:"image"`
-				Plan     string `json:"plan"`
-				Username string `json:"username"`
-				Password string `json:"password"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				http.Error(w, "Invalid JSON", 400)
-				return
-			}
-
-			// Defaults
-			if req.Plan == "" { req.Plan = "Starter" }
-			if req.Username == "" { req.Username = "root" }
-			if req.Password == "" { req.Password = "password" }
-
-			opts := vm.CreateOptions{
-				Name:     req.Name,
-				Image:    req.Image,
-				PlanName: req.Plan,
-				Username: req.Username,
-				Password: req.Password,
-			}
-
-			if err := mgr.CreateServer(opts); err != nil {
-				http.Error(w, err.Error(), 500)
-				return
-			}
-			json.NewEncoder(w).Encode(map[string]string{"status": "created", "id": req.Name})
-		}
-	})
+	mux := http.NewServeMux()
+	v1 := apiMux{mux}
+
+	// 1. IMAGE WEBHOOK (signed releases from the watcher)
+	mux.HandleFunc("/webhook", handleImageWebhook(mgr, store, queue, auditLog, opts.Releases, opts.ImageSources))
+
+	// 2. IMAGE API
+	v1.API("/images", handleImages(mgr, store, queue, auditLog))
+	v1.API("/images/{name}", handleImage(mgr, store, auditLog))
+	v1.API("/images/{name}/pull", handleImagePull(mgr, store, queue, auditLog))
+
+	// 3. VM API (list/create, info/delete, one route per action)
+	v1.API("/vms", handleVMs(mgr, queue, auditLog))
+	v1.API("/vms/{id}", handleVM(mgr, queue, auditLog))
+	for _, action := range VMActions {
+		v1.API("/vms/{id}/"+action, handleVMAction(mgr, queue, auditLog, action))
+	}
+
+	// 4. ACTION API (deprecated: {"id":..,"action":..} in the body)
+	v1.Legacy("/vms/action", handleLegacyAction(mgr, queue, auditLog))
+
+	// 5. REBUILD / RESIZE / METRICS / LABELS / PLANS
+	v1.API("/vms/{id}/metrics", handleMetrics(mgr))
+	v1.API("/vms/{id}/rebuild", handleRebuild(mgr, queue, auditLog))
+	v1.API("/vms/{id}/resize", handleResize(mgr, queue, auditLog))
+	v1.API("/vms/{id}/labels", handleLabels(mgr, auditLog))
+	v1.API("/plans", handlePlans())
+
+	// 6. GUEST API (qemu-guest-agent, audited)
+	v1.API("/vms/{id}/exec", handleGuestExec(mgr, auditLog))
+	v1.API("/vms/{id}/files", handleGuestFiles(mgr, auditLog))
 
-	// 4. ACTION API
-	http.HandleFunc("/api/vms/action", func(w http.ResponseWriter, r *http.Request) {
+	// 7. BROWSER CONSOLE (VNC over websocket, gated by signed tokens)
+	v1.API("/vms/{id}/console", handleConsoleToken(mgr, opts.Console, auditLog))
+	mux.HandleFunc("/console/vnc", console.VNCPage())
+	mux.Handle("/console/static/", console.Assets())
+	mux.Handle("/console/vnc/ws", console.VNCProxy(opts.Console, mgr.Console))
+
+	// 8. SERIAL CONSOLE (log tail + interactive websocket)
+	v1.API("/vms/{id}/console-log", handleConsoleLog(mgr))
+	mux.Handle("/console/serial/ws", console.SerialProxy(opts.Console, mgr.Serial))
+
+	// 9. JOBS (poll, or stream with Accept: text/event-stream)
+	v1.API("/jobs", handleListJobs(mgr, queue))
+	v1.API("/jobs/{id}", handleGetJob(mgr, queue))
+
+	// 10. EVENTS (state transitions etc. as server-sent events)
+	v1.API("/events", handleEvents(mgr))
+
+	// 11. OUTBOUND WEBHOOK SUBSCRIPTIONS
+	v1.API("/subscriptions", handleSubscriptions(opts.Subs, auditLog))
+	v1.API("/subscriptions/{id}", handleSubscription(opts.Subs, auditLog))
+	v1.API("/subscriptions/{id}/deliveries", handleDeliveries(opts.Subs))
+
+	// 12. AUTO-RESTART POLICIES
+	v1.API("/vms/{id}/restart-policy", handleRestartPolicy(mgr, auditLog))
+	v1.API("/crash-loops", handleCrashLoops(mgr))
+
+	// 13. HEALTH CHECKS (results also show up in GET /api/v1/vms)
+	v1.API("/vms/{id}/health-check", handleHealthCheck(mgr, auditLog))
+
+	// 14. TENANT (own quota and usage; tenants are managed with 'vps-manager tenant')
+	v1.API("/tenant", handleTenant(mgr, opts.Tenants))
+
+	// 15. AUDIT LOG (filters as query parameters, ?format=jsonl to export)
+	v1.API("/audit", handleAudit(auditLog))
+
+	// 16. LIVENESS AND API DESCRIPTION (no API key needed)
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc(api.SpecPath, handleSpec)
+
+	return withRequestID(timed(notFound(mux, requireKey(opts.Keys, ownedVMs(mgr, mux)))))
+}
+
+func handleRebuild(mgr *vm.Manager, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "POST only", 405)
+			writeError(w, r, "POST only", 405)
 			return
 		}
-		var req struct {
-			ID     string `json:"id"`
-			Action string `json:"action"`
+		var req api.RebuildRequest
+		if !decodeJSON(w, r, &req) {
+			return
 		}
-		json.NewDecoder(r.Body).Decode(&req)
-
-		if err := mgr.PerformAction(req.ID, req.Action); err != nil {
-			http.Error(w, err.Error(), 500)
+		var invalid fieldErrors
+		invalid.require("image", req.Image)
+		invalid.require("password", req.Password)
+		invalid.check("password", cloudinit.CheckPassword(req.Password))
+		if invalid.write(w, r) {
 			return
 		}
-		w.WriteHeader(200)
-	})
 
-	fmt.Printf("📡 VPS Control Plane running on %s\n", port)
-	log.Fatal(http.ListenAndServe(port, nil))
-}
-
-func handleImageWebhook(store *images.Store) http.HandlerFunc {
-	type LegacyImageRelease struct {
-		Distro  string `json:"distro"`
-		Version string `json:"version"`
-		URL     string `json:"url"`
-	}
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req LegacyImageRelease
-		json.NewDecoder(r.Body).Decode(&req)
-		logicalName := strings.ToLower(fmt.Sprintf("%s-%s", req.Distro, req.Version))
-
-		fmt.Printf("🔔 Beacon Alert: Update found for '%s'\n", logicalName)
-		store.Register(logicalName, req.URL, "")
-		go func() {
-			store.Resolve(logicalName)
-		}()
-		w.WriteHeader(200)
+		id := r.PathValue("id")
+		params := map[string]any{"image": req.Image, "password": req.Password}
+		job := queue.Submit("vm.rebuild", id, auditedJob(log, r, "vm.rebuild", id, params, func(progress jobs.Progress) error {
+			progress(fmt.Sprintf("rebuilding %s from %s", id, req.Image))
+			return mgr.RebuildServer(id, req.Image, req.Password)
+		}))
+		writeJob(w, job)
 	}
 }
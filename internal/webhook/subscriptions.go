@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/subscriptions"
+)
+
+// GET  /api/v1/subscriptions  -> list (secrets hidden)
+// POST /api/v1/subscriptions  {"url":"https://billing/hook","events":["vm.*"],"secret":"optional"}
+func handleSubscriptions(subs *subscriptions.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(wireList(subs.List(), wireSubscription))
+
+		case http.MethodPost:
+			var req api.SubscriptionRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			sub, err := subs.Add(subscriptions.Subscription{URL: req.URL, Events: req.Events, Secret: req.Secret})
+			record(log, r, "subscription.create", req.URL, map[string]any{"events": req.Events}, err)
+			if err != nil {
+				writeError(w, r, err.Error(), 400)
+				return
+			}
+			// The only time the secret is shown
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(wireSubscription(sub))
+
+		default:
+			writeError(w, r, "GET or POST only", 405)
+		}
+	}
+}
+
+// DELETE /api/v1/subscriptions/{id}
+func handleSubscription(subs *subscriptions.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, r, "DELETE only", 405)
+			return
+		}
+		id := r.PathValue("id")
+		err := subs.Remove(id)
+		record(log, r, "subscription.delete", id, nil, err)
+		if err != nil {
+			writeError(w, r, err.Error(), 404)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /api/v1/subscriptions/{id}/deliveries
+func handleDeliveries(subs *subscriptions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		id := r.PathValue("id")
+		if _, ok := subs.Get(id); !ok {
+			writeError(w, r, "subscription not found", 404)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wireList(subs.Deliveries(id), wireDelivery))
+	}
+}
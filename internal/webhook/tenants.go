@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/internal/auth"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// tenantOf returns the tenant of the request's API key
+func tenantOf(r *http.Request) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return key.TenantName()
+	}
+	return tenants.Admin
+}
+
+// ownedVMs answers 404 for /api/v1/vms/{id}/... of other tenants' VMs, exactly
+// as if they didn't exist. /api/v1/vms and /api/vms/action filter themselves.
+func ownedVMs(mgr *vm.Manager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := apiPath(r.URL.Path)
+		if rest, ok := strings.CutPrefix(path, "/api/vms/"); ok && path != "/api/vms/action" {
+			id, _, _ := strings.Cut(rest, "/")
+			if !mgr.Owns(tenantOf(r), id) {
+				writeError(w, r, "vm '"+id+"' not found", 404)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /api/v1/tenant -> the caller's tenant, quota and usage
+func handleTenant(mgr *vm.Manager, store *tenants.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		t, err := store.Get(tenantOf(r))
+		if err != nil {
+			writeError(w, r, err.Error(), 404)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wireTenant(t, mgr.Usage(t.Name)))
+	}
+}
+
+// checkCreate fails fast (before a job is queued) on quota and network limits
+func checkCreate(mgr *vm.Manager, tenant, plan string, networks []string) error {
+	if err := mgr.CheckNetworks(tenant, networks); err != nil {
+		return err
+	}
+	add, err := vm.PlanResources(plan, networks)
+	if err != nil {
+		return nil // CreateServer falls back to the first plan
+	}
+	return mgr.CheckQuota(tenant, add)
+}
@@ -0,0 +1,442 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/backend"
+	"github.com/Shaman786/vps-manager/internal/cloudinit"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// GET  /api/v1/vms -> the caller's VMs
+// POST /api/v1/vms {"name":"web1","image":"ubuntu-24.04","plan":"Starter",...} -> job
+func handleVMs(mgr *vm.Manager, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			vms, _ := mgr.ListServersFor(tenantOf(r))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(wireList(vms, wireVM))
+			return
+		case http.MethodPost:
+		default:
+			writeError(w, r, "GET or POST only", 405)
+			return
+		}
+
+		var req api.CreateVMRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var invalid fieldErrors
+		if !validName(req.Name) {
+			invalid.add("name", "must be 1-63 letters, digits, '-', '_' or '.' (and not \"action\")")
+		}
+		invalid.require("image", req.Image)
+		if req.Plan != "" && !knownPlan(req.Plan) {
+			invalid.add("plan", "unknown plan %q (see GET %s/plans)", req.Plan, APIPrefix)
+		}
+		for i, n := range req.Networks {
+			invalid.check(fmt.Sprintf("networks[%d]", i), core.CheckNetwork(n))
+		}
+		for i, k := range req.SSHKeys {
+			invalid.check(fmt.Sprintf("ssh_keys[%d]", i), cloudinit.CheckSSHKey(k))
+		}
+		invalid.username("username", req.Username)
+		invalid.check("password", cloudinit.CheckPassword(req.Password))
+		for k := range req.Labels {
+			if k == "" {
+				invalid.add("labels", "keys must not be empty")
+			}
+		}
+		if invalid.write(w, r) {
+			return
+		}
+
+		// Defaults
+		if req.Plan == "" {
+			req.Plan = "Starter"
+		}
+		if req.Username == "" {
+			req.Username = "root"
+		}
+		if req.Password == "" {
+			req.Password = "password"
+		}
+		params := map[string]any{"image": req.Image, "plan": req.Plan, "username": req.Username,
+			"password": req.Password, "networks": req.Networks, "ssh_keys": len(req.SSHKeys), "labels": req.Labels}
+		if err := checkCreate(mgr, tenantOf(r), req.Plan, req.Networks); err != nil {
+			record(log, r, "vm.create", req.Name, params, err)
+			writeErr(w, r, err)
+			return
+		}
+
+		opts := vm.CreateOptions{
+			Name:     req.Name,
+			Image:    req.Image,
+			PlanName: req.Plan,
+			Username: req.Username,
+			Password: req.Password,
+			Networks: req.Networks,
+			SSHKeys:  req.SSHKeys,
+			Labels:   req.Labels,
+			Tenant:   tenantOf(r),
+		}
+		job := queue.Submit("vm.create", req.Name, auditedJob(log, r, "vm.create", req.Name, params, func(progress jobs.Progress) error {
+			opts.Progress = progress
+			return mgr.CreateServer(opts)
+		}))
+		writeJob(w, job)
+	}
+}
+
+// GET    /api/v1/vms/{id} -> state, inventory record, restart policy, health
+// DELETE /api/v1/vms/{id} -> job
+func handleVM(mgr *vm.Manager, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		switch r.Method {
+		case http.MethodGet:
+			info, err := mgr.Info(id)
+			if err != nil {
+				writeError(w, r, err.Error(), 404)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(wireVMInfo(info))
+		case http.MethodDelete:
+			runAction(w, r, mgr, queue, log, id, "delete", vm.ActionParams{})
+		default:
+			writeError(w, r, "GET or DELETE only", 405)
+		}
+	}
+}
+
+func actionParams(req api.ActionRequest) vm.ActionParams {
+	return vm.ActionParams{Username: req.Username, Password: req.Password, SSHKey: req.SSHKey}
+}
+
+// POST /api/v1/vms/{id}/start (stop, reboot, rescue, ...) -> VM info, or a job for disk-heavy actions
+func handleVMAction(mgr *vm.Manager, queue *jobs.Store, log *audit.Log, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+		var req api.ActionRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if actionErrors(action, req).write(w, r) {
+			return
+		}
+		runAction(w, r, mgr, queue, log, r.PathValue("id"), action, actionParams(req))
+	}
+}
+
+// POST /api/vms/action {"id":"web1","action":"start"} (deprecated)
+func handleLegacyAction(mgr *vm.Manager, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+		w.Header().Set("Link", "<"+APIPrefix+`/vms/{id}/{action}>; rel="successor-version"`)
+		var req struct {
+			ID     string `json:"id"`
+			Action string `json:"action"`
+			api.ActionRequest
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var invalid fieldErrors
+		invalid.require("id", req.ID)
+		switch {
+		case req.Action == "delete":
+		case !slices.Contains(VMActions, req.Action):
+			invalid.add("action", "must be one of delete, %s", strings.Join(VMActions, ", "))
+		default:
+			invalid = append(invalid, actionErrors(req.Action, req.ActionRequest)...)
+		}
+		if invalid.write(w, r) {
+			return
+		}
+		if !mgr.Owns(tenantOf(r), req.ID) {
+			writeError(w, r, fmt.Sprintf("vm '%s' not found", req.ID), 404)
+			return
+		}
+
+		successor := APIPrefix + "/vms/" + url.PathEscape(req.ID) + "/" + req.Action
+		if req.Action == "delete" {
+			successor = "DELETE " + APIPrefix + "/vms/" + url.PathEscape(req.ID)
+		}
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		runAction(w, r, mgr, queue, log, req.ID, req.Action, actionParams(req.ActionRequest))
+	}
+}
+
+// actionErrors checks the parameters an action needs
+func actionErrors(action string, req api.ActionRequest) fieldErrors {
+	var invalid fieldErrors
+	switch action {
+	case "rescue":
+		if req.Password == "" && req.SSHKey == "" {
+			invalid.add("password", "rescue needs a temporary password or ssh_key")
+		}
+	case "reset-password":
+		invalid.require("password", req.Password)
+	case "add-ssh-key":
+		invalid.require("ssh_key", req.SSHKey)
+	}
+	if req.SSHKey != "" {
+		invalid.check("ssh_key", cloudinit.CheckSSHKey(req.SSHKey))
+	}
+	invalid.username("username", req.Username)
+	invalid.check("password", cloudinit.CheckPassword(req.Password))
+	return invalid
+}
+
+// runAction runs disk-heavy actions as jobs and answers quick ones with the VM's info
+func runAction(w http.ResponseWriter, r *http.Request, mgr *vm.Manager, queue *jobs.Store, log *audit.Log, id, action string, params vm.ActionParams) {
+	if heavyActions[action] {
+		job := queue.Submit("vm."+action, id, auditedJob(log, r, "vm."+action, id, params.Audit(), func(progress jobs.Progress) error {
+			progress(action + " " + id)
+			return mgr.PerformActionWithParams(id, action, params)
+		}))
+		writeJob(w, job)
+		return
+	}
+
+	err := mgr.PerformActionWithParams(id, action, params)
+	record(log, r, "vm."+action, id, params.Audit(), err)
+	if err != nil {
+		writeErr(w, r, err)
+		return
+	}
+	info, _ := mgr.Info(id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wireVMInfo(info))
+}
+
+// validName: VM names end up as libvirt domain names and guest hostnames.
+// "action" is taken by the legacy /api/vms/action route.
+func validName(name string) bool {
+	if name == "" || len(name) > 63 || name[0] == '-' || name[0] == '.' || name == "action" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func knownPlan(name string) bool {
+	return slices.ContainsFunc(plans.Available, func(p plans.VMPlan) bool { return strings.EqualFold(p.Name, name) })
+}
+
+// GET /api/v1/vms/{id}/metrics -> raw counters (cpu_time_ns, vcpus, mem_kb...)
+func handleMetrics(mgr *vm.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		metrics, err := mgr.Metrics(r.PathValue("id"))
+		switch {
+		case errors.Is(err, vm.ErrNotFound):
+			writeError(w, r, err.Error(), 404)
+			return
+		case err != nil:
+			writeError(w, r, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(metrics)
+	}
+}
+
+// POST /api/v1/vms/{id}/resize {"plan":"Professional"} -> job
+func handleResize(mgr *vm.Manager, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+		var req api.ResizeRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var invalid fieldErrors
+		invalid.require("plan", req.Plan)
+		if req.Plan != "" && !knownPlan(req.Plan) {
+			invalid.add("plan", "unknown plan %q (see GET %s/plans)", req.Plan, APIPrefix)
+		}
+		if invalid.write(w, r) {
+			return
+		}
+
+		id := r.PathValue("id")
+		if mgr.State(id) == vm.Deleted {
+			writeError(w, r, fmt.Sprintf("vm '%s' not found", id), 404)
+			return
+		}
+		if err := mgr.CheckResize(id, req.Plan); err != nil {
+			record(log, r, "vm.resize", id, map[string]any{"plan": req.Plan}, err)
+			writeErr(w, r, err)
+			return
+		}
+		job := queue.Submit("vm.resize", id, auditedJob(log, r, "vm.resize", id, map[string]any{"plan": req.Plan}, func(progress jobs.Progress) error {
+			progress(fmt.Sprintf("resizing %s to %s", id, req.Plan))
+			return mgr.ResizeServer(id, req.Plan)
+		}))
+		writeJob(w, job)
+	}
+}
+
+// PUT /api/v1/vms/{id}/labels {"role":"web"}
+func handleLabels(mgr *vm.Manager, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			writeError(w, r, "PUT only", 405)
+			return
+		}
+		var labels map[string]string
+		if !decodeJSON(w, r, &labels) {
+			return
+		}
+		id := r.PathValue("id")
+		err := mgr.SetLabels(id, labels)
+		record(log, r, "vm.labels", id, map[string]any{"labels": labels}, err)
+		if err != nil {
+			writeError(w, r, err.Error(), 404)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /api/v1/plans
+func handlePlans() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, "GET only", 405)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wireList(plans.Available, wirePlan))
+	}
+}
+
+// GET  /api/v1/images
+// POST /api/v1/images {"id":"debian-12","url":"https://...","pull":false} -> job (201 without pull)
+func handleImages(mgr *vm.Manager, store *images.Store, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(wireList(store.List(), wireImage))
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeError(w, r, "GET or POST only", 405)
+			return
+		}
+		var req api.RegisterImageRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var invalid fieldErrors
+		if !validName(req.ID) {
+			invalid.add("id", "must be 1-63 letters, digits, '-', '_' or '.'")
+		}
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid.add("url", "must be an http(s) URL")
+		}
+		if invalid.write(w, r) {
+			return
+		}
+
+		fmt.Printf("📥 Manual Image Registration: %s\n", req.ID)
+
+		// Register and (unless asked not to) immediately trigger download
+		err := store.Register(req.ID, req.URL, req.Format)
+		record(log, r, "image.register", req.ID, map[string]any{"url": req.URL, "format": req.Format}, err)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		if req.Pull != nil && !*req.Pull {
+			img, _ := store.Get(req.ID)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", APIPrefix+"/images/"+url.PathEscape(req.ID))
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(wireImage(img))
+			return
+		}
+		job := queue.Submit("image.download", req.ID, auditedJob(log, r, "image.pull", req.ID, nil, downloadImage(mgr, store, req.ID)))
+		writeJob(w, job)
+	}
+}
+
+// GET    /api/v1/images/{name}
+// DELETE /api/v1/images/{name}[?force=true]
+func handleImage(mgr *vm.Manager, store *images.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		img, err := store.Get(name)
+		if err != nil {
+			writeError(w, r, err.Error(), 404)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(wireImage(img))
+			return
+		case http.MethodDelete:
+		default:
+			writeError(w, r, "GET or DELETE only", 405)
+			return
+		}
+		err = backend.CheckImageUnused(mgr, name, r.URL.Query().Get("force") == "true")
+		if err == nil {
+			err = store.Remove(name)
+		}
+		record(log, r, "image.delete", name, nil, err)
+		if err != nil {
+			writeErr(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /api/v1/images/{name}/pull -> job
+func handleImagePull(mgr *vm.Manager, store *images.Store, queue *jobs.Store, log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, r, "POST only", 405)
+			return
+		}
+		name := r.PathValue("name")
+		if _, err := store.Get(name); err != nil {
+			writeError(w, r, err.Error(), 404)
+			return
+		}
+		writeJob(w, queue.Submit("image.download", name, auditedJob(log, r, "image.pull", name, nil, downloadImage(mgr, store, name))))
+	}
+}
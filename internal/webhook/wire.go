@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"github.com/Shaman786/vps-manager/api"
+	"github.com/Shaman786/vps-manager/internal/audit"
+	"github.com/Shaman786/vps-manager/internal/core"
+	"github.com/Shaman786/vps-manager/internal/events"
+	"github.com/Shaman786/vps-manager/internal/images"
+	"github.com/Shaman786/vps-manager/internal/jobs"
+	"github.com/Shaman786/vps-manager/internal/plans"
+	"github.com/Shaman786/vps-manager/internal/subscriptions"
+	"github.com/Shaman786/vps-manager/internal/tenants"
+	"github.com/Shaman786/vps-manager/internal/vm"
+)
+
+// Conversions from our structures to the api package's wire types. Types
+// with the same fields are converted directly, so a field added on one
+// side only stops the build instead of silently missing from the API.
+
+func wireVM(s core.VMState) api.VM {
+	return api.VM{
+		ID:     s.ID,
+		Name:   s.Name,
+		Status: s.Status,
+		IP:     s.IP,
+		State:  s.State,
+		Health: wireHealthReport(s.Health),
+		Plan:   s.Plan,
+		Image:  s.Image,
+		Tenant: s.Tenant,
+	}
+}
+
+func wireVMInfo(info vm.Info) api.VMInfo {
+	out := api.VMInfo{
+		VM:            wireVM(info.VMState),
+		RestartPolicy: api.RestartPolicy(info.Restart),
+		Restarts:      wireRestarts(info.Restarts),
+		HealthCheck:   wireHealthCheck(info.HealthCheck),
+	}
+	if info.Record != nil {
+		rec := wireRecord(*info.Record)
+		out.Record = &rec
+	}
+	return out
+}
+
+func wireRecord(r vm.Record) api.VMRecord {
+	out := api.VMRecord{
+		Name:      r.Name,
+		Image:     r.Image,
+		Plan:      r.Plan,
+		Username:  r.Username,
+		CreatedAt: r.CreatedAt,
+		RebuiltAt: r.RebuiltAt,
+		Networks:  r.Networks,
+		SSHKeys:   r.SSHKeys,
+		Labels:    r.Labels,
+		Tenant:    r.Tenant,
+		Restarts:  wireRestarts(r.Restarts),
+		Health:    wireHealthCheck(r.Health),
+	}
+	if r.Restart != nil {
+		p := api.RestartPolicy(*r.Restart)
+		out.Restart = &p
+	}
+	return out
+}
+
+func wireHealthReport(r *core.HealthReport) *api.HealthReport {
+	if r == nil {
+		return nil
+	}
+	out := &api.HealthReport{Status: r.Status, ConsecutiveFailures: r.ConsecutiveFailures, History: []api.HealthResult{}}
+	for _, h := range r.History {
+		out.History = append(out.History, api.HealthResult(h))
+	}
+	return out
+}
+
+func wireHealthCheck(c *vm.HealthCheck) *api.HealthCheck {
+	if c == nil {
+		return nil
+	}
+	out := api.HealthCheck(*c)
+	return &out
+}
+
+func wireRestarts(list []vm.RestartRecord) []api.RestartRecord {
+	if list == nil {
+		return nil
+	}
+	out := make([]api.RestartRecord, 0, len(list))
+	for _, r := range list {
+		out = append(out, api.RestartRecord(r))
+	}
+	return out
+}
+
+func wireImage(img images.ImageInfo) api.Image {
+	return api.Image(img)
+}
+
+func wirePlan(p plans.VMPlan) api.Plan {
+	return api.Plan{Name: p.Name, RAMMB: p.RAM, CPUs: p.CPUs, Disk: p.Disk}
+}
+
+func wireJob(j jobs.Job) api.Job {
+	out := api.Job{
+		ID:         j.ID,
+		Type:       j.Type,
+		Target:     j.Target,
+		State:      api.JobState(j.State),
+		Steps:      make([]api.JobStep, 0, len(j.Steps)),
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+	for _, s := range j.Steps {
+		out.Steps = append(out.Steps, api.JobStep(s))
+	}
+	return out
+}
+
+func wireEvent(e events.Event) api.Event {
+	return api.Event(e)
+}
+
+func wireSubscription(s subscriptions.Subscription) api.Subscription {
+	return api.Subscription(s)
+}
+
+func wireDelivery(d subscriptions.Delivery) api.Delivery {
+	return api.Delivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventType:      d.EventType,
+		Subject:        d.Subject,
+		State:          string(d.State),
+		Attempts:       d.Attempts,
+		LastStatus:     d.LastStatus,
+		LastError:      d.LastError,
+		NextAttemptAt:  d.NextAttemptAt,
+		CreatedAt:      d.CreatedAt,
+		Payload:        d.Payload,
+	}
+}
+
+func wireTenant(t tenants.Tenant, usage tenants.Resources) api.Tenant {
+	return api.Tenant{
+		Name:      t.Name,
+		Quota:     api.Resources(t.Quota),
+		Networks:  t.Networks,
+		CreatedAt: t.CreatedAt,
+		Usage:     api.Resources(usage),
+	}
+}
+
+func wireAuditEntry(e audit.Entry) api.AuditEntry {
+	return api.AuditEntry(e)
+}
+
+// wireList converts every element; the result is never nil, so empty lists
+// encode as []
+func wireList[T, W any](list []T, conv func(T) W) []W {
+	out := make([]W, 0, len(list))
+	for _, v := range list {
+		out = append(out, conv(v))
+	}
+	return out
+}